@@ -14,11 +14,16 @@ import (
 
 	"github.com/agallagher-captech/blog/internal/configuration"
 	"github.com/agallagher-captech/blog/internal/middleware"
+	"github.com/agallagher-captech/blog/internal/migrations"
 	"github.com/agallagher-captech/blog/internal/routes"
+	"github.com/agallagher-captech/blog/internal/seed"
 	"github.com/agallagher-captech/blog/internal/services"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/agallagher-captech/blog/internal/services/instrument"
+	"github.com/agallagher-captech/blog/internal/services/memory"
+	"github.com/agallagher-captech/blog/internal/services/retry"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go/aws"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
@@ -37,49 +42,91 @@ func run(ctx context.Context, w io.Writer, args []string) error {
 	}
 
 	// Create a structured logger, which will print logs in json format to the
-	// writer we specify.
-	logger := slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+	// writer we specify. Records logged with a request's context are tagged
+	// with its request id.
+	logger := slog.New(middleware.NewContextHandler(slog.NewJSONHandler(w, &slog.HandlerOptions{
 		Level: cfg.LogLevel,
-	}))
+	})))
+
+	// The table the services read and write in this environment
+	table := services.Table{Name: cfg.DynamoTable, GSI1: cfg.DynamoGSI1}
 
 	// connect to dynamoDB
-	logger.InfoContext(ctx, "connecting to DynamoDB")
-	awsCfg, err := config.LoadDefaultConfig(ctx)
+	client, err := newDynamoClient(ctx, logger, cfg, table)
 	if err != nil {
-		return fmt.Errorf("[in main.run] failed to load configuration: %w", err)
+		return err
 	}
 
-	client := dynamodb.NewFromConfig(awsCfg, func(options *dynamodb.Options) {
-		options.BaseEndpoint = aws.String(cfg.DynamoEndpoint)
-	})
+	// Record every request sent to DynamoDB, and retry the ones it throttles
+	// or fails on its side. Metrics are collected in the default Prometheus
+	// registry, which is served by the metrics server.
+	dynamoMetrics, err := instrument.NewMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		return fmt.Errorf("[in main.run] failed to create DynamoDB metrics: %w", err)
+	}
+	retryMetrics, err := retry.NewMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		return fmt.Errorf("[in main.run] failed to create retry metrics: %w", err)
+	}
+	client = retry.NewClient(instrument.NewClient(client, dynamoMetrics), cfg.RetryPolicy(), logger, retryMetrics)
 
-	// list all tables in db (we will delete this later)
-	result, err := client.ListTables(ctx, &dynamodb.ListTablesInput{})
+	httpMetrics, err := middleware.NewHTTPMetrics(prometheus.DefaultRegisterer)
 	if err != nil {
-		return fmt.Errorf("[in main.run] failed to list tables: %w", err)
+		return fmt.Errorf("[in main.run] failed to create HTTP metrics: %w", err)
 	}
 
-	fmt.Println("Tables:")
-	for _, tableName := range result.TableNames {
-		fmt.Printf("* %s\n", tableName)
+	// Check that the table and its indexes match the schema the services
+	// expect, creating anything missing if configured to
+	if err = services.EnsureSchema(ctx, logger, client, table, cfg.DynamoCreateSchema); err != nil {
+		return fmt.Errorf("[in main.run] failed to check table schema: %w", err)
 	}
 
+	// Create the codec used to sign pagination cursors
+	cursors := services.NewCursors(cfg.CursorSigningKey)
+
 	// Create a new users service
-	usersService := services.NewUsersService(logger, client)
+	usersService := services.NewUsersService(logger, client, table, cursors)
+
+	// Create the blogs and comments services
+	blogsService := services.NewBlogsService(logger, client, table, cursors)
+	commentsService := services.NewCommentsService(logger, client, table, cursors)
+
+	// Create a new auth service
+	authService := services.NewAuthService(
+		logger,
+		client,
+		table,
+		usersService,
+		cfg.JWTSigningKey,
+		cfg.AccessTokenTTL,
+		cfg.RefreshTokenTTL,
+	)
 
 	// Create a serve mux to act as our route multiplexer
 	mux := http.NewServeMux()
 
-	// Add our routes to the mux
 	// Add our routes to the mux
 	routes.AddRoutes(
 		mux,
 		logger,
 		usersService,
+		blogsService,
+		commentsService,
+		authService,
 		fmt.Sprintf("http://%s:%s", cfg.Host, cfg.Port),
 	)
-	// Wrap the mux with middleware
-	wrappedMux := middleware.Logger(logger)(mux)
+	// Wrap the mux with middleware. RequestID runs first so every log line
+	// below it carries the request id, and Recovery sits inside Logger and
+	// Metrics so that the 500 written for a recovered panic is logged and
+	// counted like any other response. Nothing between Metrics and the mux
+	// may replace the request, or Metrics cannot see the route it matched.
+	wrappedMux := middleware.RequestID()(
+		middleware.Metrics(httpMetrics)(
+			middleware.Logger(logger)(
+				middleware.Recovery(logger)(mux),
+			),
+		),
+	)
 
 	// Create a new http server with our mux as the handler
 	httpServer := &http.Server{
@@ -87,6 +134,15 @@ func run(ctx context.Context, w io.Writer, args []string) error {
 		Handler: wrappedMux,
 	}
 
+	// Serve metrics on a port of their own, if one is configured
+	var metricsServer *http.Server
+	if cfg.MetricsPort != "" {
+		metricsServer, err = serveMetrics(ctx, logger, net.JoinHostPort(cfg.Host, cfg.MetricsPort))
+		if err != nil {
+			return err
+		}
+	}
+
 	errChan := make(chan error)
 
 	// Server run context
@@ -106,7 +162,13 @@ func run(ctx context.Context, w io.Writer, args []string) error {
 		ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.ShutdownTimout)*time.Second)
 		defer cancel()
 
-		// Shutdown the server. If an error occurs, send it to the error channel
+		// Shutdown the servers. If an error occurs, send it to the error channel
+		if metricsServer != nil {
+			if err = metricsServer.Shutdown(ctx); err != nil {
+				errChan <- fmt.Errorf("[in main.run] failed to shutdown metrics server: %w", err)
+				return
+			}
+		}
 		if err = httpServer.Shutdown(ctx); err != nil {
 			errChan <- fmt.Errorf("[in main.run] failed to shutdown http server: %w", err)
 			return
@@ -134,3 +196,72 @@ func run(ctx context.Context, w io.Writer, args []string) error {
 		return nil
 	}
 }
+
+// serveMetrics starts a server for the Prometheus metrics of the default
+// registry at the provided address. The address is listened on before
+// serveMetrics returns, so a port that is in use stops the server from
+// starting. The metrics server must be shut down along with the API server.
+func serveMetrics(ctx context.Context, logger *slog.Logger, addr string) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("[in main.serveMetrics] failed to listen: %w", err)
+	}
+
+	logger.InfoContext(ctx, "serving metrics", slog.String("address", addr))
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.ErrorContext(ctx, "metrics server failed", slog.String("error", err.Error()))
+		}
+	}()
+	return server, nil
+}
+
+// newDynamoClient connects to DynamoDB at the configured endpoint, or loads the
+// seed data into an in-memory table if the server is configured to run in
+// memory. The in-memory table is given the configured names and every
+// migration is applied to it, as `make seed-database` does for a real table.
+// The AWS client does not retry requests itself.
+func newDynamoClient(ctx context.Context, logger *slog.Logger, cfg configuration.Configuration, table services.Table) (retry.API, error) {
+	if cfg.DynamoInMemory {
+		logger.InfoContext(ctx, "loading in-memory DynamoDB", slog.String("seed_dir", cfg.DynamoSeedDir))
+		schema, requests, err := seed.ReadDir(cfg.DynamoSeedDir)
+		if err != nil {
+			return nil, fmt.Errorf("[in main.newDynamoClient] failed to read seed data: %w", err)
+		}
+		seed.Rename(schema, requests, table.Name, map[string]string{services.DefaultTable().GSI1: table.GSI1})
+
+		client := memory.NewClient()
+		if err = client.Load(schema, requests); err != nil {
+			return nil, fmt.Errorf("[in main.newDynamoClient] failed to load in-memory table: %w", err)
+		}
+
+		runner, err := migrations.NewRunner(logger, client, table.Name, migrations.All())
+		if err != nil {
+			return nil, fmt.Errorf("[in main.newDynamoClient] failed to create migration runner: %w", err)
+		}
+		if _, err = runner.Up(ctx, 0, false); err != nil {
+			return nil, fmt.Errorf("[in main.newDynamoClient] failed to migrate in-memory table: %w", err)
+		}
+		return client, nil
+	}
+
+	logger.InfoContext(ctx, "connecting to DynamoDB",
+		slog.String("endpoint", cfg.DynamoEndpoint),
+		slog.String("table", table.Name))
+	// Requests are retried by the retry.Client wrapping this one, so the SDK
+	// only makes a single attempt
+	client, err := cfg.DynamoClient(ctx, func(options *dynamodb.Options) {
+		options.RetryMaxAttempts = 1
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[in main.newDynamoClient] failed to create client: %w", err)
+	}
+	return client, nil
+}
@@ -0,0 +1,105 @@
+// Command migrate applies the migrations of internal/migrations to the
+// DynamoDB table of the server's configuration.
+//
+// Usage:
+//
+//	go run ./cmd/migrate status
+//	go run ./cmd/migrate [-dry-run] [-to version] up
+//
+// status lists every migration and whether it has been applied. up applies
+// every pending migration in order, or those up to and including -to, and
+// resumes any migration that failed part way. With -dry-run nothing is
+// written, and the changes migrations would make are logged instead.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/agallagher-captech/blog/internal/configuration"
+	"github.com/agallagher-captech/blog/internal/migrations"
+)
+
+func main() {
+	ctx := context.Background()
+	if err := run(ctx, os.Stdout, os.Args); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "migrate encountered an error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, w io.Writer, args []string) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "log the changes migrations would make without writing them")
+	target := flags.Int("to", 0, "the last migration to apply, or 0 to apply every migration")
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("[in main.run] failed to parse flags: %w", err)
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("[in main.run] expected one command of status or up, got %d arguments", flags.NArg())
+	}
+
+	cfg, err := configuration.NewDatabase()
+	if err != nil {
+		return fmt.Errorf("[in main.run] failed to load configuration: %w", err)
+	}
+	// Requests are retried in the same way as the server's, so a throttled
+	// backfill slows down rather than failing
+	logger := slog.New(slog.NewTextHandler(w, nil))
+	client, err := cfg.RetryClient(ctx, logger)
+	if err != nil {
+		return fmt.Errorf("[in main.run] failed to create client: %w", err)
+	}
+
+	runner, err := migrations.NewRunner(logger, client, cfg.DynamoTable, migrations.All())
+	if err != nil {
+		return fmt.Errorf("[in main.run] failed to create runner: %w", err)
+	}
+
+	switch command := flags.Arg(0); command {
+	case "status":
+		return status(ctx, w, runner)
+	case "up":
+		applied, err := runner.Up(ctx, *target, *dryRun)
+		if err != nil {
+			return fmt.Errorf("[in main.run] failed to apply migrations: %w", err)
+		}
+		if *dryRun {
+			_, _ = fmt.Fprintf(w, "dry run: %d migrations would be applied\n", applied)
+		} else {
+			_, _ = fmt.Fprintf(w, "applied %d migrations\n", applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("[in main.run] unknown command %q, expected status or up", command)
+	}
+}
+
+// status writes a table of every migration and its status.
+func status(ctx context.Context, w io.Writer, runner *migrations.Runner) error {
+	states, err := runner.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("[in main.status] failed to read migrations: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, state := range states {
+		appliedAt := "-"
+		if !state.AppliedAt.IsZero() {
+			appliedAt = state.AppliedAt.Format(time.RFC3339)
+		}
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", state.Migration.Version, state.Migration.Name, state.Status, appliedAt)
+	}
+	return tw.Flush()
+}
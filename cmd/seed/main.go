@@ -0,0 +1,204 @@
+// Command seed manages the DynamoDB table of the server's configuration with
+// the seed data in ./dynamodb_seed.
+//
+// Usage:
+//
+//	go run ./cmd/seed [-dir ./dynamodb_seed] load|reset|export
+//
+// load creates the table from table_schema.json, writes the items of
+// batch_items.json and applies every migration of internal/migrations, since
+// the seed items are stored as they were before any migration, e.g. with
+// plaintext passwords. reset deletes the table if it exists and loads it
+// again.
+// export writes the schema and items of the live table back to the seed files.
+//
+// The seed files always use the default names of services.DefaultTable. The
+// table is given the names configured by DYNAMODB_TABLE and DYNAMODB_GSI1 when
+// it is loaded, and its names are changed back when it is exported.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/agallagher-captech/blog/internal/configuration"
+	"github.com/agallagher-captech/blog/internal/migrations"
+	"github.com/agallagher-captech/blog/internal/seed"
+	"github.com/agallagher-captech/blog/internal/services"
+	"github.com/agallagher-captech/blog/internal/services/retry"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// tableWaitTimeout is how long to wait for a table to be created or deleted.
+const tableWaitTimeout = 2 * time.Minute
+
+func main() {
+	ctx := context.Background()
+	if err := run(ctx, os.Stdout, os.Args); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "seed encountered an error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, w io.Writer, args []string) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	dir := flags.String("dir", "./dynamodb_seed", "directory holding the seed files")
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("[in main.run] failed to parse flags: %w", err)
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("[in main.run] expected one command of load, reset or export, got %d arguments", flags.NArg())
+	}
+
+	cfg, err := configuration.NewDatabase()
+	if err != nil {
+		return fmt.Errorf("[in main.run] failed to load configuration: %w", err)
+	}
+	// Requests are retried in the same way as the server's, which includes
+	// sending the items DynamoDB leaves unprocessed again
+	logger := slog.New(slog.NewTextHandler(w, nil))
+	client, err := cfg.RetryClient(ctx, logger)
+	if err != nil {
+		return fmt.Errorf("[in main.run] failed to create client: %w", err)
+	}
+	table := services.Table{Name: cfg.DynamoTable, GSI1: cfg.DynamoGSI1}
+
+	switch command := flags.Arg(0); command {
+	case "load":
+		return load(ctx, w, logger, client, *dir, table)
+	case "reset":
+		return reset(ctx, w, logger, client, *dir, table)
+	case "export":
+		return export(ctx, w, client, *dir, table)
+	default:
+		return fmt.Errorf("[in main.run] unknown command %q, expected load, reset or export", command)
+	}
+}
+
+// load creates the table described by the schema file in dir, with the names
+// of the provided table, writes the items of its items file and migrates them.
+func load(ctx context.Context, w io.Writer, logger *slog.Logger, client *retry.Client, dir string, table services.Table) error {
+	schema, requests, err := seed.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("[in main.load] failed to read seed data: %w", err)
+	}
+	seed.Rename(schema, requests, table.Name, map[string]string{services.DefaultTable().GSI1: table.GSI1})
+
+	_, _ = fmt.Fprintf(w, "creating table %s\n", aws.StringValue(schema.TableName))
+	if _, err = client.CreateTable(ctx, schema); err != nil {
+		return fmt.Errorf("[in main.load] failed to create table: %w", err)
+	}
+	err = dynamodb.NewTableExistsWaiter(client).Wait(ctx, &dynamodb.DescribeTableInput{
+		TableName: schema.TableName,
+	}, tableWaitTimeout)
+	if err != nil {
+		return fmt.Errorf("[in main.load] failed to wait for table: %w", err)
+	}
+
+	batches, err := seed.Write(ctx, client, requests)
+	if err != nil {
+		return fmt.Errorf("[in main.load] failed to write items: %w", err)
+	}
+	written := 0
+	for _, writes := range requests {
+		written += len(writes)
+	}
+
+	_, _ = fmt.Fprintf(w, "wrote %d items in %d batches\n", written, batches)
+
+	runner, err := migrations.NewRunner(logger, client, table.Name, migrations.All())
+	if err != nil {
+		return fmt.Errorf("[in main.load] failed to create migration runner: %w", err)
+	}
+	applied, err := runner.Up(ctx, 0, false)
+	if err != nil {
+		return fmt.Errorf("[in main.load] failed to migrate seed data: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(w, "applied %d migrations\n", applied)
+	return nil
+}
+
+// reset deletes the table, if it exists, and loads it again.
+func reset(ctx context.Context, w io.Writer, logger *slog.Logger, client *retry.Client, dir string, table services.Table) error {
+	_, _ = fmt.Fprintf(w, "deleting table %s\n", table.Name)
+	_, err := client.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: aws.String(table.Name)})
+	var notFound *types.ResourceNotFoundException
+	switch {
+	case errors.As(err, &notFound):
+		_, _ = fmt.Fprintf(w, "table %s does not exist\n", table.Name)
+	case err != nil:
+		return fmt.Errorf("[in main.reset] failed to delete table: %w", err)
+	default:
+		err = dynamodb.NewTableNotExistsWaiter(client).Wait(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(table.Name),
+		}, tableWaitTimeout)
+		if err != nil {
+			return fmt.Errorf("[in main.reset] failed to wait for table deletion: %w", err)
+		}
+	}
+
+	return load(ctx, w, logger, client, dir, table)
+}
+
+// export writes the schema and every item of the table to the seed files in
+// dir, under the default names. Both files are only written once the whole
+// table has been read.
+func export(ctx context.Context, w io.Writer, client *retry.Client, dir string, table services.Table) error {
+	described, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table.Name)})
+	if err != nil {
+		return fmt.Errorf("[in main.export] failed to describe table: %w", err)
+	}
+	defaults := services.DefaultTable()
+	description := *described.Table
+	description.TableName = aws.String(defaults.Name)
+	description.GlobalSecondaryIndexes = slices.Clone(description.GlobalSecondaryIndexes)
+	for i, gsi := range description.GlobalSecondaryIndexes {
+		if aws.StringValue(gsi.IndexName) == table.GSI1 {
+			description.GlobalSecondaryIndexes[i].IndexName = aws.String(defaults.GSI1)
+		}
+	}
+	var schema bytes.Buffer
+	if err = seed.WriteTableSchema(&schema, &description); err != nil {
+		return fmt.Errorf("[in main.export] failed to write schema: %w", err)
+	}
+
+	var items bytes.Buffer
+	exported := 0
+	paginator := dynamodb.NewScanPaginator(client, &dynamodb.ScanInput{TableName: aws.String(table.Name)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("[in main.export] failed to scan table: %w", err)
+		}
+		if err = seed.WriteBatchItems(&items, defaults.Name, page.Items); err != nil {
+			return fmt.Errorf("[in main.export] failed to write items: %w", err)
+		}
+		exported += len(page.Items)
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, seed.SchemaFile), schema.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("[in main.export] failed to write schema file: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, seed.ItemsFile), items.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("[in main.export] failed to write items file: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(w, "exported %d items from %s to %s\n", exported, table.Name, dir)
+	return nil
+}
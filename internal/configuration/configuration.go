@@ -1,21 +1,89 @@
 package configuration
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
+	"github.com/agallagher-captech/blog/internal/services/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
 )
 
+// minSigningKeyLength is the length, in bytes, that the HMAC signing keys must
+// have at least, which is the size of the SHA-256 digest they are used with.
+const minSigningKeyLength = 32
+
 // Config holds the application configuration settings. The configuration is loaded from
 // environment variables.
 type Configuration struct {
-	DynamoEndpoint string     `env:"DYNAMODB_ENDPOINT,required"`
+	Database
+
 	Host           string     `env:"HOST,required"`
 	Port           string     `env:"PORT,required"`
 	LogLevel       slog.Level `env:"LOG_LEVEL,required"`
 	ShutdownTimout int        `env:"SHUTDOWN_TIMEOUT,required"`
+
+	// MetricsPort is the port Prometheus metrics are served on, at /metrics.
+	// They are served by a listener of their own so that they are not
+	// exposed along with the API, and not at all if it is empty.
+	MetricsPort string `env:"METRICS_PORT"`
+
+	// JWTSigningKey is the HMAC secret used to sign and verify access and
+	// refresh tokens. It must be at least minSigningKeyLength bytes long.
+	JWTSigningKey   string        `env:"JWT_SIGNING_KEY,required,unset"`
+	AccessTokenTTL  time.Duration `env:"ACCESS_TOKEN_TTL" envDefault:"15m"`
+	RefreshTokenTTL time.Duration `env:"REFRESH_TOKEN_TTL" envDefault:"168h"`
+
+	// CursorSigningKey is the HMAC secret used to make pagination cursors
+	// tamper-evident. It must be at least minSigningKeyLength bytes long.
+	CursorSigningKey string `env:"CURSOR_SIGNING_KEY,required,unset"`
+}
+
+// Database holds the settings used to connect to DynamoDB. They can be loaded
+// on their own with NewDatabase by tools, such as cmd/seed, that do not need
+// the rest of the server's configuration.
+type Database struct {
+	// DynamoEndpoint overrides the endpoint of DynamoDB, e.g. with the
+	// database started by docker-compose.yaml. If it is empty, the AWS SDK
+	// resolves the endpoint of the region, so a deployment that does not set
+	// it talks to DynamoDB itself.
+	DynamoEndpoint string `env:"DYNAMODB_ENDPOINT"`
+
+	// AWSRegion is the region of the table. If it is empty, the region is
+	// found by the AWS SDK, e.g. from the shared AWS config file.
+	AWSRegion string `env:"AWS_REGION"`
+
+	// DynamoTable and DynamoGSI1 name the table and its global secondary
+	// index, so that environments and developers can each use a table of
+	// their own. They default to the names in the seed data.
+	DynamoTable string `env:"DYNAMODB_TABLE" envDefault:"BlogContent"`
+	DynamoGSI1  string `env:"DYNAMODB_GSI1" envDefault:"GSI1"`
+
+	// DynamoInMemory runs the server against an in-memory table loaded from
+	// DynamoSeedDir instead of DynamoDB, for local development without
+	// Docker. Nothing written is kept once the server stops.
+	DynamoInMemory bool   `env:"DYNAMODB_IN_MEMORY" envDefault:"false"`
+	DynamoSeedDir  string `env:"DYNAMODB_SEED_DIR" envDefault:"./dynamodb_seed"`
+
+	// DynamoCreateSchema creates the table or any of its global secondary
+	// indexes if they are missing when the server starts. Otherwise the
+	// server refuses to start until they exist.
+	DynamoCreateSchema bool `env:"DYNAMODB_CREATE_SCHEMA" envDefault:"false"`
+
+	// DynamoMaxAttempts is the number of times the server sends a request
+	// that DynamoDB throttled or failed on its side before giving up. The
+	// backoff between attempts starts at DynamoRetryBaseDelay and doubles up
+	// to DynamoRetryMaxDelay. DynamoTimeout bounds each operation, retries
+	// included.
+	DynamoMaxAttempts    int           `env:"DYNAMODB_MAX_ATTEMPTS" envDefault:"5"`
+	DynamoRetryBaseDelay time.Duration `env:"DYNAMODB_RETRY_BASE_DELAY" envDefault:"25ms"`
+	DynamoRetryMaxDelay  time.Duration `env:"DYNAMODB_RETRY_MAX_DELAY" envDefault:"1s"`
+	DynamoTimeout        time.Duration `env:"DYNAMODB_TIMEOUT" envDefault:"5s"`
 }
 
 // New loads Configuration from environment variables and a .env file, and returns a
@@ -32,5 +100,82 @@ func New() (Configuration, error) {
 	if err != nil {
 		return Configuration{}, fmt.Errorf("[in configuration.New] failed to parse configuration: %w", err)
 	}
+	if err = cfg.validate(); err != nil {
+		return Configuration{}, fmt.Errorf("[in configuration.New] invalid configuration: %w", err)
+	}
 	return cfg, nil
 }
+
+// validate checks the settings that cannot be checked while parsing, so that
+// the server refuses to start with weak signing keys.
+func (c Configuration) validate() error {
+	keys := []struct {
+		name  string
+		value string
+	}{
+		{"JWT_SIGNING_KEY", c.JWTSigningKey},
+		{"CURSOR_SIGNING_KEY", c.CursorSigningKey},
+	}
+	for _, key := range keys {
+		if len(key.value) < minSigningKeyLength {
+			return fmt.Errorf("%s must be at least %d bytes long, got %d", key.name, minSigningKeyLength, len(key.value))
+		}
+	}
+	return nil
+}
+
+// NewDatabase loads the Database settings from environment variables and a .env
+// file, in the same way as New.
+func NewDatabase() (Database, error) {
+	_ = godotenv.Load()
+	cfg, err := env.ParseAs[Database]()
+	if err != nil {
+		return Database{}, fmt.Errorf("[in configuration.NewDatabase] failed to parse configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// DynamoClient returns a DynamoDB client for the configured endpoint, if any,
+// and region, using the credentials found by the AWS SDK. The provided options
+// are applied after the endpoint is set.
+func (d Database) DynamoClient(ctx context.Context, optFns ...func(*dynamodb.Options)) (*dynamodb.Client, error) {
+	var opts []func(*config.LoadOptions) error
+	if d.AWSRegion != "" {
+		opts = append(opts, config.WithRegion(d.AWSRegion))
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("[in configuration.Database.DynamoClient] failed to load aws configuration: %w", err)
+	}
+
+	if d.DynamoEndpoint != "" {
+		optFns = append([]func(*dynamodb.Options){func(options *dynamodb.Options) {
+			options.BaseEndpoint = aws.String(d.DynamoEndpoint)
+		}}, optFns...)
+	}
+	return dynamodb.NewFromConfig(awsCfg, optFns...), nil
+}
+
+// RetryPolicy returns the policy requests to DynamoDB are retried with.
+func (d Database) RetryPolicy() retry.Policy {
+	return retry.Policy{
+		MaxAttempts: d.DynamoMaxAttempts,
+		BaseDelay:   d.DynamoRetryBaseDelay,
+		MaxDelay:    d.DynamoRetryMaxDelay,
+		Timeout:     d.DynamoTimeout,
+	}
+}
+
+// RetryClient returns a client for DynamoDB, like DynamoClient, whose requests
+// are retried with RetryPolicy rather than by the AWS SDK, as the server's
+// are. Retries are logged with the provided logger. It is meant for tools,
+// such as cmd/migrate and cmd/seed, that do not record metrics.
+func (d Database) RetryClient(ctx context.Context, logger *slog.Logger) (*retry.Client, error) {
+	client, err := d.DynamoClient(ctx, func(options *dynamodb.Options) {
+		options.RetryMaxAttempts = 1
+	})
+	if err != nil {
+		return nil, err
+	}
+	return retry.NewClient(client, d.RetryPolicy(), logger, nil), nil
+}
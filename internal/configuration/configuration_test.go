@@ -0,0 +1,53 @@
+package configuration
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_SigningKeys(t *testing.T) {
+	validKey := strings.Repeat("k", minSigningKeyLength)
+	shortKey := strings.Repeat("k", minSigningKeyLength-1)
+
+	tests := map[string]struct {
+		jwtKey    string
+		cursorKey string
+		wantErr   string
+	}{
+		"long enough": {
+			jwtKey:    validKey,
+			cursorKey: validKey,
+		},
+		"short JWT key": {
+			jwtKey:    shortKey,
+			cursorKey: validKey,
+			wantErr:   "JWT_SIGNING_KEY must be at least 32 bytes long, got 31",
+		},
+		"short cursor key": {
+			jwtKey:    validKey,
+			cursorKey: "change-me",
+			wantErr:   "CURSOR_SIGNING_KEY must be at least 32 bytes long, got 9",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("HOST", "localhost")
+			t.Setenv("PORT", "8080")
+			t.Setenv("LOG_LEVEL", "INFO")
+			t.Setenv("SHUTDOWN_TIMEOUT", "5")
+			t.Setenv("JWT_SIGNING_KEY", tc.jwtKey)
+			t.Setenv("CURSOR_SIGNING_KEY", tc.cursorKey)
+
+			cfg, err := New()
+
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr, "error did not match")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.jwtKey, cfg.JWTSigningKey, "JWT key mismatch")
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/agallagher-captech/blog/internal/services"
+)
+
+// loginRequest represents the input model for logging in.
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Valid checks the loginRequest for any problems.
+func (r loginRequest) Valid(ctx context.Context) map[string]string {
+	problems := make(map[string]string)
+	if strings.TrimSpace(r.Email) == "" {
+		problems["email"] = "email is required"
+	}
+	if r.Password == "" {
+		problems["password"] = "password is required"
+	}
+
+	return problems
+}
+
+// refreshTokenRequest represents the input model for refreshing or revoking a
+// refresh token.
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Valid checks the refreshTokenRequest for any problems.
+func (r refreshTokenRequest) Valid(ctx context.Context) map[string]string {
+	problems := make(map[string]string)
+	if strings.TrimSpace(r.RefreshToken) == "" {
+		problems["refresh_token"] = "refresh_token is required"
+	}
+
+	return problems
+}
+
+// authenticator represents a type capable of logging a user in and issuing
+// tokens.
+type authenticator interface {
+	Login(ctx context.Context, email, password string) (services.Tokens, error)
+}
+
+// tokenRefresher represents a type capable of rotating a refresh token.
+type tokenRefresher interface {
+	Refresh(ctx context.Context, refreshToken string) (services.Tokens, error)
+}
+
+// tokenRevoker represents a type capable of revoking a refresh token.
+type tokenRevoker interface {
+	Logout(ctx context.Context, refreshToken string) error
+}
+
+// HandleLogin returns an http.Handler that exchanges a user's credentials for
+// an access and refresh token.
+//
+//	@Summary		Login
+//	@Description	Exchange email and password for an access and refresh token
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request		body		loginRequest	true	"Login request"
+//	@Success		200			{object}	tokenResponse
+//	@Failure		400			{object}	problem.Details	"Validation error(s)"
+//	@Failure		401			{object}	problem.Details	"Invalid credentials"
+//	@Failure		500			{object}	problem.Details	"Internal server error"
+//	@Router			/auth/login	[POST]
+func HandleLogin(logger *slog.Logger, authenticator authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger.InfoContext(ctx, "handling login request")
+
+		// decode and validate request
+		req, problems, err := decodeValid[loginRequest](ctx, r)
+		if err != nil {
+			logger.ErrorContext(ctx, "invalid login request", slog.String("error", err.Error()))
+			writeDecodeError(w, r, problems, err)
+			return
+		}
+
+		tokens, err := authenticator.Login(ctx, req.Email, req.Password)
+		if err != nil {
+			writeError(ctx, logger, w, r, "failed to login", err)
+			return
+		}
+
+		writeTokens(ctx, logger, w, tokens)
+	})
+}
+
+// HandleRefresh returns an http.Handler that rotates a refresh token, issuing
+// a new access and refresh token in its place.
+//
+//	@Summary		Refresh Tokens
+//	@Description	Exchange a refresh token for a new access and refresh token
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request			body		refreshTokenRequest	true	"Refresh request"
+//	@Success		200				{object}	tokenResponse
+//	@Failure		400				{object}	problem.Details	"Validation error(s)"
+//	@Failure		401				{object}	problem.Details	"Invalid token"
+//	@Failure		500				{object}	problem.Details	"Internal server error"
+//	@Router			/auth/refresh	[POST]
+func HandleRefresh(logger *slog.Logger, tokenRefresher tokenRefresher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger.InfoContext(ctx, "handling refresh request")
+
+		// decode and validate request
+		req, problems, err := decodeValid[refreshTokenRequest](ctx, r)
+		if err != nil {
+			logger.ErrorContext(ctx, "invalid refresh request", slog.String("error", err.Error()))
+			writeDecodeError(w, r, problems, err)
+			return
+		}
+
+		tokens, err := tokenRefresher.Refresh(ctx, req.RefreshToken)
+		if err != nil {
+			writeError(ctx, logger, w, r, "failed to refresh tokens", err)
+			return
+		}
+
+		writeTokens(ctx, logger, w, tokens)
+	})
+}
+
+// HandleLogout returns an http.Handler that revokes a refresh token.
+//
+//	@Summary		Logout
+//	@Description	Revoke a refresh token
+//	@Tags			auth
+//	@Accept			json
+//	@Param			request	body	refreshTokenRequest	true	"Logout request"
+//	@Success		204
+//	@Failure		400				{object}	problem.Details	"Validation error(s)"
+//	@Failure		401				{object}	problem.Details	"Invalid token"
+//	@Failure		500				{object}	problem.Details	"Internal server error"
+//	@Router			/auth/logout	[POST]
+func HandleLogout(logger *slog.Logger, tokenRevoker tokenRevoker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger.InfoContext(ctx, "handling logout request")
+
+		// decode and validate request
+		req, problems, err := decodeValid[refreshTokenRequest](ctx, r)
+		if err != nil {
+			logger.ErrorContext(ctx, "invalid logout request", slog.String("error", err.Error()))
+			writeDecodeError(w, r, problems, err)
+			return
+		}
+
+		if err = tokenRevoker.Logout(ctx, req.RefreshToken); err != nil {
+			writeError(ctx, logger, w, r, "failed to logout", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// writeTokens writes the provided tokens as a 200 JSON response.
+func writeTokens(ctx context.Context, logger *slog.Logger, w http.ResponseWriter, tokens services.Tokens) {
+	response := tokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(tokens.ExpiresIn.Seconds()),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(ctx, "failed to encode response", slog.String("error", err.Error()))
+	}
+}
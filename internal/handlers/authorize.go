@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/agallagher-captech/blog/internal/middleware"
+	"github.com/agallagher-captech/blog/internal/problem"
+	"github.com/google/uuid"
+)
+
+// authorizeOwner checks that the authenticated caller is the user with the
+// provided ownerID. A 401 is written if the request is not authenticated and a
+// 403 if the caller is someone else, in which case false is returned and the
+// handler should stop.
+func authorizeOwner(logger *slog.Logger, w http.ResponseWriter, r *http.Request, ownerID uuid.UUID) bool {
+	ctx := r.Context()
+
+	callerID, ok := authenticatedCaller(logger, w, r)
+	if !ok {
+		return false
+	}
+
+	if callerID != ownerID {
+		logger.ErrorContext(
+			ctx,
+			"caller does not own resource",
+			slog.String("caller_id", callerID.String()),
+			slog.String("owner_id", ownerID.String()),
+		)
+		problem.Write(w, r, http.StatusForbidden, "only the owner may modify this resource")
+		return false
+	}
+
+	return true
+}
+
+// authenticatedCaller returns the id of the authenticated caller, for
+// handlers whose storage write checks ownership itself. A 401 is written if
+// the request is not authenticated, in which case false is returned and the
+// handler should stop.
+func authenticatedCaller(logger *slog.Logger, w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	ctx := r.Context()
+
+	callerID, ok := middleware.UserID(ctx)
+	if !ok {
+		logger.ErrorContext(ctx, "request is not authenticated")
+		problem.Write(w, r, http.StatusUnauthorized, "authentication is required")
+		return uuid.Nil, false
+	}
+	return callerID, true
+}
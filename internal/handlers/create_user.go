@@ -3,14 +3,12 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"log/slog"
 	"net/http"
 	"regexp"
 	"strings"
 
 	"github.com/agallagher-captech/blog/internal/models"
-	"github.com/agallagher-captech/blog/internal/services"
 	"github.com/google/uuid"
 )
 
@@ -36,11 +34,16 @@ func (r createUserRequest) Valid(ctx context.Context) map[string]string {
 	}
 	if len(r.Password) < 8 {
 		problems["password"] = "password must be at least 8 characters"
+	} else if len(r.Password) > maxPasswordBytes {
+		problems["password"] = "password must be at most 72 bytes"
 	}
 
 	return problems
 }
 
+// maxPasswordBytes is the longest password bcrypt is able to hash.
+const maxPasswordBytes = 72
+
 func isValidEmail(email string) bool {
 	// A simple regex for email validation
 	const emailRegex = `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`
@@ -62,10 +65,10 @@ type userCreator interface {
 //	@Produce		json
 //	@Param			request	body		createUserRequest	true	"User creation request"
 //	@Success		201		{object}	userResponse
-//	@Failure		400		{object}	map[string]string	"Validation error(s)"
-//	@Failure		409		{object}	string				"User already exists"
-//	@Failure		500		{object}	string				"Internal server error"
-//	@Router			/users [post]
+//	@Failure		400		{object}	problem.Details	"Validation error(s)"
+//	@Failure		409		{object}	problem.Details	"User already exists"
+//	@Failure		500		{object}	problem.Details	"Internal server error"
+//	@Router			/users	[POST]
 func HandleCreateUser(logger *slog.Logger, userCreator userCreator) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -75,19 +78,10 @@ func HandleCreateUser(logger *slog.Logger, userCreator userCreator) http.Handler
 		req, problems, err := decodeValid[createUserRequest](ctx, r)
 		if err != nil {
 			logger.ErrorContext(ctx, "invalid create user request", "error", err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			if problems != nil {
-				_ = json.NewEncoder(w).Encode(problems)
-			} else {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-			}
+			writeDecodeError(w, r, problems, err)
 			return
 		}
 
-		// Set the status code to 200 OK
-		w.WriteHeader(http.StatusOK)
-
 		// Get user from request body
 		var user models.User
 		user = models.User{
@@ -97,40 +91,26 @@ func HandleCreateUser(logger *slog.Logger, userCreator userCreator) http.Handler
 			Password: req.Password,
 		}
 
-		user.SK = "PROFILE"
-		user.GSI1PK = "USER"
-		user.GSI1SK = "USER#" + user.ID.String() // GSI1SK must be unique for each user
-
 		// Create the user
 		createdUser, err := userCreator.CreateUser(ctx, user)
 		if err != nil {
-			switch {
-			case errors.Is(err, services.ErrAlreadyExists):
-				logger.ErrorContext(ctx, "user already exists")
-				http.Error(w, "User already exists", http.StatusConflict)
-				return
-			default:
-				logger.ErrorContext(ctx, "failed to create user", slog.String("error", err.Error()))
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-				return
-			}
+			writeError(ctx, logger, w, r, "failed to create user", err)
+			return
 		}
 
 		// Convert our models.User domain model into a response model.
 		response := userResponse{
-			ID:       createdUser.ID.UUID,
-			Name:     createdUser.Name,
-			Email:    createdUser.Email,
-			Password: createdUser.Password, // Note: Password should not be returned in production
+			ID:    createdUser.ID.UUID,
+			Name:  createdUser.Name,
+			Email: createdUser.Email,
 		}
 
 		// Encode the response model as JSON
 		w.Header().Set("Content-Type", "application/json")
+		setETag(w, createdUser.Version)
 		w.WriteHeader(http.StatusCreated) // 201 Created
 		if err := json.NewEncoder(w).Encode(response); err != nil {
 			logger.ErrorContext(ctx, "failed to encode response", slog.String("error", err.Error()))
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
 		}
 	})
 }
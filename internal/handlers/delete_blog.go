@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// blogDeleter represents a type capable of deleting a blog, and every comment
+// left on it, from storage.
+type blogDeleter interface {
+	DeleteBlog(ctx context.Context, id, author uuid.UUID, version int64) error
+}
+
+// HandleDeleteBlog returns an http.Handler that deletes a blog from storage
+// along with its comments. Only the blog's author may delete it, which is
+// checked by the delete itself so the blog cannot change hands in between. If
+// the request has an If-Match header the blog is only deleted if it still
+// matches, otherwise a 412 is returned.
+//
+//	@Summary		Delete Blog
+//	@Description	Delete Blog by ID, including the comments left on it
+//	@Tags			blog
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id			path	string	true	"Blog ID"
+//	@Param			If-Match	header	string	false	"ETag of the blog being deleted"
+//	@Success		204
+//	@Failure		400			{object}	problem.Details
+//	@Failure		401			{object}	problem.Details
+//	@Failure		403			{object}	problem.Details
+//	@Failure		404			{object}	problem.Details
+//	@Failure		412			{object}	problem.Details
+//	@Failure		500			{object}	problem.Details
+//	@Router			/blogs/{id}	[DELETE]
+func HandleDeleteBlog(logger *slog.Logger, blogDeleter blogDeleter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger.InfoContext(ctx, "handling delete blog request")
+
+		idStr := r.PathValue("id")
+
+		// Convert the ID from string to a UUID
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			writeInvalidID(ctx, logger, w, r, idStr, err)
+			return
+		}
+
+		// Only the author may delete their blog, which the delete checks
+		callerID, ok := authenticatedCaller(logger, w, r)
+		if !ok {
+			return
+		}
+
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			writeError(ctx, logger, w, r, "invalid If-Match header", err)
+			return
+		}
+
+		// Delete the blog
+		if err = blogDeleter.DeleteBlog(ctx, id, callerID, version); err != nil {
+			writeError(ctx, logger, w, r, "failed to delete blog", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agallagher-captech/blog/internal/middleware"
+	"github.com/agallagher-captech/blog/internal/services"
+	"github.com/agallagher-captech/blog/internal/services/memory"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleDeleteBlog(t *testing.T) {
+	blogID := "17e16813-c203-0355-1e4c-17c630f114f3"
+	authorID := uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")
+	otherID := uuid.MustParse("1d87067c-f1fd-5516-dbac-104733ba0542")
+
+	tests := map[string]struct {
+		callerID   uuid.UUID
+		id         string
+		ifMatch    string
+		wantStatus int
+		wantExists bool
+	}{
+		"happy path": {
+			callerID:   authorID,
+			id:         blogID,
+			wantStatus: http.StatusNoContent,
+		},
+		"unauthenticated": {
+			callerID:   uuid.Nil,
+			id:         blogID,
+			wantStatus: http.StatusUnauthorized,
+			wantExists: true,
+		},
+		"another user": {
+			callerID:   otherID,
+			id:         blogID,
+			wantStatus: http.StatusForbidden,
+			wantExists: true,
+		},
+		"stale If-Match": {
+			callerID:   authorID,
+			id:         blogID,
+			ifMatch:    `"7"`,
+			wantStatus: http.StatusPreconditionFailed,
+			wantExists: true,
+		},
+		"invalid id": {
+			callerID:   authorID,
+			id:         "not-a-uuid",
+			wantStatus: http.StatusBadRequest,
+			wantExists: true,
+		},
+		"blog not found": {
+			callerID:   authorID,
+			id:         uuid.NewString(),
+			wantStatus: http.StatusNotFound,
+			wantExists: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := memory.NewClient()
+			require.NoError(t, client.LoadDir("../../dynamodb_seed"), "failed to load seed data")
+			blogs := services.NewBlogsService(slog.Default(), client, services.DefaultTable(), services.NewCursors("secret"))
+
+			req := httptest.NewRequest("DELETE", "/api/blogs/"+tc.id, nil)
+			req.SetPathValue("id", tc.id)
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			// uuid.Nil means no authentication at all
+			if tc.callerID != uuid.Nil {
+				req = req.WithContext(middleware.WithUserID(req.Context(), tc.callerID))
+			}
+			rec := httptest.NewRecorder()
+
+			HandleDeleteBlog(slog.Default(), blogs).ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.wantStatus, rec.Code, "status code mismatch")
+			_, err := blogs.ReadBlog(context.TODO(), uuid.MustParse(blogID))
+			if tc.wantExists {
+				assert.NoError(t, err, "blog was deleted")
+			} else {
+				assert.ErrorIs(t, err, services.ErrNotFound, "blog was not deleted")
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// commentDeleter represents a type capable of deleting a comment from storage.
+type commentDeleter interface {
+	DeleteComment(ctx context.Context, blogID, userID uuid.UUID, version int64) error
+}
+
+// HandleDeleteComment returns an http.Handler that deletes the comment a user
+// left on a blog. Only the commenting user may delete it. If the request has
+// an If-Match header the comment is only deleted if it still matches,
+// otherwise a 412 is returned.
+//
+//	@Summary		Delete Comment
+//	@Description	Delete the comment a user left on a blog
+//	@Tags			comment
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			blog_id		path	string	true	"Blog ID"
+//	@Param			user_id		path	string	true	"User ID of the commenter"
+//	@Param			If-Match	header	string	false	"ETag of the comment being deleted"
+//	@Success		204
+//	@Failure		400									{object}	problem.Details
+//	@Failure		401									{object}	problem.Details
+//	@Failure		403									{object}	problem.Details
+//	@Failure		404									{object}	problem.Details
+//	@Failure		412									{object}	problem.Details
+//	@Failure		500									{object}	problem.Details
+//	@Router			/blogs/{blog_id}/comments/{user_id}	[DELETE]
+func HandleDeleteComment(logger *slog.Logger, commentDeleter commentDeleter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger.InfoContext(ctx, "handling delete comment request")
+
+		// Convert the IDs from strings to UUIDs
+		blogIDStr := r.PathValue("blog_id")
+		blogID, err := uuid.Parse(blogIDStr)
+		if err != nil {
+			writeInvalidID(ctx, logger, w, r, blogIDStr, err)
+			return
+		}
+		userIDStr := r.PathValue("user_id")
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			writeInvalidID(ctx, logger, w, r, userIDStr, err)
+			return
+		}
+
+		// Only the commenting user may delete their comment
+		if !authorizeOwner(logger, w, r, userID) {
+			return
+		}
+
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			writeError(ctx, logger, w, r, "invalid If-Match header", err)
+			return
+		}
+
+		// Delete the comment
+		if err = commentDeleter.DeleteComment(ctx, blogID, userID, version); err != nil {
+			writeError(ctx, logger, w, r, "failed to delete comment", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agallagher-captech/blog/internal/middleware"
+	"github.com/agallagher-captech/blog/internal/services"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// commentDeleterFunc adapts a function to the commentDeleter interface.
+type commentDeleterFunc func(ctx context.Context, blogID, userID uuid.UUID, version int64) error
+
+func (f commentDeleterFunc) DeleteComment(ctx context.Context, blogID, userID uuid.UUID, version int64) error {
+	return f(ctx, blogID, userID, version)
+}
+
+func TestHandleDeleteComment(t *testing.T) {
+	blogID := uuid.MustParse("17e16813-c203-0355-1e4c-17c630f114f3")
+	userID := uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")
+	otherID := uuid.MustParse("1d87067c-f1fd-5516-dbac-104733ba0542")
+
+	tests := map[string]struct {
+		callerID    *uuid.UUID
+		userID      string
+		ifMatch     string
+		err         error
+		wantVersion int64
+		wantStatus  int
+		wantDeleted bool
+	}{
+		"happy path": {
+			userID:      userID.String(),
+			wantStatus:  http.StatusNoContent,
+			wantDeleted: true,
+		},
+		"matching If-Match": {
+			userID:      userID.String(),
+			ifMatch:     `"2"`,
+			wantVersion: 2,
+			wantStatus:  http.StatusNoContent,
+			wantDeleted: true,
+		},
+		"unauthenticated": {
+			callerID:   &uuid.Nil,
+			userID:     userID.String(),
+			wantStatus: http.StatusUnauthorized,
+		},
+		"another user": {
+			callerID:   &otherID,
+			userID:     userID.String(),
+			wantStatus: http.StatusForbidden,
+		},
+		"invalid id": {
+			userID:     "not-a-uuid",
+			wantStatus: http.StatusBadRequest,
+		},
+		"comment not found": {
+			userID:      userID.String(),
+			err:         services.ErrNotFound,
+			wantStatus:  http.StatusNotFound,
+			wantDeleted: true,
+		},
+		"service error": {
+			userID:      userID.String(),
+			err:         errors.New("boom"),
+			wantStatus:  http.StatusInternalServerError,
+			wantDeleted: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			deleted := false
+			deleter := commentDeleterFunc(func(ctx context.Context, gotBlogID, gotUserID uuid.UUID, version int64) error {
+				deleted = true
+				assert.Equal(t, blogID, gotBlogID, "blog id mismatch")
+				assert.Equal(t, userID, gotUserID, "user id mismatch")
+				assert.Equal(t, tc.wantVersion, version, "expected version mismatch")
+				return tc.err
+			})
+
+			req := httptest.NewRequest("DELETE", "/api/blogs/"+blogID.String()+"/comments/"+tc.userID, nil)
+			req.SetPathValue("blog_id", blogID.String())
+			req.SetPathValue("user_id", tc.userID)
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+
+			// Authenticate as the commenting user unless the test case says
+			// otherwise. uuid.Nil means no authentication at all.
+			callerID := userID
+			if tc.callerID != nil {
+				callerID = *tc.callerID
+			}
+			if callerID != uuid.Nil {
+				req = req.WithContext(middleware.WithUserID(req.Context(), callerID))
+			}
+			rec := httptest.NewRecorder()
+
+			HandleDeleteComment(slog.Default(), deleter).ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.wantStatus, rec.Code, "status code mismatch")
+			assert.Equal(t, tc.wantDeleted, deleted, "comment deleter called unexpectedly")
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// userDeleter represents a type capable of deleting a user, and everything the
+// user owns, from storage.
+type userDeleter interface {
+	DeleteUser(ctx context.Context, id uuid.UUID, version int64) (int, error)
+}
+
+// HandleDeleteUser returns an http.Handler that deletes a user from storage
+// along with their blogs and comments. If the request has an If-Match header
+// the user is only deleted if it still matches, otherwise a 412 is returned.
+//
+//	@Summary		Delete User
+//	@Description	Delete User by ID, including the user's blogs and comments
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id			path		string	true	"User ID"
+//	@Param			If-Match	header		string	false	"ETag of the user being deleted"
+//	@Success		200			{object}	deleteResponse
+//	@Failure		400			{object}	problem.Details
+//	@Failure		401			{object}	problem.Details
+//	@Failure		403			{object}	problem.Details
+//	@Failure		404			{object}	problem.Details
+//	@Failure		412			{object}	problem.Details
+//	@Failure		500			{object}	problem.Details
+//	@Router			/users/{id}	[DELETE]
+func HandleDeleteUser(logger *slog.Logger, userDeleter userDeleter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger.InfoContext(ctx, "handling delete user request")
+
+		idStr := r.PathValue("id")
+
+		// Convert the ID from string to a UUID
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			writeInvalidID(ctx, logger, w, r, idStr, err)
+			return
+		}
+
+		// Only the user themselves may delete their profile
+		if !authorizeOwner(logger, w, r, id) {
+			return
+		}
+
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			writeError(ctx, logger, w, r, "invalid If-Match header", err)
+			return
+		}
+
+		// Delete the user
+		deleted, err := userDeleter.DeleteUser(ctx, id, version)
+		if err != nil {
+			writeError(ctx, logger, w, r, "failed to delete user", err)
+			return
+		}
+
+		response := deleteResponse{
+			Message: "User deleted",
+			Deleted: deleted,
+		}
+
+		// Encode the response model as JSON
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(response); err != nil {
+			logger.ErrorContext(
+				ctx,
+				"failed to encode response",
+				slog.String("error", err.Error()),
+			)
+		}
+	})
+}
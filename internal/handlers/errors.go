@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/agallagher-captech/blog/internal/problem"
+	"github.com/agallagher-captech/blog/internal/services"
+)
+
+// serviceErrors maps the errors returned by services that are not a
+// *services.Error, along with the request errors handlers share, to the status
+// of the response describing them. The error's own message is safe to show to
+// clients and is used as the problem detail.
+var serviceErrors = []struct {
+	err    error
+	status int
+}{
+	{services.ErrNotFound, http.StatusNotFound},
+	{services.ErrAlreadyExists, http.StatusConflict},
+	{services.ErrInvalidCredentials, http.StatusUnauthorized},
+	{services.ErrInvalidToken, http.StatusUnauthorized},
+	{services.ErrInvalidCursor, http.StatusBadRequest},
+	{errInvalidIfMatch, http.StatusPreconditionFailed},
+}
+
+// describeError returns the status code and client-facing detail of the
+// response describing err. Unclassified errors are reported as a 500 without
+// any detail, so internals are never leaked.
+func describeError(err error) (int, string) {
+	var serviceErr *services.Error
+	if errors.As(err, &serviceErr) {
+		return describeServiceError(serviceErr)
+	}
+
+	for _, known := range serviceErrors {
+		if errors.Is(err, known.err) {
+			return known.status, known.err.Error()
+		}
+	}
+
+	return http.StatusInternalServerError, ""
+}
+
+// describeServiceError returns the status code and client-facing detail of the
+// response describing a failed service operation, based on the kind of the
+// failure and the entity it concerned.
+func describeServiceError(err *services.Error) (int, string) {
+	switch err.Kind {
+	case services.KindNotFound:
+		return http.StatusNotFound, err.Entity + " not found"
+	case services.KindForbidden:
+		return http.StatusForbidden, "only the owner may modify this " + err.Entity
+	case services.KindUnauthenticated:
+		return http.StatusUnauthorized, services.ErrInvalidToken.Error()
+	case services.KindConflict:
+		if errors.Is(err, services.ErrVersionMismatch) {
+			return http.StatusPreconditionFailed, err.Entity + " does not match If-Match, fetch it again"
+		}
+		if errors.Is(err, services.ErrAlreadyExists) {
+			return http.StatusConflict, err.Entity + " already exists"
+		}
+		return http.StatusConflict, err.Entity + " was modified concurrently"
+	case services.KindValidation:
+		return http.StatusBadRequest, err.Entity + " was rejected by storage"
+	case services.KindThrottled:
+		return http.StatusServiceUnavailable, "too many requests, try again later"
+	case services.KindUnavailable:
+		return http.StatusServiceUnavailable, "storage is temporarily unavailable"
+	default:
+		return http.StatusInternalServerError, ""
+	}
+}
+
+// writeError logs err and writes the problem details response describing it.
+// msg says what the handler was doing when err occurred.
+func writeError(ctx context.Context, logger *slog.Logger, w http.ResponseWriter, r *http.Request, msg string, err error) {
+	status, detail := describeError(err)
+
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	logger.Log(ctx, level, msg, slog.Int("status", status), slog.String("error", err.Error()))
+
+	// Throttling and outages are usually brief, so invite the client to retry
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+
+	problem.Write(w, r, status, detail)
+}
+
+// writeDecodeError writes the 400 response for a request body that could not
+// be decoded or failed validation, or the 415 response for a PATCH body that
+// is not a merge patch. Validation problems are reported field by field in the
+// errors member of the problem.
+func writeDecodeError(w http.ResponseWriter, r *http.Request, problems map[string]string, err error) {
+	if errors.Is(err, errUnsupportedMediaType) {
+		w.Header().Set("Accept-Patch", mergePatchContentType)
+		problem.Write(w, r, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	if problems == nil {
+		problem.Write(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	details := problem.New(r, http.StatusBadRequest, "request body failed validation")
+	details.Errors = problems
+	_ = details.Write(w)
+}
+
+// writeInvalidID writes the 400 response for a malformed id in the URL.
+func writeInvalidID(ctx context.Context, logger *slog.Logger, w http.ResponseWriter, r *http.Request, idStr string, err error) {
+	logger.InfoContext(
+		ctx,
+		"failed to parse id from url",
+		slog.String("id", idStr),
+		slog.String("error", err.Error()),
+	)
+
+	problem.Write(w, r, http.StatusBadRequest, "invalid id")
+}
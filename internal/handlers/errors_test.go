@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agallagher-captech/blog/internal/problem"
+	"github.com/agallagher-captech/blog/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := map[string]struct {
+		err            error
+		wantStatus     int
+		wantBody       string
+		wantRetryAfter bool
+	}{
+		"not found": {
+			err: &services.Error{
+				Kind:   services.KindNotFound,
+				Op:     "UsersService.ReadUser",
+				Entity: "user",
+				Err:    services.ErrNotFound,
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"type":"about:blank","title":"Not Found","status":404,"detail":"user not found","instance":"/api/users/1"}`,
+		},
+		"already exists": {
+			err: &services.Error{
+				Kind:   services.KindConflict,
+				Op:     "UsersService.UpdateUser",
+				Entity: "email",
+				Err:    services.ErrAlreadyExists,
+			},
+			wantStatus: http.StatusConflict,
+			wantBody:   `{"type":"about:blank","title":"Conflict","status":409,"detail":"email already exists","instance":"/api/users/1"}`,
+		},
+		"forbidden": {
+			err: &services.Error{
+				Kind:   services.KindForbidden,
+				Op:     "BlogsService.DeleteBlog",
+				Entity: "blog",
+				Err:    services.ErrForbidden,
+			},
+			wantStatus: http.StatusForbidden,
+			wantBody:   `{"type":"about:blank","title":"Forbidden","status":403,"detail":"only the owner may modify this blog","instance":"/api/users/1"}`,
+		},
+		"revoked token": {
+			err: &services.Error{
+				Kind:   services.KindUnauthenticated,
+				Op:     "AuthService.Refresh",
+				Entity: "session",
+				Err:    services.ErrInvalidToken,
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"type":"about:blank","title":"Unauthorized","status":401,"detail":"invalid token","instance":"/api/users/1"}`,
+		},
+		"throttled": {
+			err: &services.Error{
+				Kind:   services.KindThrottled,
+				Op:     "UsersService.ReadUser",
+				Entity: "user",
+				Err:    errors.New("ProvisionedThroughputExceededException"),
+			},
+			wantStatus:     http.StatusServiceUnavailable,
+			wantRetryAfter: true,
+		},
+		"invalid cursor": {
+			err:        services.ErrInvalidCursor,
+			wantStatus: http.StatusBadRequest,
+		},
+		"unknown error": {
+			err:        errors.New("connection refused to 10.0.0.1"),
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/api/users/1"}`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/users/1", nil)
+			rec := httptest.NewRecorder()
+
+			writeError(req.Context(), slog.Default(), rec, req, "failed", tc.err)
+
+			assert.Equal(t, tc.wantStatus, rec.Code, "status code mismatch")
+			assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"), "content type mismatch")
+			assert.Equal(t, tc.wantRetryAfter, rec.Header().Get("Retry-After") != "", "Retry-After mismatch")
+			if tc.wantBody != "" {
+				assert.JSONEq(t, tc.wantBody, strings.Trim(rec.Body.String(), "\n"), "body mismatch")
+			}
+		})
+	}
+}
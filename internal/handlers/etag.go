@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errInvalidIfMatch is returned by ifMatchVersion for an If-Match header that
+// cannot match any ETag issued by this API.
+var errInvalidIfMatch = errors.New("If-Match does not name a current entity tag")
+
+// etag returns the strong entity tag of a resource at the provided version.
+func etag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// setETag sets the ETag response header for a resource at the provided
+// version, so the client can send it back in If-Match.
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", etag(version))
+}
+
+// ifMatchVersion returns the version named by the request's If-Match header.
+// A missing header or "*" returns 0, meaning any version is acceptable, so
+// "0" itself is rejected: every item is at version 1 or later once the
+// backfill-versions migration has been applied. Weak tags never match since
+// If-Match uses strong comparison, and lists of tags are not supported, so
+// both return errInvalidIfMatch.
+func ifMatchVersion(r *http.Request) (int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	tag, ok := strings.CutPrefix(header, `"`)
+	if !ok {
+		return 0, errInvalidIfMatch
+	}
+	tag, ok = strings.CutSuffix(tag, `"`)
+	if !ok {
+		return 0, errInvalidIfMatch
+	}
+
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || version < 1 {
+		return 0, errInvalidIfMatch
+	}
+
+	return version, nil
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/agallagher-captech/blog/internal/problem"
+	"github.com/agallagher-captech/blog/internal/services"
+)
+
+// usersLister represents a type capable of listing users from storage.
+type usersLister interface {
+	ListUsers(ctx context.Context, page services.Page) ([]models.User, string, error)
+}
+
+// HandleListUsers returns an http.Handler that lists a page of users in
+// storage.
+//
+//	@Summary		List Users
+//	@Description	List users a page at a time
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int		false	"Page size (1-100, default 25)"
+//	@Param			cursor	query		string	false	"Cursor of the page to fetch, from next_cursor"
+//	@Success		200		{object}	pageResponse[userResponse]
+//	@Failure		400		{object}	problem.Details
+//	@Failure		500		{object}	problem.Details
+//	@Router			/users	[GET]
+func HandleListUsers(logger *slog.Logger, usersLister usersLister) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger.InfoContext(ctx, "handling list users request")
+
+		page, err := parsePage(r)
+		if err != nil {
+			logger.InfoContext(ctx, "invalid page", slog.String("error", err.Error()))
+			problem.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// List the users
+		users, next, err := usersLister.ListUsers(ctx, page)
+		if err != nil {
+			writeError(ctx, logger, w, r, "failed to list users", err)
+			return
+		}
+
+		// Convert our models.User domain models into response models.
+		response := pageResponse[userResponse]{
+			Items:      make([]userResponse, 0, len(users)),
+			NextCursor: next,
+		}
+		for _, user := range users {
+			response.Items = append(response.Items, userResponse{
+				ID:    user.ID.UUID,
+				Name:  user.Name,
+				Email: user.Email,
+			})
+		}
+
+		// Encode the response model as JSON
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(response); err != nil {
+			logger.ErrorContext(
+				ctx,
+				"failed to encode response",
+				slog.String("error", err.Error()),
+			)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/agallagher-captech/blog/internal/services"
+)
+
+// mergePatchContentType is the media type of a JSON merge patch, RFC 7396.
+const mergePatchContentType = "application/merge-patch+json"
+
+// errUnsupportedMediaType is returned by decodeMergePatch for a request body
+// that is not a JSON merge patch.
+var errUnsupportedMediaType = errors.New("PATCH requests must be sent as " + mergePatchContentType)
+
+// patchField describes how a member of a merge patch document maps onto an
+// attribute of the stored item.
+type patchField struct {
+	// attribute is the DynamoDB attribute the member updates.
+	attribute string
+
+	// removable reports whether the member may be null, which removes the
+	// attribute from the item.
+	removable bool
+
+	// decode decodes and validates the member's value. It returns the value
+	// to store, or a problem describing why the value is invalid.
+	decode func(raw json.RawMessage) (any, string)
+}
+
+// decodeMergePatch decodes the JSON merge patch in the request body into a
+// services.Patch, using fields to map each member onto an attribute. Members
+// set to null remove their attribute, and all other members set it. Unknown
+// members, invalid values and nulls for attributes that cannot be removed are
+// reported as problems keyed by member name.
+func decodeMergePatch(r *http.Request, fields map[string]patchField) (services.Patch, map[string]string, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != mergePatchContentType {
+		return services.Patch{}, nil, errUnsupportedMediaType
+	}
+
+	var doc map[string]json.RawMessage
+	if err = json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		return services.Patch{}, nil, fmt.Errorf("decode json: %w", err)
+	}
+	if doc == nil {
+		return services.Patch{}, nil, errors.New("merge patch must be a JSON object")
+	}
+
+	patch := services.Patch{Set: make(map[string]any)}
+	problems := make(map[string]string)
+	for member, raw := range doc {
+		field, ok := fields[member]
+		if !ok {
+			problems[member] = member + " cannot be patched"
+			continue
+		}
+
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			if !field.removable {
+				problems[member] = member + " cannot be removed"
+				continue
+			}
+			patch.Remove = append(patch.Remove, field.attribute)
+			continue
+		}
+
+		value, problem := field.decode(raw)
+		if problem != "" {
+			problems[member] = problem
+			continue
+		}
+		patch.Set[field.attribute] = value
+	}
+	if len(doc) == 0 {
+		problems["request"] = "patch must change at least one field"
+	}
+
+	if len(problems) > 0 {
+		return services.Patch{}, problems, fmt.Errorf("invalid merge patch: %d problems", len(problems))
+	}
+
+	return patch, nil, nil
+}
+
+// stringMember returns a patchField decoder for a string member. validate
+// returns a problem with the value, or an empty string if it is valid.
+func stringMember(name string, validate func(string) string) func(json.RawMessage) (any, string) {
+	return func(raw json.RawMessage) (any, string) {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, name + " must be a string"
+		}
+		if problem := validate(value); problem != "" {
+			return nil, problem
+		}
+		return value, ""
+	}
+}
+
+// numberMember returns a patchField decoder for a number member. validate
+// returns a problem with the value, or an empty string if it is valid.
+func numberMember(name string, validate func(float64) string) func(json.RawMessage) (any, string) {
+	return func(raw json.RawMessage) (any, string) {
+		var value float64
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, name + " must be a number"
+		}
+		if problem := validate(value); problem != "" {
+			return nil, problem
+		}
+		return value, ""
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agallagher-captech/blog/internal/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeMergePatch(t *testing.T) {
+	tests := map[string]struct {
+		contentType  string
+		body         string
+		wantPatch    services.Patch
+		wantProblems map[string]string
+		wantErr      error
+	}{
+		"set and remove": {
+			contentType: mergePatchContentType,
+			body:        `{"title":"Kitchen Decor Ideas","score":null}`,
+			wantPatch: services.Patch{
+				Set:    map[string]any{"title": "Kitchen Decor Ideas"},
+				Remove: []string{"score"},
+			},
+		},
+		"media type parameters": {
+			contentType: mergePatchContentType + "; charset=utf-8",
+			body:        `{"score":7.5}`,
+			wantPatch: services.Patch{
+				Set: map[string]any{"score": 7.5},
+			},
+		},
+		"plain json": {
+			contentType: "application/json",
+			body:        `{"title":"Kitchen Decor Ideas"}`,
+			wantErr:     errUnsupportedMediaType,
+		},
+		"invalid members": {
+			contentType: mergePatchContentType,
+			body:        `{"title":null,"score":"high","user_id":"d2eddb69-f92f-694d-450d-e7cdb6decce3"}`,
+			wantProblems: map[string]string{
+				"title":   "title cannot be removed",
+				"score":   "score must be a number",
+				"user_id": "user_id cannot be patched",
+			},
+		},
+		"empty title": {
+			contentType: mergePatchContentType,
+			body:        `{"title":"  "}`,
+			wantProblems: map[string]string{
+				"title": "title must not be empty",
+			},
+		},
+		"empty patch": {
+			contentType: mergePatchContentType,
+			body:        `{}`,
+			wantProblems: map[string]string{
+				"request": "patch must change at least one field",
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/api/blogs/1", strings.NewReader(tc.body))
+			r.Header.Set("Content-Type", tc.contentType)
+
+			patch, problems, err := decodeMergePatch(r, blogPatchFields)
+
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr, "error did not match")
+				return
+			}
+			if tc.wantProblems != nil {
+				assert.Error(t, err, "expected an invalid patch")
+				assert.Equal(t, tc.wantProblems, problems, "problems did not match")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.wantPatch, patch, "patch did not match")
+		})
+	}
+}
+
+func TestWriteDecodeError_UnsupportedMediaType(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPatch, "/api/users/1", nil)
+	w := httptest.NewRecorder()
+
+	writeDecodeError(w, r, nil, errUnsupportedMediaType)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code, "status did not match")
+	assert.Equal(t, mergePatchContentType, w.Header().Get("Accept-Patch"), "Accept-Patch did not match")
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/agallagher-captech/blog/internal/services"
+)
+
+// parsePage reads the optional `limit` and `cursor` query parameters of a list
+// request.
+func parsePage(r *http.Request) (services.Page, error) {
+	query := r.URL.Query()
+	page := services.Page{Cursor: query.Get("cursor")}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > services.MaxPageLimit {
+			return services.Page{}, fmt.Errorf("limit must be a number between 1 and %d", services.MaxPageLimit)
+		}
+		page.Limit = n
+	}
+
+	return page, nil
+}
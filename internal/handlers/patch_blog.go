@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/agallagher-captech/blog/internal/services"
+	"github.com/google/uuid"
+)
+
+// blogPatchFields are the members of a blog merge patch. A blog's score can be
+// removed, its title cannot.
+var blogPatchFields = map[string]patchField{
+	"title": {
+		attribute: "title",
+		decode: stringMember("title", func(title string) string {
+			if strings.TrimSpace(title) == "" {
+				return "title must not be empty"
+			}
+			return ""
+		}),
+	},
+	"score": {
+		attribute: "score",
+		removable: true,
+		decode: numberMember("score", func(float64) string {
+			return ""
+		}),
+	},
+}
+
+// blogPatcher represents a type capable of partially updating a blog in
+// storage on behalf of its author.
+type blogPatcher interface {
+	PatchBlog(ctx context.Context, id, author uuid.UUID, version int64, patch services.Patch) (models.Blog, error)
+}
+
+// HandlePatchBlog returns an http.Handler that applies a JSON merge patch to a
+// blog in storage. Only the blog's author may patch it, which is checked by
+// the update itself so the blog cannot change hands in between. If the request
+// has an If-Match header the blog is only patched if it still matches,
+// otherwise a 412 is returned.
+//
+//	@Summary		Patch Blog
+//	@Description	Partially update Blog by ID with a JSON merge patch
+//	@Tags			blog
+//	@Accept			application/merge-patch+json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id			path		string			true	"Blog ID"
+//	@Param			If-Match	header		string			false	"ETag of the blog being patched"
+//	@Param			request		body		blogResponse	true	"Blog merge patch, title and score only"
+//	@Success		200			{object}	blogResponse
+//	@Header			200			{string}	ETag			"Version of the blog, for If-Match"
+//	@Failure		400			{object}	problem.Details	"Validation error(s)"
+//	@Failure		401			{object}	problem.Details
+//	@Failure		403			{object}	problem.Details
+//	@Failure		404			{object}	problem.Details
+//	@Failure		412			{object}	problem.Details	"Blog does not match If-Match"
+//	@Failure		415			{object}	problem.Details	"Body is not a merge patch"
+//	@Failure		500			{object}	problem.Details
+//	@Router			/blogs/{id}	[PATCH]
+func HandlePatchBlog(logger *slog.Logger, blogPatcher blogPatcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger.InfoContext(ctx, "handling patch blog request")
+
+		idStr := r.PathValue("id")
+
+		// Convert the ID from string to a UUID
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			writeInvalidID(ctx, logger, w, r, idStr, err)
+			return
+		}
+
+		// Only the author may patch their blog, which the update checks
+		callerID, ok := authenticatedCaller(logger, w, r)
+		if !ok {
+			return
+		}
+
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			writeError(ctx, logger, w, r, "invalid If-Match header", err)
+			return
+		}
+
+		// decode and validate the merge patch
+		patch, problems, err := decodeMergePatch(r, blogPatchFields)
+		if err != nil {
+			logger.ErrorContext(ctx, "invalid patch blog request", slog.String("error", err.Error()))
+			writeDecodeError(w, r, problems, err)
+			return
+		}
+
+		// Patch the blog
+		blog, err := blogPatcher.PatchBlog(ctx, id, callerID, version, patch)
+		if err != nil {
+			writeError(ctx, logger, w, r, "failed to patch blog", err)
+			return
+		}
+
+		// Convert our models.Blog domain model into a response model.
+		response := blogResponse{
+			ID:           blog.ID.UUID,
+			UserID:       blog.UserID.UUID,
+			Title:        blog.Title,
+			Score:        blog.Score,
+			CreatedDate:  blog.CreatedDate,
+			CommentCount: blog.CommentCount,
+		}
+
+		// Encode the response model as JSON
+		w.Header().Set("Content-Type", "application/json")
+		setETag(w, blog.Version)
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(response); err != nil {
+			logger.ErrorContext(
+				ctx,
+				"failed to encode response",
+				slog.String("error", err.Error()),
+			)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agallagher-captech/blog/internal/middleware"
+	"github.com/agallagher-captech/blog/internal/services"
+	"github.com/agallagher-captech/blog/internal/services/memory"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePatchBlog(t *testing.T) {
+	blogID := "17e16813-c203-0355-1e4c-17c630f114f3"
+	authorID := uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")
+	otherID := uuid.MustParse("1d87067c-f1fd-5516-dbac-104733ba0542")
+
+	tests := map[string]struct {
+		callerID   uuid.UUID
+		id         string
+		ifMatch    string
+		body       string
+		wantStatus int
+		wantTitle  string
+	}{
+		"happy path": {
+			callerID:   authorID,
+			id:         blogID,
+			body:       `{"title":"Kitchen Decor Ideas"}`,
+			wantStatus: http.StatusOK,
+			wantTitle:  "Kitchen Decor Ideas",
+		},
+		"matching If-Match": {
+			callerID:   authorID,
+			id:         blogID,
+			ifMatch:    `"1"`,
+			body:       `{"title":"Kitchen Decor Ideas"}`,
+			wantStatus: http.StatusOK,
+			wantTitle:  "Kitchen Decor Ideas",
+		},
+		"stale If-Match": {
+			callerID:   authorID,
+			id:         blogID,
+			ifMatch:    `"7"`,
+			body:       `{"title":"Kitchen Decor Ideas"}`,
+			wantStatus: http.StatusPreconditionFailed,
+			wantTitle:  "Home Decor Ideas",
+		},
+		"unauthenticated": {
+			callerID:   uuid.Nil,
+			id:         blogID,
+			body:       `{"title":"Kitchen Decor Ideas"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantTitle:  "Home Decor Ideas",
+		},
+		"another user": {
+			callerID:   otherID,
+			id:         blogID,
+			body:       `{"title":"Kitchen Decor Ideas"}`,
+			wantStatus: http.StatusForbidden,
+			wantTitle:  "Home Decor Ideas",
+		},
+		"another user at a stale version": {
+			callerID:   otherID,
+			id:         blogID,
+			ifMatch:    `"7"`,
+			body:       `{"title":"Kitchen Decor Ideas"}`,
+			wantStatus: http.StatusForbidden,
+			wantTitle:  "Home Decor Ideas",
+		},
+		"blog not found": {
+			callerID:   authorID,
+			id:         uuid.NewString(),
+			body:       `{"title":"Kitchen Decor Ideas"}`,
+			wantStatus: http.StatusNotFound,
+			wantTitle:  "Home Decor Ideas",
+		},
+		"invalid body": {
+			callerID:   authorID,
+			id:         blogID,
+			body:       `{"title":""}`,
+			wantStatus: http.StatusBadRequest,
+			wantTitle:  "Home Decor Ideas",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.TODO()
+			client := memory.NewClient()
+			require.NoError(t, client.LoadDir("../../dynamodb_seed"), "failed to load seed data")
+			blogs := services.NewBlogsService(slog.Default(), client, services.DefaultTable(), services.NewCursors("secret"))
+
+			// Seeded blogs have no version yet, so bring this one to version 1
+			_, err := blogs.PatchBlog(ctx, uuid.MustParse(blogID), authorID, 0, services.Patch{Set: map[string]any{"title": "Home Decor Ideas"}})
+			require.NoError(t, err)
+
+			req := httptest.NewRequest("PATCH", "/api/blogs/"+tc.id, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", mergePatchContentType)
+			req.SetPathValue("id", tc.id)
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			// uuid.Nil means no authentication at all
+			if tc.callerID != uuid.Nil {
+				req = req.WithContext(middleware.WithUserID(req.Context(), tc.callerID))
+			}
+			rec := httptest.NewRecorder()
+
+			HandlePatchBlog(slog.Default(), blogs).ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.wantStatus, rec.Code, "status code mismatch")
+			stored, err := blogs.ReadBlog(ctx, uuid.MustParse(blogID))
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantTitle, stored.Title, "title mismatch")
+		})
+	}
+}
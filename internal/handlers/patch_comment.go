@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/agallagher-captech/blog/internal/services"
+	"github.com/google/uuid"
+)
+
+// commentPatchFields are the members of a comment merge patch.
+var commentPatchFields = map[string]patchField{
+	"message": {
+		attribute: "message",
+		decode: stringMember("message", func(message string) string {
+			if strings.TrimSpace(message) == "" {
+				return "message must not be empty"
+			}
+			return ""
+		}),
+	},
+}
+
+// commentPatcher represents a type capable of partially updating a comment in
+// storage.
+type commentPatcher interface {
+	PatchComment(ctx context.Context, blogID, userID uuid.UUID, version int64, patch services.Patch) (models.Comment, error)
+}
+
+// HandlePatchComment returns an http.Handler that applies a JSON merge patch to
+// the comment a user left on a blog. Only the commenting user may patch it. If
+// the request has an If-Match header the comment is only patched if it still
+// matches, otherwise a 412 is returned.
+//
+//	@Summary		Patch Comment
+//	@Description	Partially update the comment a user left on a blog with a JSON merge patch
+//	@Tags			comment
+//	@Accept			application/merge-patch+json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			blog_id								path		string			true	"Blog ID"
+//	@Param			user_id								path		string			true	"User ID of the commenter"
+//	@Param			If-Match							header		string			false	"ETag of the comment being patched"
+//	@Param			request								body		commentResponse	true	"Comment merge patch, message only"
+//	@Success		200									{object}	commentResponse
+//	@Header			200									{string}	ETag			"Version of the comment, for If-Match"
+//	@Failure		400									{object}	problem.Details	"Validation error(s)"
+//	@Failure		401									{object}	problem.Details
+//	@Failure		403									{object}	problem.Details
+//	@Failure		404									{object}	problem.Details
+//	@Failure		412									{object}	problem.Details	"Comment does not match If-Match"
+//	@Failure		415									{object}	problem.Details	"Body is not a merge patch"
+//	@Failure		500									{object}	problem.Details
+//	@Router			/blogs/{blog_id}/comments/{user_id}	[PATCH]
+func HandlePatchComment(logger *slog.Logger, commentPatcher commentPatcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger.InfoContext(ctx, "handling patch comment request")
+
+		// Convert the IDs from strings to UUIDs
+		blogIDStr := r.PathValue("blog_id")
+		blogID, err := uuid.Parse(blogIDStr)
+		if err != nil {
+			writeInvalidID(ctx, logger, w, r, blogIDStr, err)
+			return
+		}
+		userIDStr := r.PathValue("user_id")
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			writeInvalidID(ctx, logger, w, r, userIDStr, err)
+			return
+		}
+
+		// Only the commenting user may patch their comment
+		if !authorizeOwner(logger, w, r, userID) {
+			return
+		}
+
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			writeError(ctx, logger, w, r, "invalid If-Match header", err)
+			return
+		}
+
+		// decode and validate the merge patch
+		patch, problems, err := decodeMergePatch(r, commentPatchFields)
+		if err != nil {
+			logger.ErrorContext(ctx, "invalid patch comment request", slog.String("error", err.Error()))
+			writeDecodeError(w, r, problems, err)
+			return
+		}
+
+		// Patch the comment
+		comment, err := commentPatcher.PatchComment(ctx, blogID, userID, version, patch)
+		if err != nil {
+			writeError(ctx, logger, w, r, "failed to patch comment", err)
+			return
+		}
+
+		// Convert our models.Comment domain model into a response model.
+		response := commentResponse{
+			BlogID:      comment.BlogID.UUID,
+			UserID:      comment.UserID.UUID,
+			Message:     comment.Message,
+			CreatedDate: comment.CreatedDate,
+		}
+
+		// Encode the response model as JSON
+		w.Header().Set("Content-Type", "application/json")
+		setETag(w, comment.Version)
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(response); err != nil {
+			logger.ErrorContext(
+				ctx,
+				"failed to encode response",
+				slog.String("error", err.Error()),
+			)
+		}
+	})
+}
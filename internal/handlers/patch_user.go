@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/agallagher-captech/blog/internal/services"
+	"github.com/google/uuid"
+)
+
+// userPatchFields are the members of a user merge patch. None of them can be
+// removed, since every user needs a name, email address and password.
+var userPatchFields = map[string]patchField{
+	"name": {
+		attribute: "name",
+		decode: stringMember("name", func(name string) string {
+			if len(strings.TrimSpace(name)) < 2 {
+				return "name must be at least 2 characters"
+			}
+			return ""
+		}),
+	},
+	"email": {
+		attribute: "email",
+		decode: stringMember("email", func(email string) string {
+			if !isValidEmail(email) {
+				return "invalid email format"
+			}
+			return ""
+		}),
+	},
+	"password": {
+		attribute: "password",
+		decode: stringMember("password", func(password string) string {
+			if len(password) < 8 {
+				return "password must be at least 8 characters"
+			}
+			if len(password) > maxPasswordBytes {
+				return "password must be at most 72 bytes"
+			}
+			return ""
+		}),
+	},
+}
+
+// userPatcher represents a type capable of partially updating a user in
+// storage.
+type userPatcher interface {
+	PatchUser(ctx context.Context, id uuid.UUID, version int64, patch services.Patch) (models.User, error)
+}
+
+// HandlePatchUser returns an http.Handler that applies a JSON merge patch to a
+// user in storage. If the request has an If-Match header the user is only
+// patched if it still matches, otherwise a 412 is returned.
+//
+//	@Summary		Patch User
+//	@Description	Partially update User by ID with a JSON merge patch
+//	@Tags			user
+//	@Accept			application/merge-patch+json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id			path		string				true	"User ID"
+//	@Param			If-Match	header		string				false	"ETag of the user being patched"
+//	@Param			request		body		updateUserRequest	true	"User merge patch"
+//	@Success		200			{object}	userResponse
+//	@Header			200			{string}	ETag			"Version of the user, for If-Match"
+//	@Failure		400			{object}	problem.Details	"Validation error(s)"
+//	@Failure		401			{object}	problem.Details
+//	@Failure		403			{object}	problem.Details
+//	@Failure		404			{object}	problem.Details
+//	@Failure		409			{object}	problem.Details	"Email already in use"
+//	@Failure		412			{object}	problem.Details	"User does not match If-Match"
+//	@Failure		415			{object}	problem.Details	"Body is not a merge patch"
+//	@Failure		500			{object}	problem.Details
+//	@Router			/users/{id}	[PATCH]
+func HandlePatchUser(logger *slog.Logger, userPatcher userPatcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger.InfoContext(ctx, "handling patch user request")
+
+		idStr := r.PathValue("id")
+
+		// Convert the ID from string to a UUID
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			writeInvalidID(ctx, logger, w, r, idStr, err)
+			return
+		}
+
+		// Only the user themselves may patch their profile
+		if !authorizeOwner(logger, w, r, id) {
+			return
+		}
+
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			writeError(ctx, logger, w, r, "invalid If-Match header", err)
+			return
+		}
+
+		// decode and validate the merge patch
+		patch, problems, err := decodeMergePatch(r, userPatchFields)
+		if err != nil {
+			logger.ErrorContext(ctx, "invalid patch user request", slog.String("error", err.Error()))
+			writeDecodeError(w, r, problems, err)
+			return
+		}
+
+		// Patch the user
+		user, err := userPatcher.PatchUser(ctx, id, version, patch)
+		if err != nil {
+			writeError(ctx, logger, w, r, "failed to patch user", err)
+			return
+		}
+
+		// Convert our models.User domain model into a response model.
+		response := userResponse{
+			ID:    user.ID.UUID,
+			Name:  user.Name,
+			Email: user.Email,
+		}
+
+		// Encode the response model as JSON
+		w.Header().Set("Content-Type", "application/json")
+		setETag(w, user.Version)
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(response); err != nil {
+			logger.ErrorContext(
+				ctx,
+				"failed to encode response",
+				slog.String("error", err.Error()),
+			)
+		}
+	})
+}
@@ -2,10 +2,50 @@ package handlers
 
 import "github.com/google/uuid"
 
-// userResponse represents the output model for a user.
+// userResponse represents the output model for a user. The user's password
+// hash is deliberately left out.
 type userResponse struct {
-	ID       uuid.UUID `json:"id"`
-	Name     string    `json:"name"`
-	Email    string    `json:"email"`
-	Password string    `json:"password"`
+	ID    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+}
+
+// blogResponse represents the output model for a blog.
+type blogResponse struct {
+	ID           uuid.UUID `json:"id"`
+	UserID       uuid.UUID `json:"user_id"`
+	Title        string    `json:"title"`
+	Score        float64   `json:"score"`
+	CreatedDate  string    `json:"created_date"`
+	CommentCount int64     `json:"comment_count"`
+}
+
+// commentResponse represents the output model for a comment.
+type commentResponse struct {
+	BlogID      uuid.UUID `json:"blog_id"`
+	UserID      uuid.UUID `json:"user_id"`
+	Message     string    `json:"message"`
+	CreatedDate string    `json:"created_date"`
+}
+
+// deleteResponse represents the output model confirming a deletion.
+type deleteResponse struct {
+	Message string `json:"message"`
+	Deleted int    `json:"deleted"`
+}
+
+// tokenResponse represents the output model for an issued pair of tokens.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// pageResponse represents the output model for a single page of a list. The
+// next page is fetched by passing NextCursor as the `cursor` query parameter,
+// and it is left out on the last page.
+type pageResponse[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/google/uuid"
+)
+
+// updateUserRequest represents the input model for updating a user. Fields
+// left empty are not changed.
+type updateUserRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Valid checks the updateUserRequest for any problems.
+func (r updateUserRequest) Valid(ctx context.Context) map[string]string {
+	problems := make(map[string]string)
+	if r.Name == "" && r.Email == "" && r.Password == "" {
+		problems["request"] = "at least one of name, email or password is required"
+	}
+	if r.Name != "" && len(strings.TrimSpace(r.Name)) < 2 {
+		problems["name"] = "name must be at least 2 characters"
+	}
+	if r.Email != "" && !isValidEmail(r.Email) {
+		problems["email"] = "invalid email format"
+	}
+	if r.Password != "" && len(r.Password) < 8 {
+		problems["password"] = "password must be at least 8 characters"
+	} else if len(r.Password) > maxPasswordBytes {
+		problems["password"] = "password must be at most 72 bytes"
+	}
+
+	return problems
+}
+
+// userUpdater represents a type capable of updating a user in storage.
+type userUpdater interface {
+	UpdateUser(ctx context.Context, id uuid.UUID, patch models.User) (models.User, error)
+}
+
+// HandleUpdateUser returns an http.Handler that updates a user in storage. If
+// the request has an If-Match header the user is only updated if it still
+// matches, otherwise a 412 is returned.
+//
+//	@Summary		Update User
+//	@Description	Update User by ID
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id			path		string				true	"User ID"
+//	@Param			If-Match	header		string				false	"ETag of the user being updated"
+//	@Param			request		body		updateUserRequest	true	"User update request"
+//	@Success		200			{object}	userResponse
+//	@Header			200			{string}	ETag			"Version of the user, for If-Match"
+//	@Failure		400			{object}	problem.Details	"Validation error(s)"
+//	@Failure		401			{object}	problem.Details
+//	@Failure		403			{object}	problem.Details
+//	@Failure		404			{object}	problem.Details
+//	@Failure		409			{object}	problem.Details	"Email already in use"
+//	@Failure		412			{object}	problem.Details	"User does not match If-Match"
+//	@Failure		500			{object}	problem.Details
+//	@Router			/users/{id}	[PUT]
+func HandleUpdateUser(logger *slog.Logger, userUpdater userUpdater) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger.InfoContext(ctx, "handling update user request")
+
+		idStr := r.PathValue("id")
+
+		// Convert the ID from string to a UUID
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			writeInvalidID(ctx, logger, w, r, idStr, err)
+			return
+		}
+
+		// Only the user themselves may update their profile
+		if !authorizeOwner(logger, w, r, id) {
+			return
+		}
+
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			writeError(ctx, logger, w, r, "invalid If-Match header", err)
+			return
+		}
+
+		// decode and validate request
+		req, problems, err := decodeValid[updateUserRequest](ctx, r)
+		if err != nil {
+			logger.ErrorContext(ctx, "invalid update user request", slog.String("error", err.Error()))
+			writeDecodeError(w, r, problems, err)
+			return
+		}
+
+		// Update the user
+		user, err := userUpdater.UpdateUser(ctx, id, models.User{
+			DynamoDBBase: models.DynamoDBBase{Version: version},
+			Name:         req.Name,
+			Email:        req.Email,
+			Password:     req.Password,
+		})
+		if err != nil {
+			writeError(ctx, logger, w, r, "failed to update user", err)
+			return
+		}
+
+		// Convert our models.User domain model into a response model.
+		response := userResponse{
+			ID:    user.ID.UUID,
+			Name:  user.Name,
+			Email: user.Email,
+		}
+
+		// Encode the response model as JSON
+		w.Header().Set("Content-Type", "application/json")
+		setETag(w, user.Version)
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(response); err != nil {
+			logger.ErrorContext(
+				ctx,
+				"failed to encode response",
+				slog.String("error", err.Error()),
+			)
+		}
+	})
+}
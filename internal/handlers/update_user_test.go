@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agallagher-captech/blog/internal/middleware"
+	"github.com/agallagher-captech/blog/internal/migrations"
+	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/agallagher-captech/blog/internal/services"
+	"github.com/agallagher-captech/blog/internal/services/memory"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// userUpdaterFunc adapts a function to the userUpdater interface.
+type userUpdaterFunc func(ctx context.Context, id uuid.UUID, patch models.User) (models.User, error)
+
+func (f userUpdaterFunc) UpdateUser(ctx context.Context, id uuid.UUID, patch models.User) (models.User, error) {
+	return f(ctx, id, patch)
+}
+
+func TestHandleUpdateUser(t *testing.T) {
+	id := uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")
+	otherID := uuid.MustParse("1d87067c-f1fd-5516-dbac-104733ba0542")
+
+	tests := map[string]struct {
+		callerID    *uuid.UUID
+		id          string
+		ifMatch     string
+		body        string
+		user        models.User
+		err         error
+		wantVersion int64
+		wantStatus  int
+		wantBody    string
+		wantETag    string
+	}{
+		"happy path": {
+			id:   id.String(),
+			body: `{"name":"Emma Smith"}`,
+			user: models.User{
+				DynamoDBBase: models.DynamoDBBase{Version: 3},
+				ID:           models.UUID{UUID: id},
+				Name:         "Emma Smith",
+				Email:        "emma@example.com",
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":"d2eddb69-f92f-694d-450d-e7cdb6decce3","name":"Emma Smith","email":"emma@example.com"}`,
+			wantETag:   `"3"`,
+		},
+		"matching If-Match": {
+			id:      id.String(),
+			ifMatch: `"2"`,
+			body:    `{"name":"Emma Smith"}`,
+			user: models.User{
+				DynamoDBBase: models.DynamoDBBase{Version: 3},
+			},
+			wantVersion: 2,
+			wantStatus:  http.StatusOK,
+			wantETag:    `"3"`,
+		},
+		"stale If-Match": {
+			id:      id.String(),
+			ifMatch: `"1"`,
+			body:    `{"name":"Emma Smith"}`,
+			err: &services.Error{
+				Kind:   services.KindConflict,
+				Op:     "UsersService.UpdateUser",
+				Entity: "user",
+				Err:    services.ErrVersionMismatch,
+			},
+			wantVersion: 1,
+			wantStatus:  http.StatusPreconditionFailed,
+		},
+		"weak If-Match": {
+			id:         id.String(),
+			ifMatch:    `W/"1"`,
+			body:       `{"name":"Emma Smith"}`,
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		"invalid id": {
+			id:         "not-a-uuid",
+			body:       `{"name":"Emma Smith"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		"invalid body": {
+			id:         id.String(),
+			body:       `{"email":"not-an-email"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"about:blank","title":"Bad Request","status":400,"detail":"request body failed validation","instance":"/api/users/d2eddb69-f92f-694d-450d-e7cdb6decce3","errors":{"email":"invalid email format"}}`,
+		},
+		"unauthenticated": {
+			callerID:   &uuid.Nil,
+			id:         id.String(),
+			body:       `{"name":"Emma Smith"}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		"another user": {
+			callerID:   &otherID,
+			id:         id.String(),
+			body:       `{"name":"Emma Smith"}`,
+			wantStatus: http.StatusForbidden,
+		},
+		"user not found": {
+			id:         id.String(),
+			body:       `{"name":"Emma Smith"}`,
+			err:        services.ErrNotFound,
+			wantStatus: http.StatusNotFound,
+		},
+		"service error": {
+			id:         id.String(),
+			body:       `{"name":"Emma Smith"}`,
+			err:        errors.New("boom"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			updater := userUpdaterFunc(func(ctx context.Context, id uuid.UUID, patch models.User) (models.User, error) {
+				assert.Equal(t, tc.wantVersion, patch.Version, "expected version mismatch")
+				return tc.user, tc.err
+			})
+
+			req := httptest.NewRequest("PUT", "/api/users/"+tc.id, strings.NewReader(tc.body))
+			req.SetPathValue("id", tc.id)
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+
+			// Authenticate as the user being updated unless the test case
+			// says otherwise. uuid.Nil means no authentication at all.
+			callerID := id
+			if tc.callerID != nil {
+				callerID = *tc.callerID
+			}
+			if callerID != uuid.Nil {
+				req = req.WithContext(middleware.WithUserID(req.Context(), callerID))
+			}
+			rec := httptest.NewRecorder()
+
+			HandleUpdateUser(slog.Default(), updater).ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.wantStatus, rec.Code, "status code mismatch")
+			assert.Equal(t, tc.wantETag, rec.Header().Get("ETag"), "ETag mismatch")
+			if tc.wantBody != "" {
+				assert.JSONEq(t, tc.wantBody, strings.Trim(rec.Body.String(), "\n"), "body mismatch")
+			}
+		})
+	}
+}
+
+func TestHandleUpdateUser_SeededETag(t *testing.T) {
+	id := uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")
+	ctx := middleware.WithUserID(context.TODO(), id)
+
+	// Load the seed data and migrate it, as a fresh environment would be
+	client := memory.NewClient()
+	require.NoError(t, client.LoadDir("../../dynamodb_seed"), "failed to load seed data")
+	table := services.DefaultTable()
+	runner, err := migrations.NewRunner(slog.Default(), client, table.Name, migrations.All())
+	require.NoError(t, err)
+	_, err = runner.Up(ctx, 0, false)
+	require.NoError(t, err)
+	users := services.NewUsersService(slog.Default(), client, table, services.NewCursors("secret"))
+
+	// The ETag of a seeded user can be sent back in If-Match
+	req := httptest.NewRequest("GET", "/api/users/"+id.String(), nil).WithContext(ctx)
+	req.SetPathValue("id", id.String())
+	rec := httptest.NewRecorder()
+	HandleReadUser(slog.Default(), users).ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, "status code mismatch")
+	etag := rec.Header().Get("ETag")
+
+	req = httptest.NewRequest("PUT", "/api/users/"+id.String(), strings.NewReader(`{"name":"Emma Smith"}`)).WithContext(ctx)
+	req.SetPathValue("id", id.String())
+	req.Header.Set("If-Match", etag)
+	rec = httptest.NewRecorder()
+	HandleUpdateUser(slog.Default(), users).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code, "status code mismatch")
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"), "ETag did not change")
+
+	// and is stale once the user has been updated
+	req = httptest.NewRequest("PUT", "/api/users/"+id.String(), strings.NewReader(`{"name":"Emma Jones"}`)).WithContext(ctx)
+	req.SetPathValue("id", id.String())
+	req.Header.Set("If-Match", etag)
+	rec = httptest.NewRecorder()
+	HandleUpdateUser(slog.Default(), users).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code, "status code mismatch")
+}
@@ -0,0 +1,45 @@
+package keys
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Entity is a model stored as an item of the table, which declares how the
+// keys of its item are derived from its fields.
+type Entity interface {
+	// Keys returns the keys of the entity's item.
+	Keys() Keys
+
+	// SetKeys stores the keys on the entity, so that they are marshalled
+	// with it.
+	SetKeys(keys Keys)
+}
+
+// Marshal populates the keys of an entity and marshals it into an item. Key
+// attributes that are empty, such as the GSI1 key of an item that is not
+// indexed, are left out of the item.
+func Marshal(entity Entity) (map[string]types.AttributeValue, error) {
+	keys := entity.Keys()
+	entity.SetKeys(keys)
+
+	item, err := attributevalue.MarshalMap(entity)
+	if err != nil {
+		return nil, fmt.Errorf("[in keys.Marshal] failed to marshal %T: %w", entity, err)
+	}
+	for name, value := range map[string]string{
+		"PK":     keys.PK,
+		"SK":     keys.SK,
+		"GSI1PK": keys.GSI1PK,
+		"GSI1SK": keys.GSI1SK,
+	} {
+		if value == "" {
+			delete(item, name)
+			continue
+		}
+		item[name] = &types.AttributeValueMemberS{Value: value}
+	}
+	return item, nil
+}
@@ -0,0 +1,145 @@
+// Package keys derives the storage keys of the single-table design, so that
+// the key layout is declared once instead of being formatted by hand wherever
+// an item is read or written.
+//
+// The table holds these items:
+//
+//	item      PK              SK               GSI1PK   GSI1SK
+//	user      USER#<id>       PROFILE          USER     USER#<id>
+//	blog      BLOG#<id>       METADATA         BLOG     USER#<author id>
+//	comment   BLOG#<blog id>  USER#<user id>   COMMENT  USER#<user id>
+//	email     EMAIL#<email>   EMAIL
+//	session   USER#<id>       SESSION#<token>
+//
+// Entities declare their keys by implementing Entity, and Marshal always
+// writes them with the rest of the item.
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidKey is returned when a key cannot be parsed.
+var ErrInvalidKey = errors.New("invalid key")
+
+// Prefixes of the keys that identify an item by id.
+const (
+	UserPrefix    = "USER#"
+	BlogPrefix    = "BLOG#"
+	EmailPrefix   = "EMAIL#"
+	SessionPrefix = "SESSION#"
+)
+
+// Sort keys of the items that are alone in their partition, or that describe
+// the partition itself.
+const (
+	Profile  = "PROFILE"
+	Metadata = "METADATA"
+	Email    = "EMAIL"
+)
+
+// Partitions of GSI1 that list every item of a type.
+const (
+	Users    = "USER"
+	Blogs    = "BLOG"
+	Comments = "COMMENT"
+)
+
+// Key is the primary key of an item.
+type Key struct {
+	PK string
+	SK string
+}
+
+// AttributeValues returns the key in the form used by GetItem, DeleteItem and
+// the other requests that address a single item.
+func (k Key) AttributeValues() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: k.PK},
+		"SK": &types.AttributeValueMemberS{Value: k.SK},
+	}
+}
+
+// Keys are every key attribute of an item: its primary key and, if the item
+// is listed through GSI1, its GSI1 key.
+type Keys struct {
+	Key
+	GSI1PK string
+	GSI1SK string
+}
+
+// User returns USER#<id>, which identifies a user in the keys of its own
+// items and of the items it owns.
+func User(id uuid.UUID) string {
+	return UserPrefix + id.String()
+}
+
+// Blog returns BLOG#<id>, the partition key of a blog and its comments.
+func Blog(id uuid.UUID) string {
+	return BlogPrefix + id.String()
+}
+
+// UserKey returns the primary key of the profile item of a user.
+func UserKey(id uuid.UUID) Key {
+	return Key{PK: User(id), SK: Profile}
+}
+
+// BlogKey returns the primary key of the metadata item of a blog.
+func BlogKey(id uuid.UUID) Key {
+	return Key{PK: Blog(id), SK: Metadata}
+}
+
+// CommentKey returns the primary key of the comment the user with the
+// provided userID left on the blog with the provided blogID.
+func CommentKey(blogID, userID uuid.UUID) Key {
+	return Key{PK: Blog(blogID), SK: User(userID)}
+}
+
+// EmailKey returns the primary key of the marker item that reserves an email
+// address. The address should already be normalized.
+func EmailKey(email string) Key {
+	return Key{PK: EmailPrefix + email, SK: Email}
+}
+
+// SessionKey returns the primary key of the session of the refresh token with
+// the provided id.
+func SessionKey(userID uuid.UUID, tokenID string) Key {
+	return Key{PK: User(userID), SK: SessionPrefix + tokenID}
+}
+
+// ParseUser returns the id of the user identified by a USER#<id> key.
+func ParseUser(key string) (uuid.UUID, error) {
+	return parseID(key, UserPrefix)
+}
+
+// ParseBlog returns the id of the blog identified by a BLOG#<id> key.
+func ParseBlog(key string) (uuid.UUID, error) {
+	return parseID(key, BlogPrefix)
+}
+
+// ParseSession returns the token id of a SESSION#<token> sort key.
+func ParseSession(key string) (string, error) {
+	id, ok := strings.CutPrefix(key, SessionPrefix)
+	if !ok || id == "" {
+		return "", fmt.Errorf("[in keys.ParseSession] %q is not a session key: %w", key, ErrInvalidKey)
+	}
+	return id, nil
+}
+
+// parseID returns the id of a key made of the provided prefix and a UUID.
+func parseID(key, prefix string) (uuid.UUID, error) {
+	raw, ok := strings.CutPrefix(key, prefix)
+	if !ok {
+		return uuid.Nil, fmt.Errorf("[in keys.parseID] %q does not start with %s: %w", key, prefix, ErrInvalidKey)
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("[in keys.parseID] %q does not hold an id: %w: %w", key, ErrInvalidKey, err)
+	}
+	return id, nil
+}
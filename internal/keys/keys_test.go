@@ -0,0 +1,134 @@
+package keys
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUser(t *testing.T) {
+	id := uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")
+
+	tests := map[string]struct {
+		key     string
+		want    uuid.UUID
+		wantErr bool
+	}{
+		"user key":       {key: User(id), want: id},
+		"blog key":       {key: Blog(id), wantErr: true},
+		"missing id":     {key: UserPrefix, wantErr: true},
+		"invalid id":     {key: UserPrefix + "not-a-uuid", wantErr: true},
+		"profile key":    {key: Profile, wantErr: true},
+		"lowercase type": {key: "user#" + id.String(), wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseUser(tc.key)
+
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidKey, "expected an invalid key")
+				return
+			}
+			require.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.want, got, "id did not round trip")
+		})
+	}
+}
+
+func TestParseBlog(t *testing.T) {
+	id := uuid.MustParse("17e16813-c203-0355-1e4c-17c630f114f3")
+
+	got, err := ParseBlog(BlogKey(id).PK)
+	require.NoError(t, err, "unexpected error")
+	assert.Equal(t, id, got, "id did not round trip")
+
+	_, err = ParseBlog(User(id))
+	assert.ErrorIs(t, err, ErrInvalidKey, "user key parsed as a blog")
+}
+
+func TestParseSession(t *testing.T) {
+	key := SessionKey(uuid.New(), "token-id")
+
+	got, err := ParseSession(key.SK)
+	require.NoError(t, err, "unexpected error")
+	assert.Equal(t, "token-id", got, "token id did not round trip")
+
+	_, err = ParseSession(SessionPrefix)
+	assert.ErrorIs(t, err, ErrInvalidKey, "empty token id was accepted")
+}
+
+// entity is an Entity that is only indexed when it has an owner.
+type entity struct {
+	PK     string `dynamodbav:"PK"`
+	SK     string `dynamodbav:"SK"`
+	GSI1PK string `dynamodbav:"GSI1PK"`
+	GSI1SK string `dynamodbav:"GSI1SK"`
+	ID     string `dynamodbav:"id"`
+	Owner  string `dynamodbav:"owner"`
+}
+
+func (e entity) Keys() Keys {
+	keys := Keys{Key: Key{PK: "ENTITY#" + e.ID, SK: "ENTITY"}}
+	if e.Owner != "" {
+		keys.GSI1PK = "ENTITY"
+		keys.GSI1SK = "OWNER#" + e.Owner
+	}
+	return keys
+}
+
+func (e *entity) SetKeys(keys Keys) {
+	e.PK, e.SK, e.GSI1PK, e.GSI1SK = keys.PK, keys.SK, keys.GSI1PK, keys.GSI1SK
+}
+
+func TestMarshal(t *testing.T) {
+	tests := map[string]struct {
+		entity entity
+		want   map[string]types.AttributeValue
+	}{
+		"indexed": {
+			entity: entity{ID: "1", Owner: "2"},
+			want: map[string]types.AttributeValue{
+				"PK":     &types.AttributeValueMemberS{Value: "ENTITY#1"},
+				"SK":     &types.AttributeValueMemberS{Value: "ENTITY"},
+				"GSI1PK": &types.AttributeValueMemberS{Value: "ENTITY"},
+				"GSI1SK": &types.AttributeValueMemberS{Value: "OWNER#2"},
+				"id":     &types.AttributeValueMemberS{Value: "1"},
+				"owner":  &types.AttributeValueMemberS{Value: "2"},
+			},
+		},
+		"not indexed": {
+			entity: entity{ID: "1"},
+			want: map[string]types.AttributeValue{
+				"PK":    &types.AttributeValueMemberS{Value: "ENTITY#1"},
+				"SK":    &types.AttributeValueMemberS{Value: "ENTITY"},
+				"id":    &types.AttributeValueMemberS{Value: "1"},
+				"owner": &types.AttributeValueMemberS{Value: ""},
+			},
+		},
+		"stale keys are replaced": {
+			entity: entity{PK: "ENTITY#0", GSI1SK: "OWNER#0", ID: "1"},
+			want: map[string]types.AttributeValue{
+				"PK":    &types.AttributeValueMemberS{Value: "ENTITY#1"},
+				"SK":    &types.AttributeValueMemberS{Value: "ENTITY"},
+				"id":    &types.AttributeValueMemberS{Value: "1"},
+				"owner": &types.AttributeValueMemberS{Value: ""},
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			item, err := Marshal(&tc.entity)
+
+			require.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.want, item, "item did not match")
+			assert.Equal(t, tc.entity.Keys(), Keys{
+				Key:    Key{PK: tc.entity.PK, SK: tc.entity.SK},
+				GSI1PK: tc.entity.GSI1PK,
+				GSI1SK: tc.entity.GSI1SK,
+			}, "keys were not stored on the entity")
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/agallagher-captech/blog/internal/problem"
+	"github.com/google/uuid"
+)
+
+// userIDKey is the context key under which the authenticated user's id is
+// stored.
+type userIDKey struct{}
+
+// tokenVerifier represents a type capable of validating an access token and
+// returning the id of the user it was issued to.
+type tokenVerifier interface {
+	ParseAccessToken(accessToken string) (uuid.UUID, error)
+}
+
+// WithUserID returns a copy of ctx carrying the id of the authenticated user.
+func WithUserID(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, userIDKey{}, id)
+}
+
+// UserID returns the id of the authenticated user stored in ctx by
+// Authenticate, and whether one was present.
+func UserID(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(userIDKey{}).(uuid.UUID)
+	return id, ok
+}
+
+// Authenticate is a middleware that requires a valid bearer access token on
+// the request. The id of the user the token was issued to is stored in the
+// request context, and requests without a valid token get a 401.
+func Authenticate(logger *slog.Logger, verifier tokenVerifier) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || strings.TrimSpace(token) == "" {
+				logger.InfoContext(ctx, "missing bearer token")
+				unauthorized(w, r)
+				return
+			}
+
+			userID, err := verifier.ParseAccessToken(strings.TrimSpace(token))
+			if err != nil {
+				logger.InfoContext(ctx, "invalid bearer token", slog.String("error", err.Error()))
+				unauthorized(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithUserID(ctx, userID)))
+		})
+	}
+}
+
+// unauthorized writes a 401 response asking the client for a bearer token.
+func unauthorized(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Bearer`)
+	problem.Write(w, r, http.StatusUnauthorized, "a valid bearer token is required")
+}
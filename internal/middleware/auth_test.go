@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// tokenVerifierFunc adapts a function to the tokenVerifier interface.
+type tokenVerifierFunc func(accessToken string) (uuid.UUID, error)
+
+func (f tokenVerifierFunc) ParseAccessToken(accessToken string) (uuid.UUID, error) {
+	return f(accessToken)
+}
+
+func TestAuthenticate(t *testing.T) {
+	userID := uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")
+	verifier := tokenVerifierFunc(func(accessToken string) (uuid.UUID, error) {
+		if accessToken != "valid-token" {
+			return uuid.Nil, errors.New("invalid token")
+		}
+		return userID, nil
+	})
+
+	tests := map[string]struct {
+		authorization string
+		wantStatus    int
+		wantUserID    uuid.UUID
+	}{
+		"valid token": {
+			authorization: "Bearer valid-token",
+			wantStatus:    http.StatusOK,
+			wantUserID:    userID,
+		},
+		"missing header": {
+			authorization: "",
+			wantStatus:    http.StatusUnauthorized,
+		},
+		"wrong scheme": {
+			authorization: "Basic valid-token",
+			wantStatus:    http.StatusUnauthorized,
+		},
+		"invalid token": {
+			authorization: "Bearer invalid-token",
+			wantStatus:    http.StatusUnauthorized,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotUserID uuid.UUID
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUserID, _ = UserID(r.Context())
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+
+			Authenticate(slog.Default(), verifier)(next).ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.wantStatus, rec.Code, "status code mismatch")
+			assert.Equal(t, tc.wantUserID, gotUserID, "user id mismatch")
+		})
+	}
+}
@@ -8,12 +8,21 @@ import (
 
 type wrappedWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode  int
+	wroteHeader bool
 }
 
 func (w *wrappedWriter) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
 	w.statusCode = statusCode
+	w.wroteHeader = true
+}
+
+// Write writes the response body, which implicitly sends the headers with the
+// current status code if they have not been written yet.
+func (w *wrappedWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
 }
 
 // Logger is a middleware that logs the request method, path, duration, and
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unmatchedRoute is the route label of requests that did not match any route,
+// so that unknown paths cannot create new label values.
+const unmatchedRoute = "unmatched"
+
+// HTTPMetrics holds the request metrics recorded by the Metrics middleware.
+type HTTPMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewHTTPMetrics creates the request metrics and registers them with the
+// provided registerer.
+func NewHTTPMetrics(registerer prometheus.Registerer) (*HTTPMetrics, error) {
+	m := &HTTPMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests served, by route pattern and status code.",
+		}, []string{"route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by route pattern and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "status"}),
+	}
+	for _, collector := range []prometheus.Collector{m.requests, m.duration} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("[in middleware.NewHTTPMetrics] failed to register metric: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// Metrics is a middleware that counts requests and records their latency. The
+// requests are labelled with the pattern of the route they matched, e.g.
+// "GET /api/users/{id}", rather than their path, so it must wrap the
+// http.ServeMux that routes them.
+//
+// The pattern is read from the request Metrics passed on, which the mux
+// records it on. Every middleware between Metrics and the mux must pass that
+// same request on rather than a copy, such as the one r.WithContext returns,
+// or its requests are all counted as unmatched. Middleware that adds to the
+// request context, like RequestID, must wrap Metrics instead.
+func Metrics(metrics *HTTPMetrics) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			wrapped := &wrappedWriter{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+
+			next.ServeHTTP(wrapped, r)
+
+			// The mux records the pattern it matched on the request
+			route := r.Pattern
+			if route == "" {
+				route = unmatchedRoute
+			}
+			status := strconv.Itoa(wrapped.statusCode)
+
+			metrics.requests.WithLabelValues(route, status).Inc()
+			metrics.duration.WithLabelValues(route, status).Observe(time.Since(start).Seconds())
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics, err := NewHTTPMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("{}"))
+	})
+	handler := Metrics(metrics)(mux)
+
+	for _, path := range []string{"/api/users/1", "/api/users/2", "/api/users/missing", "/unknown"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	// Requests are labelled by the route they matched, not by their path
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.requests.WithLabelValues("GET /api/users/{id}", "200")), "matched requests were not counted")
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues("GET /api/users/{id}", "404")), "status was not labelled")
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues(unmatchedRoute, "404")), "unmatched request was not counted")
+	assert.Equal(t, 3, testutil.CollectAndCount(metrics.duration), "latency was not recorded per route and status")
+}
+
+func TestMetrics_Chain(t *testing.T) {
+	newMux := func() *http.ServeMux {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /api/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("{}"))
+		})
+		return mux
+	}
+
+	// The chain cmd/api serves the API with
+	metrics, err := NewHTTPMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	handler := RequestID()(Metrics(metrics)(Logger(slog.Default())(Recovery(slog.Default())(newMux()))))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users/1", nil))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues("GET /api/users/{id}", "200")), "route was not labelled through the chain")
+
+	// A middleware passing on a copy of the request hides the route
+	metrics, err = NewHTTPMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	handler = Metrics(metrics)(RequestID()(newMux()))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users/1", nil))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues(unmatchedRoute, "200")), "route was labelled through a copied request")
+}
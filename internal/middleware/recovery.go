@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/agallagher-captech/blog/internal/problem"
+)
+
+// Recovery is a middleware that recovers from panics in the wrapped handler.
+// The panic and its stack trace are logged along with the request method, path
+// and id. If the handler had not written a response yet a 500 problem is
+// returned, otherwise the response is aborted since its status can no longer
+// be changed.
+func Recovery(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wrapped := &wrappedWriter{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+
+				// http.ErrAbortHandler is the sanctioned way to abort a
+				// response, so let the server handle it as usual.
+				if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(recovered)
+				}
+
+				logger.ErrorContext(
+					r.Context(),
+					"recovered from panic",
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("panic", fmt.Sprint(recovered)),
+					slog.String("stack", string(debug.Stack())),
+				)
+
+				if wrapped.wroteHeader {
+					// Part of the response is already on the wire, so the
+					// only safe option left is to abort the connection.
+					panic(http.ErrAbortHandler)
+				}
+
+				// Drop anything the handler set before it panicked, but keep
+				// the request id so the error can be traced
+				for key := range w.Header() {
+					w.Header().Del(key)
+				}
+				if id := RequestIDFromContext(r.Context()); id != "" {
+					w.Header().Set(RequestIDHeader, id)
+				}
+				problem.Write(w, r, http.StatusInternalServerError, "")
+			}()
+
+			next.ServeHTTP(wrapped, r)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecovery(t *testing.T) {
+	tests := map[string]struct {
+		handler    http.HandlerFunc
+		wantStatus int
+		wantBody   string
+		wantAbort  bool
+	}{
+		"no panic": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		"panic before writing": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Secret", "leaked")
+				panic("boom")
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/"}`,
+		},
+		"panic after writing headers": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				panic("boom")
+			},
+			wantStatus: http.StatusOK,
+			wantAbort:  true,
+		},
+		"panic after writing body": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("partial"))
+				panic("boom")
+			},
+			wantStatus: http.StatusOK,
+			wantAbort:  true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			rec := httptest.NewRecorder()
+			handler := Recovery(slog.Default())(tc.handler)
+
+			if tc.wantAbort {
+				assert.PanicsWithValue(t, http.ErrAbortHandler, func() { handler.ServeHTTP(rec, req) }, "response was not aborted")
+			} else {
+				assert.NotPanics(t, func() { handler.ServeHTTP(rec, req) }, "panic was not recovered")
+			}
+
+			assert.Equal(t, tc.wantStatus, rec.Code, "status code mismatch")
+			if tc.wantBody != "" {
+				assert.JSONEq(t, tc.wantBody, strings.Trim(rec.Body.String(), "\n"), "body mismatch")
+				assert.Empty(t, rec.Header().Get("X-Secret"), "handler headers leaked")
+			}
+		})
+	}
+}
+
+func TestRecovery_RequestID(t *testing.T) {
+	handler := RequestID()(Recovery(slog.Default())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code, "status code mismatch")
+	assert.Equal(t, "req-123", rec.Header().Get(RequestIDHeader), "request id was dropped")
+}
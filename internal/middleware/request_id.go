@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header a request id is read from and echoed back in.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest request id accepted from a client.
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key under which the request id is stored.
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the provided request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id stored in ctx by RequestID, or
+// an empty string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID is a middleware that tags every request with an id. The id is
+// taken from the X-Request-ID header when the client sends a usable one and
+// generated otherwise. It is stored in the request context, where
+// ContextHandler picks it up for every log line, and echoed back in the
+// response header.
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+// validRequestID reports whether a client supplied request id is safe to log
+// and echo back: non-empty, bounded in length and limited to a conservative
+// set of characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ContextHandler is a slog.Handler that adds the request id stored in the
+// context of each record, if any, before passing it on to the wrapped handler.
+// This ties together every log line written with a request's context.
+type ContextHandler struct {
+	slog.Handler
+}
+
+// NewContextHandler wraps the provided handler in a ContextHandler and returns
+// a pointer to it.
+func NewContextHandler(handler slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: handler}
+}
+
+// Handle adds the context's request id to the record and passes it on.
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a ContextHandler wrapping the wrapped handler's WithAttrs.
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a ContextHandler wrapping the wrapped handler's WithGroup.
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := map[string]struct {
+		header   string
+		wantEcho bool
+	}{
+		"no header": {
+			header: "",
+		},
+		"client id": {
+			header:   "abc-123_def.456",
+			wantEcho: true,
+		},
+		"invalid characters": {
+			header: "abc\r\nSet-Cookie: x",
+		},
+		"too long": {
+			header: string(bytes.Repeat([]byte("a"), maxRequestIDLength+1)),
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.header != "" {
+				req.Header.Set(RequestIDHeader, tc.header)
+			}
+			rec := httptest.NewRecorder()
+
+			var seen string
+			handler := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFromContext(r.Context())
+			}))
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, seen, rec.Header().Get(RequestIDHeader), "response header did not match context")
+			if tc.wantEcho {
+				assert.Equal(t, tc.header, seen, "client id was not used")
+			} else {
+				_, err := uuid.Parse(seen)
+				assert.NoError(t, err, "generated id is not a uuid")
+			}
+		})
+	}
+}
+
+func TestContextHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewContextHandler(slog.NewJSONHandler(&buf, nil))).With("service", "test")
+
+	logger.InfoContext(WithRequestID(context.Background(), "req-1"), "with id")
+	logger.InfoContext(context.Background(), "without id")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+
+	var withID, withoutID map[string]any
+	assert.NoError(t, json.Unmarshal(lines[0], &withID))
+	assert.NoError(t, json.Unmarshal(lines[1], &withoutID))
+
+	assert.Equal(t, "req-1", withID["request_id"], "request id was not logged")
+	assert.Equal(t, "test", withID["service"], "attributes were lost")
+	assert.NotContains(t, withoutID, "request_id", "request id logged without one in context")
+}
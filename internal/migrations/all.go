@@ -0,0 +1,13 @@
+package migrations
+
+// All returns every migration of the table, in version order. New migrations
+// are appended with the next version, and applied migrations must never be
+// changed or removed.
+func All() []Migration {
+	return []Migration{
+		{Version: 1, Name: "hash-plaintext-passwords", Up: hashPlaintextPasswords},
+		{Version: 2, Name: "backfill-versions", Up: backfillVersions},
+		{Version: 3, Name: "backfill-email-markers", Up: backfillEmailMarkers},
+		{Version: 4, Name: "backfill-comment-counts", Up: backfillCommentCounts},
+	}
+}
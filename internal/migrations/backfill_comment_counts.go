@@ -0,0 +1,88 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/agallagher-captech/blog/internal/keys"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// backfillCommentCounts sets the comment count of every blog created before
+// comments were counted, which the comments service keeps up to date from
+// then on. Every comment is counted before anything is written. A count is
+// only set while the blog has none, and the blog's version is incremented so
+// that writes based on an earlier read fail.
+//
+// Comments written or deleted while the migration runs are not counted, so it
+// should be applied before servers that count comments are started.
+func backfillCommentCounts(ctx context.Context, step *Step) error {
+	counts, err := blogComments(ctx, step)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.ScanInput{
+		FilterExpression: aws.String("begins_with(PK, :blog) AND SK = :metadata AND attribute_not_exists(#count)"),
+		ExpressionAttributeNames: map[string]string{
+			"#count": "comment_count",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":blog":     &types.AttributeValueMemberS{Value: keys.BlogPrefix},
+			":metadata": &types.AttributeValueMemberS{Value: keys.Metadata},
+		},
+	}
+
+	return step.Backfill(ctx, "blogs", input, func(item map[string]types.AttributeValue) (*dynamodb.UpdateItemInput, error) {
+		return &dynamodb.UpdateItemInput{
+			Key: map[string]types.AttributeValue{
+				"PK": item["PK"],
+				"SK": item["SK"],
+			},
+			UpdateExpression:    aws.String("SET #count = :count, #version = if_not_exists(#version, :zero) + :one"),
+			ConditionExpression: aws.String("attribute_exists(PK) AND attribute_not_exists(#count)"),
+			ExpressionAttributeNames: map[string]string{
+				"#count":   "comment_count",
+				"#version": "version",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":count": &types.AttributeValueMemberN{Value: strconv.Itoa(counts[stringValue(item["PK"])])},
+				":zero":  &types.AttributeValueMemberN{Value: "0"},
+				":one":   &types.AttributeValueMemberN{Value: "1"},
+			},
+		}, nil
+	})
+}
+
+// blogComments scans every comment and returns the number of comments in the
+// partition of each blog that has any.
+func blogComments(ctx context.Context, step *Step) (map[string]int, error) {
+	scan := &dynamodb.ScanInput{
+		TableName:            aws.String(step.table),
+		FilterExpression:     aws.String("begins_with(PK, :blog) AND begins_with(SK, :user)"),
+		ProjectionExpression: aws.String("PK"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":blog": &types.AttributeValueMemberS{Value: keys.BlogPrefix},
+			":user": &types.AttributeValueMemberS{Value: keys.UserPrefix},
+		},
+	}
+
+	counts := map[string]int{}
+	for {
+		page, err := step.client.Scan(ctx, scan)
+		if err != nil {
+			return nil, fmt.Errorf("[in migrations.blogComments] failed to scan comments: %w", err)
+		}
+		for _, item := range page.Items {
+			counts[stringValue(item["PK"])]++
+		}
+
+		if page.LastEvaluatedKey == nil {
+			return counts, nil
+		}
+		scan.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+}
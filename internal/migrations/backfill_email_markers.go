@@ -0,0 +1,120 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"github.com/agallagher-captech/blog/internal/keys"
+	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// backfillEmailMarkers writes the email marker of every user created before
+// email addresses were reserved with marker items. Users are looked up by
+// their marker when they log in, and markers keep addresses unique. Every user
+// is read before anything is written, and the migration fails without writing
+// a marker if two users share an email address, since only one of them could
+// be reserved. It also fails if a marker already reserves the address of a
+// user for another user.
+//
+// Markers are only written while the address is free or already reserved for
+// the same user, so the migration can be run again after it failed.
+func backfillEmailMarkers(ctx context.Context, step *Step) error {
+	owners, err := userEmails(ctx, step)
+	if err != nil {
+		return err
+	}
+
+	written := 0
+	for _, email := range slices.Sorted(maps.Keys(owners)) {
+		id := owners[email]
+		if step.dryRun {
+			written++
+			continue
+		}
+
+		marker := keys.EmailKey(email).AttributeValues()
+		marker["user_id"] = id
+		_, err = step.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:           aws.String(step.table),
+			Item:                marker,
+			ConditionExpression: aws.String("attribute_not_exists(PK) OR user_id = :id"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":id": id,
+			},
+		})
+		switch {
+		case isConditionalCheckFailure(err):
+			return fmt.Errorf("[in migrations.backfillEmailMarkers] the email address of user %s is reserved for another user", stringValue(id))
+		case err != nil:
+			return fmt.Errorf("[in migrations.backfillEmailMarkers] failed to put email marker: %w", err)
+		}
+		written++
+	}
+
+	message := "email markers written"
+	if step.dryRun {
+		message = "email markers would be written"
+	}
+	step.logger.InfoContext(ctx, message, slog.Int("users", len(owners)), slog.Int("markers", written))
+	return nil
+}
+
+// userEmails scans every user and returns the id of the user that registered
+// each normalized email address. An error is returned if two users registered
+// the same address.
+func userEmails(ctx context.Context, step *Step) (map[string]types.AttributeValue, error) {
+	scan := &dynamodb.ScanInput{
+		TableName:            aws.String(step.table),
+		FilterExpression:     aws.String("begins_with(PK, :user) AND SK = :profile"),
+		ProjectionExpression: aws.String("user_id, email"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user":    &types.AttributeValueMemberS{Value: keys.UserPrefix},
+			":profile": &types.AttributeValueMemberS{Value: keys.Profile},
+		},
+	}
+
+	owners := map[string]types.AttributeValue{}
+	for {
+		page, err := step.client.Scan(ctx, scan)
+		if err != nil {
+			return nil, fmt.Errorf("[in migrations.userEmails] failed to scan users: %w", err)
+		}
+
+		for _, item := range page.Items {
+			email := models.NormalizeEmail(stringValue(item["email"]))
+			id := item["user_id"]
+			if email == "" || stringValue(id) == "" {
+				continue
+			}
+			if owner, ok := owners[email]; ok {
+				return nil, fmt.Errorf(
+					"[in migrations.userEmails] users %s and %s share an email address, which must be changed for one of them first",
+					stringValue(owner),
+					stringValue(id),
+				)
+			}
+			owners[email] = id
+		}
+
+		if page.LastEvaluatedKey == nil {
+			return owners, nil
+		}
+		scan.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+}
+
+// stringValue returns the value of a string attribute, or "" if the attribute
+// is missing or not a string.
+func stringValue(value types.AttributeValue) string {
+	s, ok := value.(*types.AttributeValueMemberS)
+	if !ok {
+		return ""
+	}
+	return s.Value
+}
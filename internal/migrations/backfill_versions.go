@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/agallagher-captech/blog/internal/keys"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// backfillVersions sets the version of users, blogs and comments written
+// before versioning was introduced to 1. Unversioned items have the ETag "0",
+// which cannot be sent back in If-Match, since a request without If-Match
+// also asks for version 0, meaning any version. An item is only updated while
+// it still has no version.
+func backfillVersions(ctx context.Context, step *Step) error {
+	input := &dynamodb.ScanInput{
+		FilterExpression: aws.String("GSI1PK IN (:users, :blogs, :comments) AND attribute_not_exists(#version)"),
+		ExpressionAttributeNames: map[string]string{
+			"#version": "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":users":    &types.AttributeValueMemberS{Value: keys.Users},
+			":blogs":    &types.AttributeValueMemberS{Value: keys.Blogs},
+			":comments": &types.AttributeValueMemberS{Value: keys.Comments},
+		},
+	}
+
+	return step.Backfill(ctx, "versions", input, func(item map[string]types.AttributeValue) (*dynamodb.UpdateItemInput, error) {
+		return &dynamodb.UpdateItemInput{
+			Key: map[string]types.AttributeValue{
+				"PK": item["PK"],
+				"SK": item["SK"],
+			},
+			UpdateExpression:    aws.String("SET #version = :version"),
+			ConditionExpression: aws.String("attribute_exists(PK) AND attribute_not_exists(#version)"),
+			ExpressionAttributeNames: map[string]string{
+				"#version": "version",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":version": &types.AttributeValueMemberN{Value: "1"},
+			},
+		}, nil
+	})
+}
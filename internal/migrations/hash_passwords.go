@@ -0,0 +1,60 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/agallagher-captech/blog/internal/keys"
+	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// hashPlaintextPasswords replaces the plaintext passwords of users created
+// before passwords were hashed with their bcrypt hash. A password is only
+// replaced if it has not changed since it was read, and the user's version is
+// incremented so that writes based on an earlier read fail.
+func hashPlaintextPasswords(ctx context.Context, step *Step) error {
+	input := &dynamodb.ScanInput{
+		FilterExpression: aws.String("begins_with(PK, :user) AND SK = :profile AND attribute_exists(#password)"),
+		ExpressionAttributeNames: map[string]string{
+			"#password": "password",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user":    &types.AttributeValueMemberS{Value: keys.UserPrefix},
+			":profile": &types.AttributeValueMemberS{Value: keys.Profile},
+		},
+	}
+
+	return step.Backfill(ctx, "users", input, func(item map[string]types.AttributeValue) (*dynamodb.UpdateItemInput, error) {
+		password, ok := item["password"].(*types.AttributeValueMemberS)
+		if !ok || models.IsHashedPassword(password.Value) {
+			return nil, nil
+		}
+
+		id, err := keys.ParseUser(stringValue(item["PK"]))
+		if err != nil {
+			return nil, err
+		}
+
+		hash, err := models.HashPassword(password.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &dynamodb.UpdateItemInput{
+			Key:                 keys.UserKey(id).AttributeValues(),
+			UpdateExpression:    aws.String("SET #password = :hash, #version = if_not_exists(#version, :zero) + :one"),
+			ConditionExpression: aws.String("#password = :plaintext"),
+			ExpressionAttributeNames: map[string]string{
+				"#password": "password",
+				"#version":  "version",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":hash":      &types.AttributeValueMemberS{Value: hash},
+				":plaintext": password,
+				":zero":      &types.AttributeValueMemberN{Value: "0"},
+				":one":       &types.AttributeValueMemberN{Value: "1"},
+			},
+		}, nil
+	})
+}
@@ -0,0 +1,305 @@
+// Package migrations applies versioned changes to the table, such as creating
+// indexes and backfilling items, in order and at most once.
+//
+// Every migration is recorded in the table itself as an item with the key
+// MIGRATION#<version>/MIGRATION. The record is written when a migration starts
+// and marked applied when it finishes. Backfills save the position of their
+// scan in the record after every page, so running a migration that failed
+// again resumes where it stopped. Only one runner should apply migrations at
+// a time.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// dynamoClient is the part of the DynamoDB API used to record and apply
+// migrations.
+type dynamoClient interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)
+}
+
+// Migration is a single change to the table. Up must be safe to run again
+// after it failed part way, which the helpers of Step take care of for index
+// changes and backfills.
+type Migration struct {
+	// Version orders migrations. Versions are unique and start at 1.
+	Version int
+
+	// Name is a short description of the migration, e.g.
+	// "hash-plaintext-passwords".
+	Name string
+
+	// Up applies the migration.
+	Up func(ctx context.Context, step *Step) error
+}
+
+// Status is the state of a migration in the table.
+type Status string
+
+const (
+	// StatusPending migrations have never been started.
+	StatusPending Status = "pending"
+
+	// StatusRunning migrations have been started but not finished, either
+	// because they are being applied or because they failed. Running them
+	// again resumes them.
+	StatusRunning Status = "running"
+
+	// StatusApplied migrations have finished.
+	StatusApplied Status = "applied"
+)
+
+// State is a migration along with its status in the table.
+type State struct {
+	Migration Migration
+	Status    Status
+	StartedAt time.Time
+	AppliedAt time.Time
+}
+
+// record is the item recording a migration in the table. The migration's
+// version is stored as its migration attribute, so that it cannot be mistaken
+// for the version attribute of the items the services write. Its checkpoints
+// are stored as a map of backfill name to the key the backfill's scan resumes
+// from, which is marshalled by hand.
+type record struct {
+	PK          string                                     `dynamodbav:"PK"`
+	SK          string                                     `dynamodbav:"SK"`
+	Version     int                                        `dynamodbav:"migration"`
+	Name        string                                     `dynamodbav:"name"`
+	Status      Status                                     `dynamodbav:"status"`
+	StartedAt   time.Time                                  `dynamodbav:"started_at"`
+	AppliedAt   time.Time                                  `dynamodbav:"applied_at,omitempty"`
+	Completed   []string                                   `dynamodbav:"completed,stringset,omitempty"`
+	Checkpoints map[string]map[string]types.AttributeValue `dynamodbav:"-"`
+}
+
+// recordKey returns the key of the record of the migration with the provided
+// version.
+func recordKey(version int) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "MIGRATION#" + strconv.Itoa(version)},
+		"SK": &types.AttributeValueMemberS{Value: "MIGRATION"},
+	}
+}
+
+// Runner applies migrations to a table.
+type Runner struct {
+	logger     *slog.Logger
+	client     dynamoClient
+	table      string
+	migrations []Migration
+}
+
+// NewRunner creates a new Runner for the provided migrations and returns a
+// pointer to it. An error is returned if the migrations are not numbered from 1
+// without gaps or repeats, in any order.
+func NewRunner(logger *slog.Logger, client dynamoClient, table string, migrations []Migration) (*Runner, error) {
+	sorted := slices.Clone(migrations)
+	slices.SortFunc(sorted, func(a, b Migration) int {
+		return a.Version - b.Version
+	})
+	for i, migration := range sorted {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("[in migrations.NewRunner] migration %q has version %d, want %d", migration.Name, migration.Version, i+1)
+		}
+		if migration.Up == nil {
+			return nil, fmt.Errorf("[in migrations.NewRunner] migration %d has no Up function", migration.Version)
+		}
+	}
+
+	return &Runner{
+		logger:     logger,
+		client:     client,
+		table:      table,
+		migrations: sorted,
+	}, nil
+}
+
+// Status returns the state of every migration in version order.
+func (r *Runner) Status(ctx context.Context) ([]State, error) {
+	states := make([]State, len(r.migrations))
+	for i, migration := range r.migrations {
+		rec, err := r.read(ctx, migration)
+		if err != nil {
+			return nil, err
+		}
+		states[i] = State{
+			Migration: migration,
+			Status:    rec.Status,
+			StartedAt: rec.StartedAt,
+			AppliedAt: rec.AppliedAt,
+		}
+	}
+	return states, nil
+}
+
+// Up applies every migration that has not been applied, in version order, up
+// to and including the target version. A target of 0 applies every
+// migration. If dryRun is true, nothing is written to the table: migrations
+// read what they would change and log it instead. Up returns the number of
+// migrations applied, or that would be during a dry run, and stops at the
+// first that fails.
+func (r *Runner) Up(ctx context.Context, target int, dryRun bool) (int, error) {
+	applied := 0
+	for _, migration := range r.migrations {
+		if target > 0 && migration.Version > target {
+			break
+		}
+
+		rec, err := r.read(ctx, migration)
+		if err != nil {
+			return applied, err
+		}
+		if rec.Status == StatusApplied {
+			continue
+		}
+
+		logger := r.logger.With(
+			slog.Int("migration", migration.Version),
+			slog.String("name", migration.Name),
+			slog.Bool("dry_run", dryRun),
+		)
+		if rec.Status == StatusRunning {
+			logger.InfoContext(ctx, "resuming migration")
+		} else {
+			logger.InfoContext(ctx, "applying migration")
+			rec.Status = StatusRunning
+			rec.StartedAt = time.Now().UTC()
+		}
+
+		step := &Step{
+			logger: logger,
+			client: r.client,
+			table:  r.table,
+			dryRun: dryRun,
+			record: rec,
+		}
+		if err = step.save(ctx); err != nil {
+			return applied, err
+		}
+		if err = migration.Up(ctx, step); err != nil {
+			return applied, fmt.Errorf("[in migrations.Runner.Up] migration %d %q failed, run it again to resume: %w", migration.Version, migration.Name, err)
+		}
+
+		rec.Status = StatusApplied
+		rec.AppliedAt = time.Now().UTC()
+		rec.Checkpoints = nil
+		if err = step.save(ctx); err != nil {
+			return applied, err
+		}
+		logger.InfoContext(ctx, "applied migration")
+		applied++
+	}
+	return applied, nil
+}
+
+// read returns the record of a migration, which is pending if the migration
+// has never been started.
+func (r *Runner) read(ctx context.Context, migration Migration) (*record, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.table),
+		Key:            recordKey(migration.Version),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[in migrations.Runner.read] failed to get migration %d: %w", migration.Version, err)
+	}
+
+	rec := &record{Checkpoints: make(map[string]map[string]types.AttributeValue)}
+	if result.Item == nil {
+		rec.Version = migration.Version
+		rec.Name = migration.Name
+		rec.Status = StatusPending
+		return rec, nil
+	}
+	if err = attributevalue.UnmarshalMap(result.Item, rec); err != nil {
+		return nil, fmt.Errorf("[in migrations.Runner.read] failed to unmarshal migration %d: %w", migration.Version, err)
+	}
+	// Records written before the version was stored as the migration
+	// attribute only hold it in their key, and the attribute is replaced the
+	// next time the record is saved.
+	rec.Version = migration.Version
+	if checkpoints, ok := result.Item["checkpoints"].(*types.AttributeValueMemberM); ok {
+		for name, key := range checkpoints.Value {
+			if key, ok := key.(*types.AttributeValueMemberM); ok {
+				rec.Checkpoints[name] = key.Value
+			}
+		}
+	}
+	return rec, nil
+}
+
+// Step is a single run of a migration, which is passed to its Up function.
+type Step struct {
+	logger *slog.Logger
+	client dynamoClient
+	table  string
+	dryRun bool
+	record *record
+}
+
+// Logger returns the logger of the migration.
+func (s *Step) Logger() *slog.Logger {
+	return s.logger
+}
+
+// DryRun reports whether the migration is being run without writing to the
+// table.
+func (s *Step) DryRun() bool {
+	return s.dryRun
+}
+
+// save writes the record of the migration, unless this is a dry run.
+func (s *Step) save(ctx context.Context) error {
+	if s.dryRun {
+		return nil
+	}
+
+	s.record.PK = "MIGRATION#" + strconv.Itoa(s.record.Version)
+	s.record.SK = "MIGRATION"
+	item, err := attributevalue.MarshalMap(s.record)
+	if err != nil {
+		return fmt.Errorf("[in migrations.Step.save] failed to marshal migration %d: %w", s.record.Version, err)
+	}
+	if len(s.record.Checkpoints) > 0 {
+		checkpoints := make(map[string]types.AttributeValue, len(s.record.Checkpoints))
+		for name, key := range s.record.Checkpoints {
+			checkpoints[name] = &types.AttributeValueMemberM{Value: key}
+		}
+		item["checkpoints"] = &types.AttributeValueMemberM{Value: checkpoints}
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("[in migrations.Step.save] failed to put migration %d: %w", s.record.Version, err)
+	}
+	return nil
+}
+
+// isConditionalCheckFailure reports whether a write failed because its
+// condition did not hold.
+func isConditionalCheckFailure(err error) bool {
+	var ccf *types.ConditionalCheckFailedException
+	return errors.As(err, &ccf)
+}
@@ -0,0 +1,427 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/agallagher-captech/blog/internal/services/memory"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const table = "BlogContent"
+
+func newSeededClient(t *testing.T) *memory.Client {
+	t.Helper()
+	client := memory.NewClient()
+	require.NoError(t, client.LoadDir("../../dynamodb_seed"), "failed to load seed data")
+	return client
+}
+
+// blogItems is a scan of the blogs and their comments, four items per page.
+func blogItems() *dynamodb.ScanInput {
+	return &dynamodb.ScanInput{
+		FilterExpression: aws.String("begins_with(PK, :blog)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":blog": &types.AttributeValueMemberS{Value: "BLOG#"},
+		},
+		Limit: aws.Int32(4),
+	}
+}
+
+// markMigrated returns the update that marks an item as migrated, unless it
+// already is.
+func markMigrated(item map[string]types.AttributeValue) *dynamodb.UpdateItemInput {
+	return &dynamodb.UpdateItemInput{
+		Key:                 map[string]types.AttributeValue{"PK": item["PK"], "SK": item["SK"]},
+		UpdateExpression:    aws.String("SET migrated = :true"),
+		ConditionExpression: aws.String("attribute_not_exists(migrated)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":true": &types.AttributeValueMemberBOOL{Value: true},
+		},
+	}
+}
+
+// countMigrated returns the number of blog items marked as migrated, and the
+// number of blog items.
+func countMigrated(t *testing.T, client *memory.Client) (int, int) {
+	t.Helper()
+	input := blogItems()
+	input.TableName = aws.String(table)
+	input.Limit = nil
+	result, err := client.Scan(context.TODO(), input)
+	require.NoError(t, err)
+
+	migrated := 0
+	for _, item := range result.Items {
+		if _, ok := item["migrated"]; ok {
+			migrated++
+		}
+	}
+	return migrated, len(result.Items)
+}
+
+func TestNewRunner(t *testing.T) {
+	up := func(ctx context.Context, step *Step) error { return nil }
+
+	tests := map[string]struct {
+		migrations []Migration
+		wantErr    bool
+	}{
+		"ordered":     {migrations: []Migration{{Version: 1, Up: up}, {Version: 2, Up: up}}},
+		"unordered":   {migrations: []Migration{{Version: 2, Up: up}, {Version: 1, Up: up}}},
+		"gap":         {migrations: []Migration{{Version: 1, Up: up}, {Version: 3, Up: up}}, wantErr: true},
+		"repeat":      {migrations: []Migration{{Version: 1, Up: up}, {Version: 1, Up: up}}, wantErr: true},
+		"zero":        {migrations: []Migration{{Version: 0, Up: up}}, wantErr: true},
+		"missing up":  {migrations: []Migration{{Version: 1}}, wantErr: true},
+		"registered":  {migrations: All()},
+		"no versions": {},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewRunner(slog.Default(), memory.NewClient(), table, tc.migrations)
+
+			if tc.wantErr {
+				assert.Error(t, err, "expected an error")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+		})
+	}
+}
+
+func TestRunner_Up(t *testing.T) {
+	client := newSeededClient(t)
+	var applied []int
+	record := func(version int) func(ctx context.Context, step *Step) error {
+		return func(ctx context.Context, step *Step) error {
+			applied = append(applied, version)
+			return nil
+		}
+	}
+	runner, err := NewRunner(slog.Default(), client, table, []Migration{
+		{Version: 1, Name: "first", Up: record(1)},
+		{Version: 2, Name: "second", Up: record(2)},
+		{Version: 3, Name: "third", Up: record(3)},
+	})
+	require.NoError(t, err)
+
+	// Apply up to the second migration
+	count, err := runner.Up(context.TODO(), 2, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count, "expected two migrations to be applied")
+	assert.Equal(t, []int{1, 2}, applied, "migrations were not applied in order")
+
+	states, err := runner.Status(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, states, 3)
+	assert.Equal(t, StatusApplied, states[0].Status, "first migration was not recorded")
+	assert.False(t, states[0].AppliedAt.IsZero(), "first migration has no applied time")
+	assert.Equal(t, StatusApplied, states[1].Status, "second migration was not recorded")
+	assert.Equal(t, StatusPending, states[2].Status, "third migration should be pending")
+	assert.True(t, states[2].AppliedAt.IsZero(), "third migration should have no applied time")
+
+	// Only the remaining migration is applied
+	count, err = runner.Up(context.TODO(), 0, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "expected one migration to be applied")
+	assert.Equal(t, []int{1, 2, 3}, applied, "applied migrations were run again")
+
+	// The records are items of the table
+	result, err := client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key:       recordKey(3),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "third"}, result.Item["name"], "record did not match")
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "3"}, result.Item["migration"], "record did not store its migration")
+	assert.NotContains(t, result.Item, "version", "record must not look like a versioned item")
+}
+
+func TestRunner_Up_DryRun(t *testing.T) {
+	client := newSeededClient(t)
+	runner, err := NewRunner(slog.Default(), client, table, []Migration{
+		{Version: 1, Name: "mark-blogs", Up: func(ctx context.Context, step *Step) error {
+			assert.True(t, step.DryRun(), "step should be a dry run")
+			return step.Backfill(ctx, "blogs", blogItems(), func(item map[string]types.AttributeValue) (*dynamodb.UpdateItemInput, error) {
+				return markMigrated(item), nil
+			})
+		}},
+	})
+	require.NoError(t, err)
+
+	count, err := runner.Up(context.TODO(), 0, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "expected one migration to be dry run")
+
+	migrated, _ := countMigrated(t, client)
+	assert.Zero(t, migrated, "a dry run must not update items")
+	states, err := runner.Status(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, states[0].Status, "a dry run must not record the migration")
+}
+
+func TestRunner_Up_ResumesFailedBackfill(t *testing.T) {
+	client := newSeededClient(t)
+	errBackfill := errors.New("backfill failed")
+	seen, failAt := 0, 10
+	runner, err := NewRunner(slog.Default(), client, table, []Migration{
+		{Version: 1, Name: "mark-blogs", Up: func(ctx context.Context, step *Step) error {
+			return step.Backfill(ctx, "blogs", blogItems(), func(item map[string]types.AttributeValue) (*dynamodb.UpdateItemInput, error) {
+				seen++
+				if seen == failAt {
+					return nil, errBackfill
+				}
+				return markMigrated(item), nil
+			})
+		}},
+	})
+	require.NoError(t, err)
+
+	// The first run fails part way through its third page
+	_, err = runner.Up(context.TODO(), 0, false)
+	require.ErrorIs(t, err, errBackfill)
+	states, err := runner.Status(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, states[0].Status, "failed migration should still be running")
+	migrated, total := countMigrated(t, client)
+	assert.Equal(t, failAt-1, migrated, "items before the failure should be migrated")
+
+	// The second run resumes from the start of the third page, so the item
+	// already migrated on that page is skipped by its condition
+	seen, failAt = 0, 0
+	count, err := runner.Up(context.TODO(), 0, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "expected the migration to be applied")
+	assert.Equal(t, total-8, seen, "backfill did not resume from its checkpoint")
+	migrated, _ = countMigrated(t, client)
+	assert.Equal(t, total, migrated, "every item should be migrated")
+
+	states, err = runner.Status(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, StatusApplied, states[0].Status, "migration should be applied")
+}
+
+func TestStep_CreateIndex(t *testing.T) {
+	client := newSeededClient(t)
+	index := types.GlobalSecondaryIndex{
+		IndexName: aws.String("GSI2"),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("GSI2PK"), KeyType: types.KeyTypeHash},
+		},
+		Projection: &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly},
+	}
+	definitions := []types.AttributeDefinition{
+		{AttributeName: aws.String("GSI2PK"), AttributeType: types.ScalarAttributeTypeS},
+	}
+
+	for _, dryRun := range []bool{true, false, false} {
+		step := &Step{logger: slog.Default(), client: client, table: table, dryRun: dryRun, record: &record{Version: 1}}
+		require.NoError(t, step.CreateIndex(context.TODO(), index, definitions), "dry run %t", dryRun)
+
+		described, err := client.DescribeTable(context.TODO(), &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+		require.NoError(t, err)
+		want := 2
+		if dryRun {
+			want = 1
+		}
+		assert.Len(t, described.Table.GlobalSecondaryIndexes, want, "dry run %t", dryRun)
+	}
+}
+
+func TestHashPlaintextPasswords(t *testing.T) {
+	// The seed data holds plaintext passwords, as users created before
+	// passwords were hashed do
+	client := newSeededClient(t)
+	seededKey := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "USER#d2eddb69-f92f-694d-450d-e7cdb6decce3"},
+		"SK": &types.AttributeValueMemberS{Value: "PROFILE"},
+	}
+	hashedKey := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "USER#00000000-0000-0000-0000-000000000001"},
+		"SK": &types.AttributeValueMemberS{Value: "PROFILE"},
+	}
+	hash, err := models.HashPassword("hunter2")
+	require.NoError(t, err)
+	_, err = client.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName: aws.String(table),
+		Item: map[string]types.AttributeValue{
+			"PK":       hashedKey["PK"],
+			"SK":       hashedKey["SK"],
+			"password": &types.AttributeValueMemberS{Value: hash},
+			"version":  &types.AttributeValueMemberN{Value: "3"},
+		},
+	})
+	require.NoError(t, err)
+
+	runner, err := NewRunner(slog.Default(), client, table, All())
+	require.NoError(t, err)
+	_, err = runner.Up(context.TODO(), 1, false)
+	require.NoError(t, err)
+
+	result, err := client.GetItem(context.TODO(), &dynamodb.GetItemInput{TableName: aws.String(table), Key: seededKey})
+	require.NoError(t, err)
+	seededHash := result.Item["password"].(*types.AttributeValueMemberS).Value
+	assert.True(t, models.User{Password: seededHash}.VerifyPassword("password5"), "seeded password was not hashed")
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "1"}, result.Item["version"], "version was not incremented")
+
+	users, err := client.Scan(context.TODO(), &dynamodb.ScanInput{
+		TableName:        aws.String(table),
+		FilterExpression: aws.String("attribute_exists(password)"),
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, users.Items, "seed data has no users")
+	for _, item := range users.Items {
+		password := item["password"].(*types.AttributeValueMemberS).Value
+		assert.True(t, models.IsHashedPassword(password), "password of %s was not hashed", stringValue(item["PK"]))
+	}
+
+	result, err = client.GetItem(context.TODO(), &dynamodb.GetItemInput{TableName: aws.String(table), Key: hashedKey})
+	require.NoError(t, err)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: hash}, result.Item["password"], "hashed passwords must not change")
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "3"}, result.Item["version"], "unchanged users must keep their version")
+}
+
+func TestBackfillVersions(t *testing.T) {
+	client := newSeededClient(t)
+	versioned := map[string]types.AttributeValue{
+		"PK":      &types.AttributeValueMemberS{Value: "USER#00000000-0000-0000-0000-000000000001"},
+		"SK":      &types.AttributeValueMemberS{Value: "PROFILE"},
+		"GSI1PK":  &types.AttributeValueMemberS{Value: "USER"},
+		"version": &types.AttributeValueMemberN{Value: "4"},
+	}
+	_, err := client.PutItem(context.TODO(), &dynamodb.PutItemInput{TableName: aws.String(table), Item: versioned})
+	require.NoError(t, err)
+
+	runner, err := NewRunner(slog.Default(), client, table, All())
+	require.NoError(t, err)
+	_, err = runner.Up(context.TODO(), 2, false)
+	require.NoError(t, err)
+
+	result, err := client.Scan(context.TODO(), &dynamodb.ScanInput{
+		TableName:        aws.String(table),
+		FilterExpression: aws.String("GSI1PK IN (:users, :blogs, :comments)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":users":    &types.AttributeValueMemberS{Value: "USER"},
+			":blogs":    &types.AttributeValueMemberS{Value: "BLOG"},
+			":comments": &types.AttributeValueMemberS{Value: "COMMENT"},
+		},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, result.Items)
+	for _, item := range result.Items {
+		want := "1"
+		if item["PK"].(*types.AttributeValueMemberS).Value == "USER#00000000-0000-0000-0000-000000000001" {
+			want = "4"
+		}
+		assert.Equal(t, &types.AttributeValueMemberN{Value: want}, item["version"], "version of %v", item["PK"])
+	}
+}
+
+func TestBackfillEmailMarkers(t *testing.T) {
+	emmaID := &types.AttributeValueMemberS{Value: "d2eddb69-f92f-694d-450d-e7cdb6decce3"}
+	emmaMarker := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "EMAIL#emma@example.com"},
+		"SK": &types.AttributeValueMemberS{Value: "EMAIL"},
+	}
+
+	tests := map[string]struct {
+		user    map[string]types.AttributeValue
+		marker  map[string]types.AttributeValue
+		wantErr bool
+	}{
+		"seeded users": {},
+		"marker already written": {
+			marker: map[string]types.AttributeValue{"PK": emmaMarker["PK"], "SK": emmaMarker["SK"], "user_id": emmaID},
+		},
+		"duplicate email": {
+			user: map[string]types.AttributeValue{
+				"PK":      &types.AttributeValueMemberS{Value: "USER#00000000-0000-0000-0000-000000000001"},
+				"SK":      &types.AttributeValueMemberS{Value: "PROFILE"},
+				"user_id": &types.AttributeValueMemberS{Value: "00000000-0000-0000-0000-000000000001"},
+				"email":   &types.AttributeValueMemberS{Value: " Emma@Example.com"},
+			},
+			wantErr: true,
+		},
+		"email reserved for another user": {
+			marker: map[string]types.AttributeValue{
+				"PK":      emmaMarker["PK"],
+				"SK":      emmaMarker["SK"],
+				"user_id": &types.AttributeValueMemberS{Value: "00000000-0000-0000-0000-000000000001"},
+			},
+			wantErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Remove the marker of a seeded user, as if it was created before
+			// markers were
+			client := newSeededClient(t)
+			_, err := client.DeleteItem(context.TODO(), &dynamodb.DeleteItemInput{TableName: aws.String(table), Key: emmaMarker})
+			require.NoError(t, err)
+			for _, item := range []map[string]types.AttributeValue{tc.user, tc.marker} {
+				if item != nil {
+					_, err := client.PutItem(context.TODO(), &dynamodb.PutItemInput{TableName: aws.String(table), Item: item})
+					require.NoError(t, err)
+				}
+			}
+
+			runner, err := NewRunner(slog.Default(), client, table, All())
+			require.NoError(t, err)
+			_, err = runner.Up(context.TODO(), 3, false)
+
+			result, getErr := client.GetItem(context.TODO(), &dynamodb.GetItemInput{TableName: aws.String(table), Key: emmaMarker})
+			require.NoError(t, getErr)
+			if tc.wantErr {
+				assert.Error(t, err, "expected an error")
+				if tc.marker == nil {
+					assert.Nil(t, result.Item, "marker was written for a duplicate email")
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, emmaID, result.Item["user_id"], "marker was not written")
+		})
+	}
+}
+
+func TestBackfillCommentCounts(t *testing.T) {
+	client := newSeededClient(t)
+	counted := map[string]types.AttributeValue{
+		"PK":            &types.AttributeValueMemberS{Value: "BLOG#00000000-0000-0000-0000-000000000001"},
+		"SK":            &types.AttributeValueMemberS{Value: "METADATA"},
+		"comment_count": &types.AttributeValueMemberN{Value: "7"},
+		"version":       &types.AttributeValueMemberN{Value: "4"},
+	}
+	_, err := client.PutItem(context.TODO(), &dynamodb.PutItemInput{TableName: aws.String(table), Item: counted})
+	require.NoError(t, err)
+
+	runner, err := NewRunner(slog.Default(), client, table, All())
+	require.NoError(t, err)
+	_, err = runner.Up(context.TODO(), 4, false)
+	require.NoError(t, err)
+
+	result, err := client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "BLOG#17e16813-c203-0355-1e4c-17c630f114f3"},
+			"SK": &types.AttributeValueMemberS{Value: "METADATA"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "5"}, result.Item["comment_count"], "comments were not counted")
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "2"}, result.Item["version"], "version was not incremented")
+
+	result, err = client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key:       map[string]types.AttributeValue{"PK": counted["PK"], "SK": counted["SK"]},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, counted, result.Item, "counted blog was changed")
+}
@@ -0,0 +1,170 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// indexPollInterval is how often the table is described while waiting for a
+// new index to become ACTIVE.
+var indexPollInterval = 5 * time.Second
+
+// UpdateFunc returns the update a backfill writes for an item, or nil if the
+// item does not need one. The table name of the update is set by the
+// backfill. Updates should have a condition that fails once the item has been
+// migrated, so items updated before a failure are skipped when the backfill
+// resumes.
+type UpdateFunc func(item map[string]types.AttributeValue) (*dynamodb.UpdateItemInput, error)
+
+// Backfill scans the table page by page, or the index named by input, and
+// writes the update returned for every item. The name identifies the backfill
+// within its migration: after every page the position of the scan is saved
+// under it, so a backfill that failed resumes from the last page it finished,
+// and a backfill that finished is not run again. Updates whose condition fails
+// are counted as skipped. During a dry run the table is scanned but nothing
+// is written.
+func (s *Step) Backfill(ctx context.Context, name string, input *dynamodb.ScanInput, update UpdateFunc) error {
+	logger := s.logger.With(slog.String("backfill", name))
+	if slices.Contains(s.record.Completed, name) {
+		logger.InfoContext(ctx, "backfill already completed")
+		return nil
+	}
+
+	scan := *input
+	scan.TableName = aws.String(s.table)
+	scan.ExclusiveStartKey = s.record.Checkpoints[name]
+	if scan.ExclusiveStartKey != nil {
+		logger.InfoContext(ctx, "resuming backfill from checkpoint")
+	}
+
+	scanned, updated, skipped := 0, 0, 0
+	for {
+		page, err := s.client.Scan(ctx, &scan)
+		if err != nil {
+			return fmt.Errorf("[in migrations.Step.Backfill] failed to scan %s: %w", name, err)
+		}
+		scanned += len(page.Items)
+
+		for _, item := range page.Items {
+			write, err := update(item)
+			if err != nil {
+				return fmt.Errorf("[in migrations.Step.Backfill] failed to build update for %s: %w", name, err)
+			}
+			if write == nil {
+				continue
+			}
+			if s.dryRun {
+				updated++
+				continue
+			}
+
+			write.TableName = aws.String(s.table)
+			_, err = s.client.UpdateItem(ctx, write)
+			switch {
+			case isConditionalCheckFailure(err):
+				skipped++
+			case err != nil:
+				return fmt.Errorf("[in migrations.Step.Backfill] failed to update item for %s: %w", name, err)
+			default:
+				updated++
+			}
+		}
+
+		if page.LastEvaluatedKey == nil {
+			break
+		}
+		scan.ExclusiveStartKey = page.LastEvaluatedKey
+		s.record.Checkpoints[name] = page.LastEvaluatedKey
+		if err = s.save(ctx); err != nil {
+			return err
+		}
+	}
+
+	delete(s.record.Checkpoints, name)
+	s.record.Completed = append(s.record.Completed, name)
+	if err := s.save(ctx); err != nil {
+		return err
+	}
+
+	message := "backfill completed"
+	if s.dryRun {
+		message = "backfill would update items"
+	}
+	logger.InfoContext(ctx, message,
+		slog.Int("scanned", scanned),
+		slog.Int("updated", updated),
+		slog.Int("skipped", skipped))
+	return nil
+}
+
+// CreateIndex creates a global secondary index on the table, defining the
+// provided key attributes, and waits until it is ACTIVE. Nothing is created if
+// the table already has an index with the same name. During a dry run the
+// index is only logged.
+func (s *Step) CreateIndex(ctx context.Context, index types.GlobalSecondaryIndex, definitions []types.AttributeDefinition) error {
+	name := aws.StringValue(index.IndexName)
+	logger := s.logger.With(slog.String("index", name))
+
+	described, err := s.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(s.table)})
+	if err != nil {
+		return fmt.Errorf("[in migrations.Step.CreateIndex] failed to describe table: %w", err)
+	}
+	exists := slices.ContainsFunc(described.Table.GlobalSecondaryIndexes, func(gsi types.GlobalSecondaryIndexDescription) bool {
+		return aws.StringValue(gsi.IndexName) == name
+	})
+
+	switch {
+	case exists:
+		logger.InfoContext(ctx, "index already exists")
+	case s.dryRun:
+		logger.InfoContext(ctx, "index would be created")
+		return nil
+	default:
+		logger.InfoContext(ctx, "creating index")
+		_, err = s.client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+			TableName:            aws.String(s.table),
+			AttributeDefinitions: definitions,
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+				{Create: &types.CreateGlobalSecondaryIndexAction{
+					IndexName:             index.IndexName,
+					KeySchema:             index.KeySchema,
+					Projection:            index.Projection,
+					ProvisionedThroughput: index.ProvisionedThroughput,
+				}},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("[in migrations.Step.CreateIndex] failed to create index %s: %w", name, err)
+		}
+	}
+
+	return s.waitForIndex(ctx, name)
+}
+
+// waitForIndex waits until the named index is ACTIVE.
+func (s *Step) waitForIndex(ctx context.Context, name string) error {
+	for {
+		described, err := s.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(s.table)})
+		if err != nil {
+			return fmt.Errorf("[in migrations.Step.waitForIndex] failed to describe table: %w", err)
+		}
+		for _, gsi := range described.Table.GlobalSecondaryIndexes {
+			if aws.StringValue(gsi.IndexName) == name && gsi.IndexStatus == types.IndexStatusActive {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("[in migrations.Step.waitForIndex] index %s did not become ACTIVE: %w", name, ctx.Err())
+		case <-time.After(indexPollInterval):
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package models
+
+import "github.com/agallagher-captech/blog/internal/keys"
+
+type Blog struct {
+	DynamoDBBase
+	ID          UUID    `dynamodbav:"blog_id"`
+	UserID      UUID    `dynamodbav:"user_id"`
+	Title       string  `dynamodbav:"title"`
+	Score       float64 `dynamodbav:"score"`
+	CreatedDate string  `dynamodbav:"created_date"`
+
+	// CommentCount is the number of comments on the blog, which is kept up
+	// to date by the comments service.
+	CommentCount int64 `dynamodbav:"comment_count"`
+}
+
+// Keys returns the keys of the blog's metadata item, which is listed through
+// GSI1 alongside the blogs of its author. It implements keys.Entity.
+func (b Blog) Keys() keys.Keys {
+	return keys.Keys{
+		Key:    keys.BlogKey(b.ID.UUID),
+		GSI1PK: keys.Blogs,
+		GSI1SK: keys.User(b.UserID.UUID),
+	}
+}
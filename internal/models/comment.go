@@ -0,0 +1,22 @@
+package models
+
+import "github.com/agallagher-captech/blog/internal/keys"
+
+type Comment struct {
+	DynamoDBBase
+	BlogID      UUID   `dynamodbav:"blog_id"`
+	UserID      UUID   `dynamodbav:"user_id"`
+	Message     string `dynamodbav:"message"`
+	CreatedDate string `dynamodbav:"created_date"`
+}
+
+// Keys returns the keys of the comment's item. Comments live in the partition
+// of their blog and are listed through GSI1 by the commenting user. It
+// implements keys.Entity.
+func (c Comment) Keys() keys.Keys {
+	return keys.Keys{
+		Key:    keys.CommentKey(c.BlogID.UUID, c.UserID.UUID),
+		GSI1PK: keys.Comments,
+		GSI1SK: keys.User(c.UserID.UUID),
+	}
+}
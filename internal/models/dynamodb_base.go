@@ -1,8 +1,23 @@
 package models
 
+import "github.com/agallagher-captech/blog/internal/keys"
+
+// DynamoDBBase holds the storage attributes shared by every indexed model.
+// Its keys are derived by keys.Marshal from the Keys method of the model that
+// embeds it, so they never need to be set by hand.
 type DynamoDBBase struct {
-	PK     string `dynamodbav:"PK"`
-	SK     string `dynamodbav:"SK"`
-	GSI1PK string `dynamodbav:"GSI1PK"`
-	GSI1SK string `dynamodbav:"GSI1SK"`
+	PK      string `dynamodbav:"PK"`
+	SK      string `dynamodbav:"SK"`
+	GSI1PK  string `dynamodbav:"GSI1PK"`
+	GSI1SK  string `dynamodbav:"GSI1SK"`
+	Version int64  `dynamodbav:"version"` // incremented on every write, 0 for items written before versioning until they are migrated
+}
+
+// SetKeys stores the provided keys on the model. It implements keys.Entity
+// for the models that embed DynamoDBBase.
+func (b *DynamoDBBase) SetKeys(k keys.Keys) {
+	b.PK = k.PK
+	b.SK = k.SK
+	b.GSI1PK = k.GSI1PK
+	b.GSI1SK = k.GSI1SK
 }
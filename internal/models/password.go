@@ -0,0 +1,25 @@
+package models
+
+import "golang.org/x/crypto/bcrypt"
+
+// HashPassword returns the bcrypt hash of the provided plaintext password. The
+// hash is what gets stored on a User, never the plaintext.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsHashedPassword reports whether the provided value is already a bcrypt hash.
+func IsHashedPassword(password string) bool {
+	_, err := bcrypt.Cost([]byte(password))
+	return err == nil
+}
+
+// VerifyPassword reports whether the provided plaintext password matches the
+// hash stored on the user.
+func (u User) VerifyPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)) == nil
+}
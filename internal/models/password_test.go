@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUser_VerifyPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse battery")
+	assert.NoError(t, err, "unexpected error hashing password")
+	assert.True(t, IsHashedPassword(hash), "hash was not recognised as a bcrypt hash")
+	assert.False(t, IsHashedPassword("correct horse battery"), "plaintext recognised as a hash")
+
+	tests := map[string]struct {
+		password string
+		want     bool
+	}{
+		"matching password":  {password: "correct horse battery", want: true},
+		"different password": {password: "incorrect horse battery", want: false},
+		"empty password":     {password: "", want: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			user := User{Password: hash}
+
+			assert.Equal(t, tc.want, user.VerifyPassword(tc.password), "verification mismatch")
+		})
+	}
+}
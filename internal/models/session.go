@@ -0,0 +1,29 @@
+package models
+
+import "github.com/agallagher-captech/blog/internal/keys"
+
+// Session is a refresh token issued to a user. It is stored in the user's
+// partition under SK SESSION#<token id> and removed when the token is rotated
+// or revoked. Sessions are not indexed, so unlike the other models it does not
+// embed DynamoDBBase and never writes empty GSI1 keys.
+type Session struct {
+	PK        string `dynamodbav:"PK"`
+	SK        string `dynamodbav:"SK"`
+	ID        string `dynamodbav:"session_id"`
+	UserID    UUID   `dynamodbav:"user_id"`
+	CreatedAt int64  `dynamodbav:"created_at"`
+	ExpiresAt int64  `dynamodbav:"expires_at"`
+}
+
+// Keys returns the primary key of the session's item. It implements
+// keys.Entity.
+func (s Session) Keys() keys.Keys {
+	return keys.Keys{Key: keys.SessionKey(s.UserID.UUID, s.ID)}
+}
+
+// SetKeys stores the primary key of the provided keys on the session. It
+// implements keys.Entity.
+func (s *Session) SetKeys(k keys.Keys) {
+	s.PK = k.PK
+	s.SK = k.SK
+}
@@ -1,9 +1,31 @@
 package models
 
+import (
+	"strings"
+
+	"github.com/agallagher-captech/blog/internal/keys"
+)
+
 type User struct {
 	DynamoDBBase
 	ID       UUID   `dynamodbav:"user_id"`
 	Name     string `dynamodbav:"name"`
 	Email    string `dynamodbav:"email"`
-	Password string `dynamodbav:"password"`
+	Password string `dynamodbav:"password"` // bcrypt hash, see HashPassword
+}
+
+// Keys returns the keys of the user's profile item, which is listed through
+// GSI1 with every other user. It implements keys.Entity.
+func (u User) Keys() keys.Keys {
+	return keys.Keys{
+		Key:    keys.UserKey(u.ID.UUID),
+		GSI1PK: keys.Users,
+		GSI1SK: keys.User(u.ID.UUID), // GSI1SK must be unique for each user
+	}
+}
+
+// NormalizeEmail returns the canonical form of an email address, under which
+// it is reserved by a single user.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
@@ -33,6 +33,8 @@ func (u *UUID) UnmarshalDynamoDBAttributeValue(av types.AttributeValue) error {
 
 // MarshalDynamoDBAttributeValue marshals a UUID into a DynamoDB
 // types.AttributeValue. It implements the attributevalue.Marshaler interface.
-func (u *UUID) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+// It has a value receiver so that UUID fields of structs marshalled by value
+// are stored as strings too.
+func (u UUID) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
 	return &types.AttributeValueMemberS{Value: u.UUID.String()}, nil
 }
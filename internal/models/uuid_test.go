@@ -0,0 +1,23 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUUID_MarshalDynamoDBAttributeValue(t *testing.T) {
+	id := uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")
+
+	// Users are marshalled by value, so the field is not addressable
+	item, err := attributevalue.MarshalMap(User{ID: UUID{UUID: id}})
+	assert.NoError(t, err, "unexpected error marshalling user")
+	assert.Equal(t, &types.AttributeValueMemberS{Value: id.String()}, item["user_id"], "id was not stored as a string")
+
+	var user User
+	assert.NoError(t, attributevalue.UnmarshalMap(item, &user), "unexpected error unmarshalling user")
+	assert.Equal(t, id, user.ID.UUID, "id did not round trip")
+}
@@ -0,0 +1,54 @@
+// Package problem writes error responses as RFC 7807 problem details, so that
+// every failed request gets the same machine-readable body regardless of where
+// it failed.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ContentType is the media type of a problem details body.
+const ContentType = "application/problem+json"
+
+// DefaultType is the problem type used when the status code alone describes
+// the problem. Its title is always the status text of the code.
+const DefaultType = "about:blank"
+
+// Details is an RFC 7807 problem details object. Errors is an extension member
+// carrying field-level validation problems, keyed by field name.
+type Details struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// New returns the Details of a problem with the provided status that occurred
+// while handling r. The detail is a human-readable explanation specific to
+// this occurrence and may be empty.
+func New(r *http.Request, status int, detail string) Details {
+	return Details{
+		Type:     DefaultType,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	}
+}
+
+// Write writes the problem to w as the response.
+func (d Details) Write(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(d.Status)
+	return json.NewEncoder(w).Encode(d)
+}
+
+// Write writes a problem with the provided status and detail as the response
+// to r.
+func Write(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	_ = New(r, status, detail).Write(w)
+}
@@ -0,0 +1,44 @@
+package problem
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	tests := map[string]struct {
+		details  func(r *http.Request) Details
+		wantBody string
+	}{
+		"status only": {
+			details: func(r *http.Request) Details {
+				return New(r, http.StatusInternalServerError, "")
+			},
+			wantBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/api/users"}`,
+		},
+		"with field errors": {
+			details: func(r *http.Request) Details {
+				d := New(r, http.StatusBadRequest, "request body failed validation")
+				d.Errors = map[string]string{"email": "invalid email format"}
+				return d
+			},
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"request body failed validation","instance":"/api/users","errors":{"email":"invalid email format"}}`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/users?limit=5", nil)
+			rec := httptest.NewRecorder()
+
+			err := tc.details(req).Write(rec)
+
+			assert.NoError(t, err)
+			assert.Equal(t, ContentType, rec.Header().Get("Content-Type"), "content type mismatch")
+			assert.JSONEq(t, tc.wantBody, strings.Trim(rec.Body.String(), "\n"), "body mismatch")
+		})
+	}
+}
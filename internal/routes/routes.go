@@ -6,6 +6,7 @@ import (
 
 	_ "github.com/agallagher-captech/blog/cmd/api/docs"
 	"github.com/agallagher-captech/blog/internal/handlers"
+	"github.com/agallagher-captech/blog/internal/middleware"
 	"github.com/agallagher-captech/blog/internal/services"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
@@ -23,9 +24,25 @@ import (
 //	@license.url				http://www.apache.org/licenses/LICENSE-2.0.html
 //	@host						localhost:8080
 //	@BasePath					/api
+//	@securityDefinitions.apikey	BearerAuth
+//	@in							header
+//	@name						Authorization
+//	@description				Access token issued by /auth/login, sent as "Bearer <token>"
 //	@externalDocs.description	OpenAPI
 //	@externalDocs.url			https://swagger.io/resources/open-api/
-func AddRoutes(mux *http.ServeMux, logger *slog.Logger, usersService *services.UsersService, baseURL string) {
+func AddRoutes(
+	mux *http.ServeMux,
+	logger *slog.Logger,
+	usersService *services.UsersService,
+	blogsService *services.BlogsService,
+	commentsService *services.CommentsService,
+	authService *services.AuthService,
+	baseURL string,
+) {
+	// Routes wrapped with protected require a valid access token, all other
+	// routes are public.
+	protected := middleware.Authenticate(logger, authService)
+
 	// Swagger docs
 	mux.Handle(
 		"GET /swagger/",
@@ -36,9 +53,44 @@ func AddRoutes(mux *http.ServeMux, logger *slog.Logger, usersService *services.U
 	// Health check
 	mux.Handle("GET /api/health", handlers.HandleHealthCheck(logger))
 
+	// Authentication
+	mux.Handle("POST /api/auth/login", handlers.HandleLogin(logger, authService))
+	mux.Handle("POST /api/auth/refresh", handlers.HandleRefresh(logger, authService))
+	mux.Handle("POST /api/auth/logout", handlers.HandleLogout(logger, authService))
+
 	// Read a user
 	mux.Handle("GET /api/users/{id}", handlers.HandleReadUser(logger, usersService))
 
+	// List users
+	mux.Handle("GET /api/users", handlers.HandleListUsers(logger, usersService))
+
 	// Create a user
-	mux.Handle("/api/users", handlers.HandleCreateUser(logger, usersService))
+	mux.Handle("POST /api/users", handlers.HandleCreateUser(logger, usersService))
+
+	// Update a user
+	mux.Handle("PUT /api/users/{id}", protected(handlers.HandleUpdateUser(logger, usersService)))
+
+	// Partially update a user
+	mux.Handle("PATCH /api/users/{id}", protected(handlers.HandlePatchUser(logger, usersService)))
+
+	// Delete a user and everything they own
+	mux.Handle("DELETE /api/users/{id}", protected(handlers.HandleDeleteUser(logger, usersService)))
+
+	// Partially update a blog
+	mux.Handle("PATCH /api/blogs/{id}", protected(handlers.HandlePatchBlog(logger, blogsService)))
+
+	// Delete a blog and its comments
+	mux.Handle("DELETE /api/blogs/{id}", protected(handlers.HandleDeleteBlog(logger, blogsService)))
+
+	// Partially update a comment
+	mux.Handle(
+		"PATCH /api/blogs/{blog_id}/comments/{user_id}",
+		protected(handlers.HandlePatchComment(logger, commentsService)),
+	)
+
+	// Delete a comment
+	mux.Handle(
+		"DELETE /api/blogs/{blog_id}/comments/{user_id}",
+		protected(handlers.HandleDeleteComment(logger, commentsService)),
+	)
 }
@@ -0,0 +1,138 @@
+package seed
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// decodeItem decodes an item whose attribute values are in the DynamoDB JSON
+// format, e.g. {"name": {"S": "Emma"}}.
+func decodeItem(raw map[string]json.RawMessage) (map[string]types.AttributeValue, error) {
+	item := make(map[string]types.AttributeValue, len(raw))
+	for name, value := range raw {
+		decoded, err := decodeAttributeValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", name, err)
+		}
+		item[name] = decoded
+	}
+	return item, nil
+}
+
+// decodeAttributeValue decodes a single attribute value in the DynamoDB JSON
+// format, an object with one member named after the value's type.
+func decodeAttributeValue(raw json.RawMessage) (types.AttributeValue, error) {
+	var member map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &member); err != nil {
+		return nil, err
+	}
+	if len(member) != 1 {
+		return nil, fmt.Errorf("an attribute value must have exactly one type, got %d", len(member))
+	}
+
+	for typ, value := range member {
+		switch typ {
+		case "S":
+			var s string
+			err := json.Unmarshal(value, &s)
+			return &types.AttributeValueMemberS{Value: s}, err
+		case "N":
+			var n string
+			err := json.Unmarshal(value, &n)
+			return &types.AttributeValueMemberN{Value: n}, err
+		case "B":
+			var b []byte
+			err := json.Unmarshal(value, &b)
+			return &types.AttributeValueMemberB{Value: b}, err
+		case "BOOL":
+			var b bool
+			err := json.Unmarshal(value, &b)
+			return &types.AttributeValueMemberBOOL{Value: b}, err
+		case "NULL":
+			var b bool
+			err := json.Unmarshal(value, &b)
+			return &types.AttributeValueMemberNULL{Value: b}, err
+		case "SS":
+			var ss []string
+			err := json.Unmarshal(value, &ss)
+			return &types.AttributeValueMemberSS{Value: ss}, err
+		case "NS":
+			var ns []string
+			err := json.Unmarshal(value, &ns)
+			return &types.AttributeValueMemberNS{Value: ns}, err
+		case "BS":
+			var bs [][]byte
+			err := json.Unmarshal(value, &bs)
+			return &types.AttributeValueMemberBS{Value: bs}, err
+		case "L":
+			var elements []json.RawMessage
+			if err := json.Unmarshal(value, &elements); err != nil {
+				return nil, err
+			}
+			list := &types.AttributeValueMemberL{Value: make([]types.AttributeValue, len(elements))}
+			for i, element := range elements {
+				decoded, err := decodeAttributeValue(element)
+				if err != nil {
+					return nil, err
+				}
+				list.Value[i] = decoded
+			}
+			return list, nil
+		case "M":
+			var members map[string]json.RawMessage
+			if err := json.Unmarshal(value, &members); err != nil {
+				return nil, err
+			}
+			m, err := decodeItem(members)
+			return &types.AttributeValueMemberM{Value: m}, err
+		default:
+			return nil, fmt.Errorf("unknown attribute value type %q", typ)
+		}
+	}
+	return nil, nil
+}
+
+// encodeItem converts an item into the DynamoDB JSON format read by
+// decodeItem.
+func encodeItem(item map[string]types.AttributeValue) map[string]any {
+	encoded := make(map[string]any, len(item))
+	for name, value := range item {
+		encoded[name] = encodeAttributeValue(value)
+	}
+	return encoded
+}
+
+// encodeAttributeValue converts a single attribute value into the DynamoDB
+// JSON format read by decodeAttributeValue.
+func encodeAttributeValue(value types.AttributeValue) map[string]any {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return map[string]any{"S": v.Value}
+	case *types.AttributeValueMemberN:
+		return map[string]any{"N": v.Value}
+	case *types.AttributeValueMemberB:
+		return map[string]any{"B": v.Value}
+	case *types.AttributeValueMemberBOOL:
+		return map[string]any{"BOOL": v.Value}
+	case *types.AttributeValueMemberNULL:
+		return map[string]any{"NULL": v.Value}
+	case *types.AttributeValueMemberSS:
+		return map[string]any{"SS": v.Value}
+	case *types.AttributeValueMemberNS:
+		return map[string]any{"NS": v.Value}
+	case *types.AttributeValueMemberBS:
+		return map[string]any{"BS": v.Value}
+	case *types.AttributeValueMemberL:
+		list := make([]any, len(v.Value))
+		for i, element := range v.Value {
+			list[i] = encodeAttributeValue(element)
+		}
+		return map[string]any{"L": list}
+	case *types.AttributeValueMemberM:
+		return map[string]any{"M": encodeItem(v.Value)}
+	default:
+		return nil
+	}
+}
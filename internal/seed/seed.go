@@ -0,0 +1,284 @@
+// Package seed reads and writes the seed data in ./dynamodb_seed: a table
+// schema in the JSON format of a CreateTable request, and items in the JSON
+// format of BatchWriteItem requests, one request per line. Both are the
+// formats accepted by the AWS CLI.
+package seed
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+const (
+	// SchemaFile is the name of the table schema in a seed directory.
+	SchemaFile = "table_schema.json"
+
+	// ItemsFile is the name of the seed items in a seed directory.
+	ItemsFile = "batch_items.json"
+
+	// BatchSize is the most write requests DynamoDB accepts in a single
+	// BatchWriteItem request.
+	BatchSize = 25
+)
+
+// ReadDir reads the schema and items files in dir, the layout of
+// ./dynamodb_seed.
+func ReadDir(dir string) (*dynamodb.CreateTableInput, map[string][]types.WriteRequest, error) {
+	schemaFile, err := os.Open(filepath.Join(dir, SchemaFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("[in seed.ReadDir] failed to open schema: %w", err)
+	}
+	defer schemaFile.Close()
+	schema, err := ReadTableSchema(schemaFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	itemsFile, err := os.Open(filepath.Join(dir, ItemsFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("[in seed.ReadDir] failed to open items: %w", err)
+	}
+	defer itemsFile.Close()
+	requests, err := ReadBatchItems(itemsFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return schema, requests, nil
+}
+
+// Rename renames the table of a schema, along with the write requests for it,
+// and the global secondary indexes named in indexes, so that seed data can be
+// loaded into a table with other names. The schema and requests are changed
+// in place.
+func Rename(schema *dynamodb.CreateTableInput, requests map[string][]types.WriteRequest, table string, indexes map[string]string) {
+	from := aws.StringValue(schema.TableName)
+	if from != table {
+		schema.TableName = aws.String(table)
+		if writes, ok := requests[from]; ok {
+			requests[table] = append(requests[table], writes...)
+			delete(requests, from)
+		}
+	}
+
+	for i, gsi := range schema.GlobalSecondaryIndexes {
+		if name, ok := indexes[aws.StringValue(gsi.IndexName)]; ok {
+			schema.GlobalSecondaryIndexes[i].IndexName = aws.String(name)
+		}
+	}
+}
+
+// ReadTableSchema decodes a CreateTable request, such as
+// dynamodb_seed/table_schema.json.
+func ReadTableSchema(r io.Reader) (*dynamodb.CreateTableInput, error) {
+	var input dynamodb.CreateTableInput
+	if err := json.NewDecoder(r).Decode(&input); err != nil {
+		return nil, fmt.Errorf("[in seed.ReadTableSchema] failed to decode schema: %w", err)
+	}
+	return &input, nil
+}
+
+// tableSchema is the part of a table's description that is needed to create
+// it again.
+type tableSchema struct {
+	TableName              *string                     `json:"TableName"`
+	KeySchema              []types.KeySchemaElement    `json:"KeySchema"`
+	AttributeDefinitions   []types.AttributeDefinition `json:"AttributeDefinitions"`
+	LocalSecondaryIndexes  []secondaryIndex            `json:"LocalSecondaryIndexes,omitempty"`
+	GlobalSecondaryIndexes []secondaryIndex            `json:"GlobalSecondaryIndexes,omitempty"`
+	BillingMode            types.BillingMode           `json:"BillingMode"`
+}
+
+// secondaryIndex is the part of a secondary index's description that is
+// needed to create it again.
+type secondaryIndex struct {
+	IndexName  *string                  `json:"IndexName"`
+	KeySchema  []types.KeySchemaElement `json:"KeySchema"`
+	Projection *types.Projection        `json:"Projection"`
+}
+
+// WriteTableSchema encodes the CreateTable request that creates the described
+// table again, in the format read by ReadTableSchema. The table is always
+// created with on-demand billing.
+func WriteTableSchema(w io.Writer, table *types.TableDescription) error {
+	schema := tableSchema{
+		TableName:            table.TableName,
+		KeySchema:            table.KeySchema,
+		AttributeDefinitions: table.AttributeDefinitions,
+		BillingMode:          types.BillingModePayPerRequest,
+	}
+	for _, lsi := range table.LocalSecondaryIndexes {
+		schema.LocalSecondaryIndexes = append(schema.LocalSecondaryIndexes, secondaryIndex{
+			IndexName:  lsi.IndexName,
+			KeySchema:  lsi.KeySchema,
+			Projection: lsi.Projection,
+		})
+	}
+	for _, gsi := range table.GlobalSecondaryIndexes {
+		schema.GlobalSecondaryIndexes = append(schema.GlobalSecondaryIndexes, secondaryIndex{
+			IndexName:  gsi.IndexName,
+			KeySchema:  gsi.KeySchema,
+			Projection: gsi.Projection,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(schema); err != nil {
+		return fmt.Errorf("[in seed.WriteTableSchema] failed to encode schema: %w", err)
+	}
+	return nil
+}
+
+// request is a write request of a seed line, with its attribute values still
+// in their JSON form.
+type request struct {
+	PutRequest *struct {
+		Item map[string]json.RawMessage `json:"Item"`
+	} `json:"PutRequest"`
+	DeleteRequest *struct {
+		Key map[string]json.RawMessage `json:"Key"`
+	} `json:"DeleteRequest"`
+}
+
+// ReadBatchItems decodes every line of a seed items file such as
+// dynamodb_seed/batch_items.json, and returns the write requests of each table
+// in the order they appear.
+func ReadBatchItems(r io.Reader) (map[string][]types.WriteRequest, error) {
+	requests := make(map[string][]types.WriteRequest)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var batch map[string][]request
+		if err := json.Unmarshal(scanner.Bytes(), &batch); err != nil {
+			return nil, fmt.Errorf("[in seed.ReadBatchItems] failed to decode line %d: %w", line, err)
+		}
+		for _, table := range sortedKeys(batch) {
+			for _, write := range batch[table] {
+				decoded, err := decodeWriteRequest(write)
+				if err != nil {
+					return nil, fmt.Errorf("[in seed.ReadBatchItems] failed to decode line %d: %w", line, err)
+				}
+				requests[table] = append(requests[table], decoded)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("[in seed.ReadBatchItems] failed to scan items: %w", err)
+	}
+	return requests, nil
+}
+
+// WriteBatchItems encodes items as put requests for a table, one item per
+// line, in the format read by ReadBatchItems.
+func WriteBatchItems(w io.Writer, tableName string, items []map[string]types.AttributeValue) error {
+	type putRequest struct {
+		PutRequest struct {
+			Item map[string]any `json:"Item"`
+		} `json:"PutRequest"`
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, item := range items {
+		var put putRequest
+		put.PutRequest.Item = encodeItem(item)
+		if err := encoder.Encode(map[string][]putRequest{tableName: {put}}); err != nil {
+			return fmt.Errorf("[in seed.WriteBatchItems] failed to encode item: %w", err)
+		}
+	}
+	return nil
+}
+
+// Batches splits write requests into BatchWriteItem requests of at most size
+// write requests, each for a single table. Tables are written in alphabetical
+// order, and the requests of a table in their original order.
+func Batches(requests map[string][]types.WriteRequest, size int) []map[string][]types.WriteRequest {
+	var batches []map[string][]types.WriteRequest
+	for _, table := range sortedKeys(requests) {
+		writes := requests[table]
+		for start := 0; start < len(writes); start += size {
+			end := min(start+size, len(writes))
+			batches = append(batches, map[string][]types.WriteRequest{table: writes[start:end]})
+		}
+	}
+	return batches
+}
+
+// BatchWriter is the part of the DynamoDB API used to write seed items. It
+// should send the items DynamoDB leaves unprocessed again, as retry.Client
+// does, since Write does not.
+type BatchWriter interface {
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+// Write writes the requests in batches of BatchSize, in the order of Batches,
+// and returns the number of batches written. An error is returned if a batch
+// fails or still has unprocessed items once the client returns.
+func Write(ctx context.Context, client BatchWriter, requests map[string][]types.WriteRequest) (int, error) {
+	batches := Batches(requests, BatchSize)
+	for i, batch := range batches {
+		result, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: batch})
+		if err != nil {
+			return i, fmt.Errorf("[in seed.Write] failed to write batch %d of %d: %w", i+1, len(batches), err)
+		}
+		if unprocessed := countRequests(result.UnprocessedItems); unprocessed > 0 {
+			return i, fmt.Errorf("[in seed.Write] %d items of batch %d of %d were left unprocessed", unprocessed, i+1, len(batches))
+		}
+	}
+	return len(batches), nil
+}
+
+// countRequests returns the number of write requests in a batch.
+func countRequests(batch map[string][]types.WriteRequest) int {
+	count := 0
+	for _, requests := range batch {
+		count += len(requests)
+	}
+	return count
+}
+
+// sortedKeys returns the keys of m in alphabetical order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// decodeWriteRequest converts a seed write request into a WriteRequest.
+func decodeWriteRequest(write request) (types.WriteRequest, error) {
+	switch {
+	case write.PutRequest != nil:
+		item, err := decodeItem(write.PutRequest.Item)
+		if err != nil {
+			return types.WriteRequest{}, err
+		}
+		return types.WriteRequest{PutRequest: &types.PutRequest{Item: item}}, nil
+	case write.DeleteRequest != nil:
+		key, err := decodeItem(write.DeleteRequest.Key)
+		if err != nil {
+			return types.WriteRequest{}, err
+		}
+		return types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key}}, nil
+	default:
+		return types.WriteRequest{}, fmt.Errorf("write request has neither a PutRequest nor a DeleteRequest")
+	}
+}
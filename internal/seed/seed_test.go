@@ -0,0 +1,247 @@
+package seed
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedDir is the seed data of the repository, relative to this package.
+const seedDir = "../../dynamodb_seed"
+
+func TestReadTableSchema(t *testing.T) {
+	f, err := os.Open(filepath.Join(seedDir, SchemaFile))
+	require.NoError(t, err)
+	defer f.Close()
+
+	schema, err := ReadTableSchema(f)
+	require.NoError(t, err)
+	assert.Equal(t, "BlogContent", aws.StringValue(schema.TableName), "table name did not match")
+	assert.Len(t, schema.KeySchema, 2, "key schema did not match")
+	require.Len(t, schema.GlobalSecondaryIndexes, 1, "indexes did not match")
+	assert.Equal(t, "GSI1", aws.StringValue(schema.GlobalSecondaryIndexes[0].IndexName), "index name did not match")
+	assert.Equal(t, types.BillingModePayPerRequest, schema.BillingMode, "billing mode did not match")
+}
+
+func TestWriteTableSchema(t *testing.T) {
+	f, err := os.Open(filepath.Join(seedDir, SchemaFile))
+	require.NoError(t, err)
+	defer f.Close()
+	want, err := ReadTableSchema(f)
+	require.NoError(t, err)
+
+	// A described table holds more than the schema, which must not be
+	// exported.
+	description := &types.TableDescription{
+		TableName:            want.TableName,
+		TableStatus:          types.TableStatusActive,
+		ItemCount:            aws.Int64(86),
+		KeySchema:            want.KeySchema,
+		AttributeDefinitions: want.AttributeDefinitions,
+		BillingModeSummary:   &types.BillingModeSummary{BillingMode: types.BillingModeProvisioned},
+	}
+	for _, gsi := range want.GlobalSecondaryIndexes {
+		description.GlobalSecondaryIndexes = append(description.GlobalSecondaryIndexes, types.GlobalSecondaryIndexDescription{
+			IndexName:   gsi.IndexName,
+			IndexStatus: types.IndexStatusActive,
+			KeySchema:   gsi.KeySchema,
+			Projection:  gsi.Projection,
+		})
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, WriteTableSchema(&out, description))
+	assert.NotContains(t, out.String(), "ItemCount", "table status was exported")
+	assert.NotContains(t, out.String(), "LocalSecondaryIndexes", "empty indexes were exported")
+
+	got, err := ReadTableSchema(&out)
+	require.NoError(t, err)
+	assert.Equal(t, want, got, "schema did not round trip")
+}
+
+func TestReadBatchItems(t *testing.T) {
+	tests := map[string]struct {
+		input   string
+		want    map[string][]types.WriteRequest
+		wantErr bool
+	}{
+		"put and delete": {
+			input: `{"Blogs":[{"PutRequest":{"Item":{"PK":{"S":"BLOG#1"},"score":{"N":"9.5"},"tags":{"SS":["home"]}}}}]}
+
+{"Blogs":[{"DeleteRequest":{"Key":{"PK":{"S":"BLOG#2"}}}}],"Users":[{"PutRequest":{"Item":{"PK":{"S":"USER#1"},"profile":{"M":{"bio":{"NULL":true}}}}}}]}`,
+			want: map[string][]types.WriteRequest{
+				"Blogs": {
+					{PutRequest: &types.PutRequest{Item: map[string]types.AttributeValue{
+						"PK":    &types.AttributeValueMemberS{Value: "BLOG#1"},
+						"score": &types.AttributeValueMemberN{Value: "9.5"},
+						"tags":  &types.AttributeValueMemberSS{Value: []string{"home"}},
+					}}},
+					{DeleteRequest: &types.DeleteRequest{Key: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: "BLOG#2"},
+					}}},
+				},
+				"Users": {
+					{PutRequest: &types.PutRequest{Item: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: "USER#1"},
+						"profile": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+							"bio": &types.AttributeValueMemberNULL{Value: true},
+						}},
+					}}},
+				},
+			},
+		},
+		"invalid json": {
+			input:   `{"Blogs":[`,
+			wantErr: true,
+		},
+		"unknown type": {
+			input:   `{"Blogs":[{"PutRequest":{"Item":{"PK":{"X":"BLOG#1"}}}}]}`,
+			wantErr: true,
+		},
+		"several types": {
+			input:   `{"Blogs":[{"PutRequest":{"Item":{"PK":{"S":"BLOG#1","N":"1"}}}}]}`,
+			wantErr: true,
+		},
+		"empty request": {
+			input:   `{"Blogs":[{}]}`,
+			wantErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ReadBatchItems(strings.NewReader(tc.input))
+
+			if tc.wantErr {
+				assert.Error(t, err, "expected an error")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.want, got, "requests did not match")
+		})
+	}
+}
+
+func TestWriteBatchItems(t *testing.T) {
+	f, err := os.Open(filepath.Join(seedDir, ItemsFile))
+	require.NoError(t, err)
+	defer f.Close()
+	requests, err := ReadBatchItems(f)
+	require.NoError(t, err)
+
+	var items []map[string]types.AttributeValue
+	for _, request := range requests["BlogContent"] {
+		items = append(items, request.PutRequest.Item)
+	}
+	items = append(items, map[string]types.AttributeValue{
+		"PK":      &types.AttributeValueMemberS{Value: "BLOG#<&>"},
+		"flags":   &types.AttributeValueMemberBOOL{Value: true},
+		"data":    &types.AttributeValueMemberB{Value: []byte{0, 1, 2}},
+		"scores":  &types.AttributeValueMemberNS{Value: []string{"1", "2.5"}},
+		"history": &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberN{Value: "1"}}},
+	})
+
+	var out bytes.Buffer
+	require.NoError(t, WriteBatchItems(&out, "BlogContent", items))
+	assert.Contains(t, out.String(), "BLOG#<&>", "html characters were escaped")
+	assert.Equal(t, len(items), strings.Count(out.String(), "\n"), "expected one item per line")
+
+	got, err := ReadBatchItems(&out)
+	require.NoError(t, err)
+	require.Len(t, got["BlogContent"], len(items))
+	for i, request := range got["BlogContent"] {
+		assert.Equal(t, items[i], request.PutRequest.Item, "item %d did not round trip", i)
+	}
+}
+
+func TestBatches(t *testing.T) {
+	requests := map[string][]types.WriteRequest{}
+	for i := 0; i < 30; i++ {
+		requests["Blogs"] = append(requests["Blogs"], types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+			Key: map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: fmt.Sprint(i)}},
+		}})
+	}
+	requests["Authors"] = requests["Blogs"][:3]
+
+	batches := Batches(requests, BatchSize)
+
+	require.Len(t, batches, 3, "expected one batch of authors and two of blogs")
+	assert.Equal(t, requests["Authors"], batches[0]["Authors"], "first batch did not match")
+	assert.Equal(t, requests["Blogs"][:25], batches[1]["Blogs"], "second batch did not match")
+	assert.Equal(t, requests["Blogs"][25:], batches[2]["Blogs"], "third batch did not match")
+	for _, batch := range batches {
+		assert.Len(t, batch, 1, "a batch must only write to one table")
+	}
+}
+
+// batchWriterFunc adapts a function to the BatchWriter interface.
+type batchWriterFunc func(params *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+
+func (f batchWriterFunc) BatchWriteItem(_ context.Context, params *dynamodb.BatchWriteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	return f(params)
+}
+
+func TestWrite(t *testing.T) {
+	requests := map[string][]types.WriteRequest{}
+	for i := 0; i < 30; i++ {
+		requests["Blogs"] = append(requests["Blogs"], types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+			Key: map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: fmt.Sprint(i)}},
+		}})
+	}
+
+	tests := map[string]struct {
+		unprocessed int
+		fail        error
+		wantBatches int
+		wantErr     string
+	}{
+		"written": {
+			wantBatches: 2,
+		},
+		"unprocessed items": {
+			unprocessed: 2,
+			wantErr:     "2 items of batch 1 of 2 were left unprocessed",
+		},
+		"failed batch": {
+			fail:    fmt.Errorf("connection refused"),
+			wantErr: "failed to write batch 1 of 2: connection refused",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var written []map[string][]types.WriteRequest
+			client := batchWriterFunc(func(params *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+				if tc.fail != nil {
+					return nil, tc.fail
+				}
+				written = append(written, params.RequestItems)
+				output := &dynamodb.BatchWriteItemOutput{}
+				if tc.unprocessed > 0 {
+					output.UnprocessedItems = map[string][]types.WriteRequest{
+						"Blogs": params.RequestItems["Blogs"][:tc.unprocessed],
+					}
+				}
+				return output, nil
+			})
+
+			batches, err := Write(context.TODO(), client, requests)
+
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr, "error did not match")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantBatches, batches, "batch count mismatch")
+			assert.Equal(t, Batches(requests, BatchSize), written, "written batches did not match")
+		})
+	}
+}
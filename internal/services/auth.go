@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/agallagher-captech/blog/internal/keys"
+	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidToken = fmt.Errorf("invalid token")
+
+// invalidToken returns an *Error reporting that the refresh token the provided
+// operation was given belongs to a session that no longer exists.
+func invalidToken(op, entity string) error {
+	return &Error{Kind: KindUnauthenticated, Op: op, Entity: entity, Err: ErrInvalidToken}
+}
+
+const (
+	accessTokenType  = "access"
+	refreshTokenType = "refresh"
+)
+
+// passwordVerifier represents a type capable of checking a user's credentials.
+type passwordVerifier interface {
+	VerifyPassword(ctx context.Context, email, password string) (models.User, error)
+}
+
+// Tokens is the pair of signed tokens issued to an authenticated user.
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// tokenClaims are the JWT claims carried by both access and refresh tokens.
+// The subject is the user id and the type tells the two kinds apart.
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Type string `json:"typ"`
+}
+
+// AuthService is a service capable of issuing, rotating and revoking the
+// tokens used to authenticate users.
+type AuthService struct {
+	logger          *slog.Logger
+	client          dynamoClient
+	table           Table
+	users           passwordVerifier
+	signingKey      []byte
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+}
+
+// NewAuthService creates a new AuthService and returns a pointer to it.
+func NewAuthService(
+	logger *slog.Logger,
+	client dynamoClient,
+	table Table,
+	users passwordVerifier,
+	signingKey string,
+	accessTokenTTL time.Duration,
+	refreshTokenTTL time.Duration,
+) *AuthService {
+	return &AuthService{
+		logger:          logger,
+		client:          client,
+		table:           table,
+		users:           users,
+		signingKey:      []byte(signingKey),
+		accessTokenTTL:  accessTokenTTL,
+		refreshTokenTTL: refreshTokenTTL,
+	}
+}
+
+// Login checks the provided credentials and issues a new pair of tokens for
+// the user. ErrInvalidCredentials is returned if the credentials are wrong.
+func (s *AuthService) Login(ctx context.Context, email, password string) (Tokens, error) {
+	s.logger.InfoContext(ctx, "Logging in user")
+
+	user, err := s.users.VerifyPassword(ctx, email, password)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			return Tokens{}, ErrInvalidCredentials
+		}
+		return Tokens{}, newError(
+			"AuthService.Login",
+			"session",
+			fmt.Errorf("failed to verify password: %w", err),
+		)
+	}
+
+	tokens, session, err := s.issueTokens(user.ID.UUID)
+	if err != nil {
+		return Tokens{}, newError(
+			"AuthService.Login",
+			"session",
+			fmt.Errorf("failed to issue tokens: %w", err),
+		)
+	}
+
+	item, err := keys.Marshal(&session)
+	if err != nil {
+		return Tokens{}, newError(
+			"AuthService.Login",
+			"session",
+			fmt.Errorf("failed to marshal session: %w", err),
+		)
+	}
+
+	// Store the session so the refresh token can later be rotated or revoked
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table.Name),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(PK) AND attribute_not_exists(SK)"),
+	})
+	if err != nil {
+		return Tokens{}, newError(
+			"AuthService.Login",
+			"session",
+			fmt.Errorf("failed to put item: %w", err),
+		)
+	}
+
+	return tokens, nil
+}
+
+// Refresh rotates the provided refresh token, revoking it and issuing a new
+// pair of tokens in its place. ErrInvalidToken is returned if the token is
+// malformed, expired or has already been used or revoked.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (Tokens, error) {
+	s.logger.InfoContext(ctx, "Refreshing tokens")
+
+	claims, err := s.parseToken(refreshToken, refreshTokenType)
+	if err != nil {
+		return Tokens{}, err
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return Tokens{}, ErrInvalidToken
+	}
+
+	tokens, session, err := s.issueTokens(userID)
+	if err != nil {
+		return Tokens{}, newError(
+			"AuthService.Refresh",
+			"session",
+			fmt.Errorf("failed to issue tokens: %w", err),
+		)
+	}
+
+	item, err := keys.Marshal(&session)
+	if err != nil {
+		return Tokens{}, newError(
+			"AuthService.Refresh",
+			"session",
+			fmt.Errorf("failed to marshal session: %w", err),
+		)
+	}
+
+	// Swap the old session for the new one. The condition on the delete makes
+	// sure a refresh token can only ever be used once.
+	err = newTransaction(s.table).
+		delete("session", keys.SessionKey(userID, claims.ID), deleteCondition(0)).
+		failWith(invalidToken).
+		put("session", item, condition{}).
+		execute(ctx, s.client, "AuthService.Refresh")
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return tokens, nil
+}
+
+// Logout revokes the provided refresh token. ErrInvalidToken is returned if
+// the token is malformed, expired or has already been revoked.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	s.logger.InfoContext(ctx, "Logging out user")
+
+	claims, err := s.parseToken(refreshToken, refreshTokenType)
+	if err != nil {
+		return err
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	_, err = s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(s.table.Name),
+		Key:                 keys.SessionKey(userID, claims.ID).AttributeValues(),
+		ConditionExpression: aws.String("attribute_exists(PK) AND attribute_exists(SK)"),
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return ErrInvalidToken
+		}
+		return newError(
+			"AuthService.Logout",
+			"session",
+			fmt.Errorf("failed to delete item: %w", err),
+		)
+	}
+
+	return nil
+}
+
+// ParseAccessToken validates the provided access token and returns the id of
+// the user it was issued to. ErrInvalidToken is returned if the token is not a
+// valid, unexpired access token.
+func (s *AuthService) ParseAccessToken(accessToken string) (uuid.UUID, error) {
+	claims, err := s.parseToken(accessToken, accessTokenType)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, ErrInvalidToken
+	}
+
+	return userID, nil
+}
+
+// issueTokens signs a new access and refresh token for the user with the
+// provided id and returns them with the session to store for the refresh
+// token.
+func (s *AuthService) issueTokens(userID uuid.UUID) (Tokens, models.Session, error) {
+	now := time.Now()
+
+	accessToken, err := s.signToken(userID, uuid.NewString(), accessTokenType, now, s.accessTokenTTL)
+	if err != nil {
+		return Tokens{}, models.Session{}, fmt.Errorf("sign access token: %w", err)
+	}
+
+	sessionID := uuid.NewString()
+	refreshToken, err := s.signToken(userID, sessionID, refreshTokenType, now, s.refreshTokenTTL)
+	if err != nil {
+		return Tokens{}, models.Session{}, fmt.Errorf("sign refresh token: %w", err)
+	}
+
+	session := models.Session{
+		ID:        sessionID,
+		UserID:    models.UUID{UUID: userID},
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(s.refreshTokenTTL).Unix(),
+	}
+
+	tokens := Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.accessTokenTTL,
+	}
+
+	return tokens, session, nil
+}
+
+// signToken returns a signed JWT of the provided type for the user with the
+// provided id.
+func (s *AuthService) signToken(userID uuid.UUID, tokenID, tokenType string, now time.Time, ttl time.Duration) (string, error) {
+	claims := tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID.String(),
+			ID:        tokenID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Type: tokenType,
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.signingKey)
+}
+
+// parseToken verifies the signature and expiry of the provided JWT and checks
+// that it is of the expected type. ErrInvalidToken is returned if any check
+// fails.
+func (s *AuthService) parseToken(token, tokenType string) (tokenClaims, error) {
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(
+		token,
+		&claims,
+		func(*jwt.Token) (any, error) { return s.signingKey, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.Type != tokenType || claims.ID == "" {
+		return tokenClaims{}, ErrInvalidToken
+	}
+
+	return claims, nil
+}
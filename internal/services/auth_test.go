@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/agallagher-captech/blog/internal/services/mock"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	testifymock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthService_ParseAccessToken(t *testing.T) {
+	userID := uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")
+	authService := NewAuthService(slog.Default(), nil, DefaultTable(), nil, "test-signing-key", time.Minute, time.Hour)
+
+	tokens, _, err := authService.issueTokens(userID)
+	assert.NoError(t, err, "unexpected error issuing tokens")
+
+	otherKey := NewAuthService(slog.Default(), nil, DefaultTable(), nil, "other-signing-key", time.Minute, time.Hour)
+	foreignTokens, _, err := otherKey.issueTokens(userID)
+	assert.NoError(t, err, "unexpected error issuing tokens")
+
+	expired := NewAuthService(slog.Default(), nil, DefaultTable(), nil, "test-signing-key", -time.Minute, time.Hour)
+	expiredTokens, _, err := expired.issueTokens(userID)
+	assert.NoError(t, err, "unexpected error issuing tokens")
+
+	testcases := map[string]struct {
+		token          string
+		expectedOutput uuid.UUID
+		expectedError  error
+	}{
+		"valid access token": {
+			token:          tokens.AccessToken,
+			expectedOutput: userID,
+		},
+		"refresh token": {
+			token:         tokens.RefreshToken,
+			expectedError: ErrInvalidToken,
+		},
+		"signed with another key": {
+			token:         foreignTokens.AccessToken,
+			expectedError: ErrInvalidToken,
+		},
+		"expired": {
+			token:         expiredTokens.AccessToken,
+			expectedError: ErrInvalidToken,
+		},
+		"malformed": {
+			token:         "not-a-token",
+			expectedError: ErrInvalidToken,
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			output, err := authService.ParseAccessToken(tc.token)
+
+			assert.ErrorIs(t, err, tc.expectedError, "error did not match")
+			assert.Equal(t, tc.expectedOutput, output, "returned user id does not match")
+		})
+	}
+}
+
+func TestAuthService_Refresh(t *testing.T) {
+	userID := uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")
+
+	testcases := map[string]struct {
+		mockError     error
+		expectedError error
+	}{
+		"happy path": {
+			mockError:     nil,
+			expectedError: nil,
+		},
+		"token already used": {
+			mockError: &types.TransactionCanceledException{
+				CancellationReasons: []types.CancellationReason{
+					{Code: aws.String("ConditionalCheckFailed")},
+					{Code: aws.String("None")},
+				},
+			},
+			expectedError: ErrInvalidToken,
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			mockClient := new(mock.DynamoClient)
+			authService := NewAuthService(slog.Default(), mockClient, DefaultTable(), nil, "test-signing-key", time.Minute, time.Hour)
+
+			tokens, session, err := authService.issueTokens(userID)
+			assert.NoError(t, err, "unexpected error issuing tokens")
+
+			mockClient.
+				On("TransactWriteItems", context.TODO(), testifymock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+					sk := input.TransactItems[0].Delete.Key["SK"].(*types.AttributeValueMemberS)
+					return sk.Value == session.Keys().SK
+				})).
+				Return(&dynamodb.TransactWriteItemsOutput{}, tc.mockError).
+				Once()
+
+			output, err := authService.Refresh(context.TODO(), tokens.RefreshToken)
+
+			assert.ErrorIs(t, err, tc.expectedError, "error did not match")
+			if tc.expectedError == nil {
+				assert.NotEqual(t, tokens.RefreshToken, output.RefreshToken, "refresh token was not rotated")
+			} else {
+				var serviceErr *Error
+				require.ErrorAs(t, err, &serviceErr, "error is not a service error")
+				assert.Equal(t, KindUnauthenticated, serviceErr.Kind, "kind did not match")
+				assert.Equal(t, "AuthService.Refresh", serviceErr.Op, "operation was not recorded")
+				assert.Equal(t, "session", serviceErr.Entity, "entity was not recorded")
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
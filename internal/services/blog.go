@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/agallagher-captech/blog/internal/keys"
+	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/google/uuid"
+)
+
+// BlogsService is a service capable of performing CRUD operations for
+// models.Blog models.
+type BlogsService struct {
+	logger *slog.Logger
+	blogs  *Repository[models.Blog, *models.Blog]
+}
+
+// NewBlogsService creates a new BlogsService and returns a pointer to it.
+func NewBlogsService(logger *slog.Logger, client dynamoClient, table Table, cursors *Cursors) *BlogsService {
+	return &BlogsService{
+		logger: logger,
+		blogs:  NewRepository[models.Blog](logger, client, table, cursors, "blog"),
+	}
+}
+
+// CreateBlog attempts to create the provided blog, returning a fully hydrated
+// models.Blog or an error. ErrAlreadyExists is returned if a blog with the
+// same id is already stored.
+func (s *BlogsService) CreateBlog(ctx context.Context, blog models.Blog) (models.Blog, error) {
+	s.logger.InfoContext(ctx, "Creating blog", "id", blog.ID)
+
+	return s.blogs.Create(ctx, blog)
+}
+
+// ReadBlog attempts to read a blog from the database using the provided id. A
+// fully hydrated models.Blog or error is returned.
+func (s *BlogsService) ReadBlog(ctx context.Context, id uuid.UUID) (models.Blog, error) {
+	s.logger.InfoContext(ctx, "Reading blog", "id", id)
+
+	return s.blogs.Get(ctx, keys.BlogKey(id))
+}
+
+// UpdateBlog attempts to perform an update of the blog with the provided id,
+// updating it to reflect the properties on the provided patch object. If the
+// patch has a non-zero Version the blog must still be at that version, or
+// ErrVersionMismatch is returned. The blog's author cannot be changed, so a
+// patch naming another author fails with ErrValidation. A models.Blog or an
+// error is returned.
+func (s *BlogsService) UpdateBlog(ctx context.Context, id uuid.UUID, patch models.Blog) (models.Blog, error) {
+	s.logger.InfoContext(ctx, "Updating blog", "id", id)
+
+	existingBlog, err := s.blogs.Get(ctx, keys.BlogKey(id))
+	if err != nil {
+		return models.Blog{}, err
+	}
+
+	if !checkVersion(patch.Version, existingBlog.Version) {
+		return models.Blog{}, versionMismatch("BlogsService.UpdateBlog", "blog")
+	}
+	if patch.UserID.UUID != uuid.Nil && patch.UserID != existingBlog.UserID {
+		return models.Blog{}, &Error{
+			Kind:   KindValidation,
+			Op:     "BlogsService.UpdateBlog",
+			Entity: "blog",
+			Err:    fmt.Errorf("%w: user_id cannot be changed", ErrValidation),
+		}
+	}
+
+	// Update the existing blog with the patch data
+	if patch.Title != "" {
+		existingBlog.Title = patch.Title
+	}
+	if patch.Score != 0 {
+		existingBlog.Score = patch.Score
+	}
+
+	return s.blogs.Replace(ctx, existingBlog, patch.Version)
+}
+
+// PatchBlog attempts to apply the provided patch to the blog with the provided
+// id, written by the provided author, in a single UpdateItem request, so
+// attributes can be removed as well as set. The author is checked by the
+// update itself: ErrNotFound is returned if the blog does not exist and
+// ErrForbidden if it was written by someone else. If version is non-zero the
+// blog must still be at that version, or ErrVersionMismatch is returned. The
+// blog's id, author and comment count cannot be patched. The updated models.Blog or an error
+// is returned.
+func (s *BlogsService) PatchBlog(ctx context.Context, id, author uuid.UUID, version int64, patch Patch) (models.Blog, error) {
+	s.logger.InfoContext(ctx, "Patching blog", "id", id, "author", author)
+
+	if err := checkPatchable("BlogsService.PatchBlog", "blog", patch, "blog_id", "user_id", "comment_count"); err != nil {
+		return models.Blog{}, err
+	}
+
+	return s.blogs.UpdateOwned(ctx, keys.BlogKey(id), author, version, patch)
+}
+
+// DeleteBlog attempts to delete the blog with the provided id, written by the
+// provided author, along with every comment left on it. ErrNotFound is
+// returned if the blog does not exist and ErrForbidden if it was written by
+// someone else. If version is non-zero the blog must still be at that version,
+// or ErrVersionMismatch is returned. An error is returned if the delete fails.
+//
+// The blog and its comments are deleted in a transaction. A blog with more
+// comments than a transaction holds is deleted in several, each of which only
+// applies while the blog still exists, belongs to the author and, if version
+// is non-zero, is still at that version. Without a version, a blog changed between two of them is
+// deleted all the same. The blog itself is deleted last so a failed delete can
+// be retried.
+func (s *BlogsService) DeleteBlog(ctx context.Context, id, author uuid.UUID, version int64) error {
+	s.logger.InfoContext(ctx, "Deleting blog", "id", id, "author", author)
+
+	blogKey := keys.BlogKey(id)
+
+	// Find the comments, which share the blog's partition
+	commentKeys, err := queryKeys(ctx, s.blogs.client, &dynamodb.QueryInput{
+		TableName:              aws.String(s.blogs.table.Name),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: blogKey.PK},
+			":sk": &types.AttributeValueMemberS{Value: keys.UserPrefix},
+		},
+	})
+	if err != nil {
+		return newError(
+			"BlogsService.DeleteBlog",
+			"blog",
+			fmt.Errorf("failed to collect comments: %w", err),
+		)
+	}
+
+	cond := ownedBy(deleteCondition(version), author)
+	tx := newTransaction(s.blogs.table).
+		allowSplit().
+		guard("blog", blogKey, cond)
+	for _, key := range commentKeys {
+		tx.delete("comment", itemKey(key), condition{})
+	}
+	tx.delete("blog", blogKey, cond)
+
+	return tx.execute(ctx, s.blogs.client, "BlogsService.DeleteBlog")
+}
+
+// ListBlogs attempts to list a page of blogs in the database using a GSI. A
+// slice of models.Blog and the cursor of the next page, which is empty on the
+// last page, or an error is returned. ErrInvalidCursor is returned if the
+// page's cursor is not valid.
+func (s *BlogsService) ListBlogs(ctx context.Context, page Page) ([]models.Blog, string, error) {
+	s.logger.InfoContext(ctx, "Listing blogs")
+
+	return s.blogs.QueryIndex(ctx, keys.Blogs, "", page)
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/agallagher-captech/blog/internal/services/mock"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	testifymock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlogsService_ReadBlog(t *testing.T) {
+	testcases := map[string]struct {
+		mockOutput     []any
+		input          uuid.UUID
+		expectedOutput models.Blog
+		expectedError  error
+	}{
+		"happy path": {
+			mockOutput: []any{
+				&dynamodb.GetItemOutput{
+					Item: map[string]types.AttributeValue{
+						"PK":           &types.AttributeValueMemberS{Value: "BLOG#17e16813-c203-0355-1e4c-17c630f114f3"},
+						"SK":           &types.AttributeValueMemberS{Value: "METADATA"},
+						"GSI1PK":       &types.AttributeValueMemberS{Value: "BLOG"},
+						"GSI1SK":       &types.AttributeValueMemberS{Value: "USER#d2eddb69-f92f-694d-450d-e7cdb6decce3"},
+						"blog_id":      &types.AttributeValueMemberS{Value: "17e16813-c203-0355-1e4c-17c630f114f3"},
+						"user_id":      &types.AttributeValueMemberS{Value: "d2eddb69-f92f-694d-450d-e7cdb6decce3"},
+						"title":        &types.AttributeValueMemberS{Value: "Home Decor Ideas"},
+						"score":        &types.AttributeValueMemberN{Value: "9.5"},
+						"created_date": &types.AttributeValueMemberS{Value: "2024-04-30T09:30:00"},
+					},
+				},
+				nil,
+			},
+			input: uuid.MustParse("17e16813-c203-0355-1e4c-17c630f114f3"),
+			expectedOutput: models.Blog{
+				DynamoDBBase: models.DynamoDBBase{
+					PK:     "BLOG#17e16813-c203-0355-1e4c-17c630f114f3",
+					SK:     "METADATA",
+					GSI1PK: "BLOG",
+					GSI1SK: "USER#d2eddb69-f92f-694d-450d-e7cdb6decce3",
+				},
+				ID:          models.UUID{UUID: uuid.MustParse("17e16813-c203-0355-1e4c-17c630f114f3")},
+				UserID:      models.UUID{UUID: uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")},
+				Title:       "Home Decor Ideas",
+				Score:       9.5,
+				CreatedDate: "2024-04-30T09:30:00",
+			},
+			expectedError: nil,
+		},
+		"blog not found": {
+			mockOutput: []any{
+				&dynamodb.GetItemOutput{Item: nil},
+				nil,
+			},
+			input:          uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+			expectedOutput: models.Blog{},
+			expectedError:  ErrNotFound,
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			mockClient := new(mock.DynamoClient)
+			logger := slog.Default()
+
+			mockClient.
+				On("GetItem", context.TODO(), &dynamodb.GetItemInput{
+					TableName: aws.String("BlogContent"),
+					Key: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: "BLOG#" + tc.input.String()},
+						"SK": &types.AttributeValueMemberS{Value: "METADATA"},
+					},
+				}).
+				Return(tc.mockOutput...).
+				Once()
+
+			blogsService := NewBlogsService(logger, mockClient, DefaultTable(), NewCursors("test-cursor-key"))
+
+			output, err := blogsService.ReadBlog(context.TODO(), tc.input)
+
+			assert.ErrorIs(t, err, tc.expectedError, "error did not match")
+			assert.Equal(t, tc.expectedOutput, output, "returned data does not match")
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBlogsService_UpdateBlog(t *testing.T) {
+	id := uuid.MustParse("17e16813-c203-0355-1e4c-17c630f114f3")
+	storedItem := map[string]types.AttributeValue{
+		"PK":      &types.AttributeValueMemberS{Value: "BLOG#17e16813-c203-0355-1e4c-17c630f114f3"},
+		"SK":      &types.AttributeValueMemberS{Value: "METADATA"},
+		"blog_id": &types.AttributeValueMemberS{Value: "17e16813-c203-0355-1e4c-17c630f114f3"},
+		"user_id": &types.AttributeValueMemberS{Value: "d2eddb69-f92f-694d-450d-e7cdb6decce3"},
+		"title":   &types.AttributeValueMemberS{Value: "Home Decor Ideas"},
+		"version": &types.AttributeValueMemberN{Value: "2"},
+	}
+
+	testcases := map[string]struct {
+		expectedVersion int64
+		userID          uuid.UUID
+		putError        error
+		expectPut       bool
+		expectedError   error
+	}{
+		"happy path": {
+			expectPut: true,
+		},
+		"matching version": {
+			expectedVersion: 2,
+			expectPut:       true,
+		},
+		"stale version": {
+			expectedVersion: 1,
+			expectedError:   ErrVersionMismatch,
+		},
+		"same author": {
+			userID:    uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3"),
+			expectPut: true,
+		},
+		"another author": {
+			userID:        uuid.MustParse("1d87067c-f1fd-5516-dbac-104733ba0542"),
+			expectedError: ErrValidation,
+		},
+		"concurrent write": {
+			putError:      &types.ConditionalCheckFailedException{},
+			expectPut:     true,
+			expectedError: ErrConflict,
+		},
+		"concurrent write with expected version": {
+			expectedVersion: 2,
+			putError:        &types.ConditionalCheckFailedException{},
+			expectPut:       true,
+			expectedError:   ErrVersionMismatch,
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			mockClient := new(mock.DynamoClient)
+
+			mockClient.
+				On("GetItem", context.TODO(), testifymock.Anything).
+				Return(&dynamodb.GetItemOutput{Item: storedItem}, nil).
+				Once()
+			if tc.expectPut {
+				mockClient.
+					On("PutItem", context.TODO(), testifymock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+						// The write must be conditional on the version that was read
+						expected, ok := input.ExpressionAttributeValues[":version"].(*types.AttributeValueMemberN)
+						written, _ := input.Item["version"].(*types.AttributeValueMemberN)
+						return ok && expected.Value == "2" && written != nil && written.Value == "3"
+					})).
+					Return(&dynamodb.PutItemOutput{}, tc.putError).
+					Once()
+			}
+
+			blogsService := NewBlogsService(slog.Default(), mockClient, DefaultTable(), NewCursors("test-cursor-key"))
+
+			output, err := blogsService.UpdateBlog(context.TODO(), id, models.Blog{
+				DynamoDBBase: models.DynamoDBBase{Version: tc.expectedVersion},
+				UserID:       models.UUID{UUID: tc.userID},
+				Title:        "Kitchen Decor Ideas",
+			})
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError, "error did not match")
+			} else {
+				assert.NoError(t, err, "unexpected error")
+				assert.Equal(t, int64(3), output.Version, "version was not incremented")
+				assert.Equal(t, "Kitchen Decor Ideas", output.Title, "title was not updated")
+				assert.Equal(t, "d2eddb69-f92f-694d-450d-e7cdb6decce3", output.UserID.String(), "author was changed")
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBlogsService_DeleteBlog(t *testing.T) {
+	author := uuid.New()
+
+	testcases := map[string]struct {
+		version       int64
+		mockError     error
+		expectedError error
+	}{
+		"happy path": {},
+		"blog not found": {
+			mockError: &types.TransactionCanceledException{
+				CancellationReasons: []types.CancellationReason{
+					{Code: aws.String("ConditionalCheckFailed")},
+				},
+			},
+			expectedError: ErrNotFound,
+		},
+		"stale version": {
+			version: 1,
+			mockError: &types.TransactionCanceledException{
+				CancellationReasons: []types.CancellationReason{
+					{
+						Code: aws.String("ConditionalCheckFailed"),
+						Item: map[string]types.AttributeValue{
+							"user_id": &types.AttributeValueMemberS{Value: author.String()},
+							"version": &types.AttributeValueMemberN{Value: "2"},
+						},
+					},
+				},
+			},
+			expectedError: ErrVersionMismatch,
+		},
+		"another author": {
+			mockError: &types.TransactionCanceledException{
+				CancellationReasons: []types.CancellationReason{
+					{
+						Code: aws.String("ConditionalCheckFailed"),
+						Item: map[string]types.AttributeValue{
+							"user_id": &types.AttributeValueMemberS{Value: uuid.NewString()},
+							"version": &types.AttributeValueMemberN{Value: "2"},
+						},
+					},
+				},
+			},
+			expectedError: ErrForbidden,
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			mockClient := new(mock.DynamoClient)
+
+			mockClient.
+				On("Query", context.TODO(), testifymock.Anything).
+				Return(&dynamodb.QueryOutput{}, nil).
+				Once()
+			mockClient.
+				On("TransactWriteItems", context.TODO(), testifymock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+					return len(input.TransactItems) == 1 && input.TransactItems[0].Delete != nil
+				})).
+				Return(&dynamodb.TransactWriteItemsOutput{}, tc.mockError).
+				Once()
+
+			blogsService := NewBlogsService(slog.Default(), mockClient, DefaultTable(), NewCursors("test-cursor-key"))
+
+			err := blogsService.DeleteBlog(context.TODO(), uuid.New(), author, tc.version)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError, "error did not match")
+			} else {
+				assert.NoError(t, err, "unexpected error")
+			}
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBlogsService_PatchBlog(t *testing.T) {
+	id := uuid.MustParse("17e16813-c203-0355-1e4c-17c630f114f3")
+	author := uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")
+
+	testcases := map[string]struct {
+		id            uuid.UUID
+		author        *uuid.UUID
+		version       int64
+		patch         Patch
+		expectedError error
+	}{
+		"set and remove": {
+			id:    id,
+			patch: Patch{Set: map[string]any{"title": "Kitchen Decor Ideas"}, Remove: []string{"score"}},
+		},
+		"matching version": {
+			id:      id,
+			version: 1,
+			patch:   Patch{Set: map[string]any{"title": "Kitchen Decor Ideas"}},
+		},
+		"stale version": {
+			id:            id,
+			version:       2,
+			patch:         Patch{Set: map[string]any{"title": "Kitchen Decor Ideas"}},
+			expectedError: ErrVersionMismatch,
+		},
+		"blog not found": {
+			id:            uuid.New(),
+			patch:         Patch{Set: map[string]any{"title": "Kitchen Decor Ideas"}},
+			expectedError: ErrNotFound,
+		},
+		"another author": {
+			id:            id,
+			author:        &uuid.Nil,
+			patch:         Patch{Set: map[string]any{"title": "Kitchen Decor Ideas"}},
+			expectedError: ErrForbidden,
+		},
+		"another author at a stale version": {
+			id:            id,
+			author:        &uuid.Nil,
+			version:       2,
+			patch:         Patch{Set: map[string]any{"title": "Kitchen Decor Ideas"}},
+			expectedError: ErrForbidden,
+		},
+		"immutable author": {
+			id:            id,
+			patch:         Patch{Set: map[string]any{"user_id": uuid.NewString()}},
+			expectedError: ErrValidation,
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.TODO()
+			blogsService := NewBlogsService(slog.Default(), newSeededClient(t), DefaultTable(), NewCursors("test-cursor-key"))
+
+			// Seeded blogs have no version yet, so bring this one to version 1
+			_, err := blogsService.PatchBlog(ctx, id, author, 0, Patch{Set: map[string]any{"title": "Home Decor Ideas"}})
+			require.NoError(t, err)
+
+			caller := author
+			if tc.author != nil {
+				caller = *tc.author
+			}
+			output, err := blogsService.PatchBlog(ctx, tc.id, caller, tc.version, tc.patch)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError, "error did not match")
+				stored, err := blogsService.ReadBlog(ctx, id)
+				require.NoError(t, err)
+				assert.Equal(t, "Home Decor Ideas", stored.Title, "blog was patched")
+				return
+			}
+			require.NoError(t, err, "unexpected error")
+			assert.Equal(t, "Kitchen Decor Ideas", output.Title, "title was not patched")
+			assert.Equal(t, int64(2), output.Version, "version was not incremented")
+
+			stored, err := blogsService.ReadBlog(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, output, stored, "patched blog was not stored")
+		})
+	}
+}
+
+func TestBlogsService_DeleteBlog_Comments(t *testing.T) {
+	ctx := context.TODO()
+	client := newSeededClient(t)
+	blogsService := NewBlogsService(slog.Default(), client, DefaultTable(), NewCursors("test-cursor-key"))
+	commentsService := NewCommentsService(slog.Default(), client, DefaultTable(), NewCursors("test-cursor-key"))
+	blogID := uuid.MustParse("17e16813-c203-0355-1e4c-17c630f114f3")
+	author := uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")
+
+	comments, _, err := commentsService.ListComments(ctx, CommentsFilter{BlogID: blogID}, Page{Limit: 100})
+	require.NoError(t, err)
+	require.NotEmpty(t, comments, "seeded blog has no comments")
+
+	// Only the author may delete the blog, and nothing is deleted otherwise
+	err = blogsService.DeleteBlog(ctx, blogID, uuid.MustParse("1d87067c-f1fd-5516-dbac-104733ba0542"), 0)
+	assert.ErrorIs(t, err, ErrForbidden, "blog was deleted by another user")
+	stored, _, err := commentsService.ListComments(ctx, CommentsFilter{BlogID: blogID}, Page{Limit: 100})
+	require.NoError(t, err)
+	assert.Len(t, stored, len(comments), "comments were deleted by another user")
+
+	require.NoError(t, blogsService.DeleteBlog(ctx, blogID, author, 0))
+
+	_, err = blogsService.ReadBlog(ctx, blogID)
+	assert.ErrorIs(t, err, ErrNotFound, "blog was not deleted")
+	comments, _, err = commentsService.ListComments(ctx, CommentsFilter{BlogID: blogID}, Page{Limit: 100})
+	require.NoError(t, err)
+	assert.Empty(t, comments, "comments were not deleted")
+
+	assert.ErrorIs(t, blogsService.DeleteBlog(ctx, blogID, author, 0), ErrNotFound, "missing blog was deleted")
+}
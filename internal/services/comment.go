@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/agallagher-captech/blog/internal/keys"
+	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/google/uuid"
+)
+
+// CommentsService is a service capable of performing CRUD operations for
+// models.Comment models.
+type CommentsService struct {
+	logger   *slog.Logger
+	comments *Repository[models.Comment, *models.Comment]
+}
+
+// NewCommentsService creates a new CommentsService and returns a pointer to it.
+func NewCommentsService(logger *slog.Logger, client dynamoClient, table Table, cursors *Cursors) *CommentsService {
+	return &CommentsService{
+		logger:   logger,
+		comments: NewRepository[models.Comment](logger, client, table, cursors, "comment"),
+	}
+}
+
+// CommentsFilter narrows the comments returned by ListComments. Zero value
+// ids are ignored, so an empty filter lists every comment.
+type CommentsFilter struct {
+	BlogID uuid.UUID
+	UserID uuid.UUID
+}
+
+// CreateComment attempts to create the provided comment, returning a fully
+// hydrated models.Comment or an error. The comment is written in a transaction
+// that increments the comment count of its blog, which must exist, so
+// ErrNotFound is returned for a comment on a missing blog. ErrAlreadyExists is
+// returned if the user has already commented on the blog.
+func (s *CommentsService) CreateComment(ctx context.Context, comment models.Comment) (models.Comment, error) {
+	s.logger.InfoContext(ctx, "Creating comment", "blog_id", comment.BlogID, "user_id", comment.UserID)
+
+	item, _, err := s.comments.marshal("CommentsService.CreateComment", &comment)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	item["version"] = versionValue(1)
+
+	err = newTransaction(s.comments.table).
+		create("comment", item).
+		update("blog", countComments(s.comments.table, comment.BlogID.UUID, 1)).
+		execute(ctx, s.comments.client, "CommentsService.CreateComment")
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	return s.comments.unmarshal("CommentsService.CreateComment", item)
+}
+
+// ReadComment attempts to read the comment left by the user with the provided
+// userID on the blog with the provided blogID. A fully hydrated models.Comment
+// or error is returned.
+func (s *CommentsService) ReadComment(ctx context.Context, blogID, userID uuid.UUID) (models.Comment, error) {
+	s.logger.InfoContext(ctx, "Reading comment", "blog_id", blogID, "user_id", userID)
+
+	return s.comments.Get(ctx, keys.CommentKey(blogID, userID))
+}
+
+// UpdateComment attempts to perform an update of the comment identified by the
+// provided blogID and userID, updating it to reflect the properties on the
+// provided patch object. If the patch has a non-zero Version the comment must
+// still be at that version, or ErrVersionMismatch is returned. A
+// models.Comment or an error is returned.
+func (s *CommentsService) UpdateComment(ctx context.Context, blogID, userID uuid.UUID, patch models.Comment) (models.Comment, error) {
+	s.logger.InfoContext(ctx, "Updating comment", "blog_id", blogID, "user_id", userID)
+
+	existingComment, err := s.comments.Get(ctx, keys.CommentKey(blogID, userID))
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	if !checkVersion(patch.Version, existingComment.Version) {
+		return models.Comment{}, versionMismatch("CommentsService.UpdateComment", "comment")
+	}
+
+	// Update the existing comment with the patch data. The blog and user ids
+	// form the key of the comment and cannot be changed.
+	if patch.Message != "" {
+		existingComment.Message = patch.Message
+	}
+
+	return s.comments.Replace(ctx, existingComment, patch.Version)
+}
+
+// PatchComment attempts to apply the provided patch to the comment identified
+// by the provided blogID and userID in a single UpdateItem request, so
+// attributes can be removed as well as set. If version is non-zero the comment
+// must still be at that version, or ErrVersionMismatch is returned. The blog
+// and user ids cannot be patched. The updated models.Comment or an error is
+// returned.
+func (s *CommentsService) PatchComment(ctx context.Context, blogID, userID uuid.UUID, version int64, patch Patch) (models.Comment, error) {
+	s.logger.InfoContext(ctx, "Patching comment", "blog_id", blogID, "user_id", userID)
+
+	if err := checkPatchable("CommentsService.PatchComment", "comment", patch, "blog_id", "user_id"); err != nil {
+		return models.Comment{}, err
+	}
+
+	return s.comments.Update(ctx, keys.CommentKey(blogID, userID), version, patch)
+}
+
+// DeleteComment attempts to delete the comment identified by the provided
+// blogID and userID. ErrNotFound is returned if the comment does not exist. If
+// version is non-zero the comment must still be at that version, or
+// ErrVersionMismatch is returned. The comment count of the blog is decremented
+// in the same transaction. An error is returned if the delete fails.
+func (s *CommentsService) DeleteComment(ctx context.Context, blogID, userID uuid.UUID, version int64) error {
+	s.logger.InfoContext(ctx, "Deleting comment", "blog_id", blogID, "user_id", userID)
+
+	return newTransaction(s.comments.table).
+		delete("comment", keys.CommentKey(blogID, userID), deleteCondition(version)).
+		update("blog", countComments(s.comments.table, blogID, -1)).
+		execute(ctx, s.comments.client, "CommentsService.DeleteComment")
+}
+
+// countComments returns the update adding delta to the comment count of the
+// blog with the provided id, which only applies while the blog exists. The
+// blog's version is incremented as well, so that a blog read before the count
+// changed cannot be written back over it.
+func countComments(table Table, blogID uuid.UUID, delta int) *dynamodb.UpdateItemInput {
+	return &dynamodb.UpdateItemInput{
+		TableName:           aws.String(table.Name),
+		Key:                 keys.BlogKey(blogID).AttributeValues(),
+		UpdateExpression:    aws.String("ADD #count :delta, #version :one"),
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+		ExpressionAttributeNames: map[string]string{
+			"#count":   "comment_count",
+			"#version": "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":delta": &types.AttributeValueMemberN{Value: strconv.Itoa(delta)},
+			":one":   &types.AttributeValueMemberN{Value: "1"},
+		},
+	}
+}
+
+// ListComments attempts to list a page of the comments matching the provided
+// filter. When a blog id is given the blog's partition is queried directly,
+// when only a user id is given GSI1 is queried, and otherwise every comment is
+// listed through GSI1. A slice of models.Comment and the cursor of the next
+// page, which is empty on the last page, or an error is returned.
+// ErrInvalidCursor is returned if the page's cursor is not valid.
+func (s *CommentsService) ListComments(ctx context.Context, filter CommentsFilter, page Page) ([]models.Comment, string, error) {
+	s.logger.InfoContext(ctx, "Listing comments", "blog_id", filter.BlogID, "user_id", filter.UserID)
+
+	// Cursors are only valid for the same filter they were issued for
+	scope := fmt.Sprintf("comments:%s:%s", filter.BlogID, filter.UserID)
+
+	return s.comments.Query(ctx, scope, commentsQuery(s.comments.table, filter), page)
+}
+
+// commentsQuery builds the Query input used to list the comments of the table
+// matching the provided filter.
+func commentsQuery(table Table, filter CommentsFilter) *dynamodb.QueryInput {
+	switch {
+	case filter.BlogID != uuid.Nil && filter.UserID != uuid.Nil:
+		return &dynamodb.QueryInput{
+			TableName:              aws.String(table.Name),
+			KeyConditionExpression: aws.String("PK = :pk AND SK = :sk"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: keys.Blog(filter.BlogID)},
+				":sk": &types.AttributeValueMemberS{Value: keys.User(filter.UserID)},
+			},
+		}
+	case filter.BlogID != uuid.Nil:
+		// The blog's METADATA item shares the partition, so only select the
+		// items sorted under a user.
+		return &dynamodb.QueryInput{
+			TableName:              aws.String(table.Name),
+			KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: keys.Blog(filter.BlogID)},
+				":sk": &types.AttributeValueMemberS{Value: keys.UserPrefix},
+			},
+		}
+	case filter.UserID != uuid.Nil:
+		return &dynamodb.QueryInput{
+			TableName:              aws.String(table.Name),
+			IndexName:              aws.String(table.GSI1),
+			KeyConditionExpression: aws.String("GSI1PK = :pk AND GSI1SK = :sk"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: keys.Comments},
+				":sk": &types.AttributeValueMemberS{Value: keys.User(filter.UserID)},
+			},
+		}
+	default:
+		return &dynamodb.QueryInput{
+			TableName:              aws.String(table.Name),
+			IndexName:              aws.String(table.GSI1),
+			KeyConditionExpression: aws.String("GSI1PK = :pk"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: keys.Comments},
+			},
+		}
+	}
+}
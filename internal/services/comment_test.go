@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommentsQuery(t *testing.T) {
+	blogID := uuid.MustParse("17e16813-c203-0355-1e4c-17c630f114f3")
+	userID := uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")
+
+	testcases := map[string]struct {
+		filter        CommentsFilter
+		wantIndex     *string
+		wantCondition string
+		wantValues    map[string]types.AttributeValue
+	}{
+		"blog and user": {
+			filter:        CommentsFilter{BlogID: blogID, UserID: userID},
+			wantCondition: "PK = :pk AND SK = :sk",
+			wantValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: "BLOG#" + blogID.String()},
+				":sk": &types.AttributeValueMemberS{Value: "USER#" + userID.String()},
+			},
+		},
+		"blog only": {
+			filter:        CommentsFilter{BlogID: blogID},
+			wantCondition: "PK = :pk AND begins_with(SK, :sk)",
+			wantValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: "BLOG#" + blogID.String()},
+				":sk": &types.AttributeValueMemberS{Value: "USER#"},
+			},
+		},
+		"user only": {
+			filter:        CommentsFilter{UserID: userID},
+			wantIndex:     aws.String("GSI1"),
+			wantCondition: "GSI1PK = :pk AND GSI1SK = :sk",
+			wantValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: "COMMENT"},
+				":sk": &types.AttributeValueMemberS{Value: "USER#" + userID.String()},
+			},
+		},
+		"no filter": {
+			filter:        CommentsFilter{},
+			wantIndex:     aws.String("GSI1"),
+			wantCondition: "GSI1PK = :pk",
+			wantValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: "COMMENT"},
+			},
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			input := commentsQuery(DefaultTable(), tc.filter)
+
+			assert.Equal(t, tc.wantIndex, input.IndexName, "index mismatch")
+			assert.Equal(t, tc.wantCondition, *input.KeyConditionExpression, "key condition mismatch")
+			assert.Equal(t, tc.wantValues, input.ExpressionAttributeValues, "values mismatch")
+		})
+	}
+}
+
+func TestCommentsService_CreateComment(t *testing.T) {
+	ctx := context.TODO()
+	client := newSeededClient(t)
+	commentsService := NewCommentsService(slog.Default(), client, DefaultTable(), NewCursors("test-cursor-key"))
+	blogsService := NewBlogsService(slog.Default(), client, DefaultTable(), NewCursors("test-cursor-key"))
+	blogID := uuid.MustParse("17e16813-c203-0355-1e4c-17c630f114f3")
+
+	created, err := commentsService.CreateComment(ctx, models.Comment{
+		BlogID:  models.UUID{UUID: blogID},
+		UserID:  models.UUID{UUID: uuid.New()},
+		Message: "Great post!",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), created.Version, "created at the wrong version")
+
+	blog, err := blogsService.ReadBlog(ctx, blogID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), blog.CommentCount, "comment was not counted")
+
+	_, err = commentsService.CreateComment(ctx, created)
+	assert.ErrorIs(t, err, ErrAlreadyExists, "duplicate comment was created")
+
+	blog, err = blogsService.ReadBlog(ctx, blogID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), blog.CommentCount, "duplicate comment was counted")
+
+	_, err = commentsService.CreateComment(ctx, models.Comment{
+		BlogID:  models.UUID{UUID: uuid.New()},
+		UserID:  created.UserID,
+		Message: "Hello?",
+	})
+	var serviceErr *Error
+	require.ErrorAs(t, err, &serviceErr)
+	assert.ErrorIs(t, err, ErrNotFound, "comment on a missing blog was created")
+	assert.Equal(t, "blog", serviceErr.Entity, "missing entity did not match")
+}
+
+func TestCommentsService_DeleteComment(t *testing.T) {
+	ctx := context.TODO()
+	client := newSeededClient(t)
+	commentsService := NewCommentsService(slog.Default(), client, DefaultTable(), NewCursors("test-cursor-key"))
+	blogsService := NewBlogsService(slog.Default(), client, DefaultTable(), NewCursors("test-cursor-key"))
+	blogID := uuid.MustParse("17e16813-c203-0355-1e4c-17c630f114f3")
+
+	created, err := commentsService.CreateComment(ctx, models.Comment{
+		BlogID:  models.UUID{UUID: blogID},
+		UserID:  models.UUID{UUID: uuid.New()},
+		Message: "Great post!",
+	})
+	require.NoError(t, err)
+	before, err := blogsService.ReadBlog(ctx, blogID)
+	require.NoError(t, err)
+
+	err = commentsService.DeleteComment(ctx, blogID, created.UserID.UUID, created.Version+1)
+	assert.ErrorIs(t, err, ErrVersionMismatch, "comment at another version was deleted")
+
+	err = commentsService.DeleteComment(ctx, blogID, created.UserID.UUID, created.Version)
+	require.NoError(t, err)
+
+	after, err := blogsService.ReadBlog(ctx, blogID)
+	require.NoError(t, err)
+	assert.Equal(t, before.CommentCount-1, after.CommentCount, "deleted comment was still counted")
+	assert.Equal(t, before.Version+1, after.Version, "blog version was not incremented")
+
+	err = commentsService.DeleteComment(ctx, blogID, created.UserID.UUID, 0)
+	var serviceErr *Error
+	require.ErrorAs(t, err, &serviceErr)
+	assert.ErrorIs(t, err, ErrNotFound, "missing comment was deleted")
+	assert.Equal(t, "comment", serviceErr.Entity, "missing entity did not match")
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrInvalidCursor = fmt.Errorf("invalid cursor")
+
+const (
+	// DefaultPageLimit is the number of items returned by a list operation
+	// when no limit is requested.
+	DefaultPageLimit = 25
+
+	// MaxPageLimit is the largest number of items a list operation returns in
+	// a single page.
+	MaxPageLimit = 100
+)
+
+// Page selects a single page of a list operation. Cursor is the NextCursor of
+// the previous page, or empty for the first page.
+type Page struct {
+	Limit  int
+	Cursor string
+}
+
+// limit returns the page size to request from DynamoDB.
+func (p Page) limit() int32 {
+	switch {
+	case p.Limit <= 0:
+		return DefaultPageLimit
+	case p.Limit > MaxPageLimit:
+		return MaxPageLimit
+	default:
+		return int32(p.Limit)
+	}
+}
+
+// Cursors encodes DynamoDB LastEvaluatedKey values as opaque, signed cursor
+// strings and decodes them back into ExclusiveStartKey values. The signature
+// makes cursors tamper-evident, and each cursor is bound to the scope it was
+// issued for so it cannot be replayed against a different listing.
+type Cursors struct {
+	key []byte
+}
+
+// NewCursors creates a new Cursors signing with the provided key and returns a
+// pointer to it.
+func NewCursors(signingKey string) *Cursors {
+	return &Cursors{key: []byte(signingKey)}
+}
+
+// Encode returns the cursor for the provided LastEvaluatedKey, or an empty
+// string if there are no more pages.
+func (c *Cursors) Encode(scope string, lastEvaluatedKey map[string]types.AttributeValue) (string, error) {
+	if len(lastEvaluatedKey) == 0 {
+		return "", nil
+	}
+
+	// The table's key attributes are all strings, so the key can be carried
+	// as a plain map.
+	key := make(map[string]string, len(lastEvaluatedKey))
+	for name, value := range lastEvaluatedKey {
+		s, ok := value.(*types.AttributeValueMemberS)
+		if !ok {
+			return "", fmt.Errorf("encode cursor: unsupported key attribute %q of type %T", name, value)
+		}
+		key[name] = s.Value
+	}
+
+	payload, err := json.Marshal(key)
+	if err != nil {
+		return "", fmt.Errorf("encode cursor: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + c.sign(scope, encoded), nil
+}
+
+// Decode returns the ExclusiveStartKey for the provided cursor, or nil if the
+// cursor is empty. ErrInvalidCursor is returned if the cursor was not issued
+// by Encode for the same scope.
+func (c *Cursors) Decode(scope, cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	encoded, signature, ok := strings.Cut(cursor, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(c.sign(scope, encoded))) {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var key map[string]string
+	if err = json.Unmarshal(payload, &key); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	startKey := make(map[string]types.AttributeValue, len(key))
+	for name, value := range key {
+		startKey[name] = &types.AttributeValueMemberS{Value: value}
+	}
+
+	return startKey, nil
+}
+
+// sign returns the encoded HMAC of the scope and encoded key.
+func (c *Cursors) sign(scope, encoded string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(scope))
+	mac.Write([]byte{0})
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
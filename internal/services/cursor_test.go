@@ -0,0 +1,78 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursors(t *testing.T) {
+	cursors := NewCursors("test-cursor-key")
+	lastEvaluatedKey := map[string]types.AttributeValue{
+		"PK":     &types.AttributeValueMemberS{Value: "USER#d2eddb69-f92f-694d-450d-e7cdb6decce3"},
+		"SK":     &types.AttributeValueMemberS{Value: "PROFILE"},
+		"GSI1PK": &types.AttributeValueMemberS{Value: "USER"},
+		"GSI1SK": &types.AttributeValueMemberS{Value: "USER#d2eddb69-f92f-694d-450d-e7cdb6decce3"},
+	}
+
+	cursor, err := cursors.Encode("users", lastEvaluatedKey)
+	assert.NoError(t, err, "unexpected error encoding cursor")
+
+	last, err := cursors.Encode("users", nil)
+	assert.NoError(t, err, "unexpected error encoding last page cursor")
+	assert.Empty(t, last, "last page should have no cursor")
+
+	testcases := map[string]struct {
+		scope          string
+		cursor         string
+		expectedOutput map[string]types.AttributeValue
+		expectedError  error
+	}{
+		"round trip": {
+			scope:          "users",
+			cursor:         cursor,
+			expectedOutput: lastEvaluatedKey,
+		},
+		"first page": {
+			scope:          "users",
+			cursor:         "",
+			expectedOutput: nil,
+		},
+		"different scope": {
+			scope:         "blogs",
+			cursor:        cursor,
+			expectedError: ErrInvalidCursor,
+		},
+		"tampered key": {
+			scope:         "users",
+			cursor:        "e30" + cursor[3:],
+			expectedError: ErrInvalidCursor,
+		},
+		"signed with another key": {
+			scope:         "users",
+			cursor:        mustEncode(t, NewCursors("other-cursor-key"), "users", lastEvaluatedKey),
+			expectedError: ErrInvalidCursor,
+		},
+		"garbage": {
+			scope:         "users",
+			cursor:        "not-a-cursor",
+			expectedError: ErrInvalidCursor,
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			output, err := cursors.Decode(tc.scope, tc.cursor)
+
+			assert.ErrorIs(t, err, tc.expectedError, "error did not match")
+			assert.Equal(t, tc.expectedOutput, output, "decoded key does not match")
+		})
+	}
+}
+
+func mustEncode(t *testing.T, cursors *Cursors, scope string, key map[string]types.AttributeValue) string {
+	t.Helper()
+	cursor, err := cursors.Encode(scope, key)
+	assert.NoError(t, err, "unexpected error encoding cursor")
+	return cursor
+}
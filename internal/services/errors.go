@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/smithy-go"
+)
+
+var (
+	ErrNotFound      = fmt.Errorf("item not found")
+	ErrAlreadyExists = fmt.Errorf("item already exists")
+	ErrConflict      = fmt.Errorf("conflicting write")
+	ErrThrottled     = fmt.Errorf("request throttled")
+	ErrValidation    = fmt.Errorf("invalid request")
+	ErrUnavailable   = fmt.Errorf("storage unavailable")
+	ErrForbidden     = fmt.Errorf("caller does not own item")
+)
+
+// ErrorKind classifies why a service operation failed, independent of the
+// storage error that caused it.
+type ErrorKind int
+
+const (
+	// KindInternal is a failure the caller can do nothing about, such as a
+	// bug or an item that cannot be unmarshalled.
+	KindInternal ErrorKind = iota
+
+	// KindNotFound means the item the operation needed does not exist.
+	KindNotFound
+
+	// KindConflict means the write clashed with the current state of the
+	// item, either because it already exists or because it was changed
+	// concurrently.
+	KindConflict
+
+	// KindThrottled means DynamoDB rejected the request for exceeding the
+	// table's throughput. The operation can be retried after a delay.
+	KindThrottled
+
+	// KindValidation means DynamoDB rejected the request as invalid, for
+	// example because an item is too large.
+	KindValidation
+
+	// KindUnavailable means DynamoDB could not be reached or failed on its
+	// side.
+	KindUnavailable
+
+	// KindForbidden means the item exists but is owned by someone other
+	// than the caller the write was restricted to.
+	KindForbidden
+
+	// KindUnauthenticated means the token the caller authenticated with is
+	// no longer valid. Errors of this kind wrap ErrInvalidToken.
+	KindUnauthenticated
+)
+
+// String returns a short description of the kind.
+func (k ErrorKind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not found"
+	case KindConflict:
+		return "conflict"
+	case KindThrottled:
+		return "throttled"
+	case KindValidation:
+		return "validation"
+	case KindUnavailable:
+		return "unavailable"
+	case KindForbidden:
+		return "forbidden"
+	case KindUnauthenticated:
+		return "unauthenticated"
+	default:
+		return "internal"
+	}
+}
+
+// sentinel returns the error that all errors of the kind match with
+// errors.Is, or nil for KindInternal.
+func (k ErrorKind) sentinel() error {
+	switch k {
+	case KindNotFound:
+		return ErrNotFound
+	case KindConflict:
+		return ErrConflict
+	case KindThrottled:
+		return ErrThrottled
+	case KindValidation:
+		return ErrValidation
+	case KindUnavailable:
+		return ErrUnavailable
+	case KindForbidden:
+		return ErrForbidden
+	case KindUnauthenticated:
+		return ErrInvalidToken
+	default:
+		return nil
+	}
+}
+
+// Error is the error returned by services when an operation fails. It records
+// the operation and the entity it was acting on, and classifies the cause so
+// callers can react to it without inspecting DynamoDB errors. Errors of a kind
+// match that kind's sentinel, e.g. ErrNotFound, with errors.Is.
+type Error struct {
+	Kind   ErrorKind
+	Op     string
+	Entity string
+	Err    error
+}
+
+// Error returns the error message, prefixed with the operation that failed.
+func (e *Error) Error() string {
+	return fmt.Sprintf("[in services.%s] %s: %v", e.Op, e.Entity, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel of the error's kind.
+func (e *Error) Is(target error) bool {
+	sentinel := e.Kind.sentinel()
+	return sentinel != nil && target == sentinel
+}
+
+// newError returns an *Error for the failure of the provided operation on the
+// provided entity, classifying err to find its kind.
+func newError(op, entity string, err error) error {
+	return &Error{
+		Kind:   classify(err),
+		Op:     op,
+		Entity: entity,
+		Err:    err,
+	}
+}
+
+// notFound returns an *Error reporting that the entity the provided operation
+// needed does not exist.
+func notFound(op, entity string) error {
+	return &Error{Kind: KindNotFound, Op: op, Entity: entity, Err: ErrNotFound}
+}
+
+// alreadyExists returns an *Error reporting that the entity the provided
+// operation tried to create already exists.
+func alreadyExists(op, entity string) error {
+	return &Error{Kind: KindConflict, Op: op, Entity: entity, Err: ErrAlreadyExists}
+}
+
+// forbidden returns an *Error reporting that the entity the provided
+// operation acted on is owned by someone other than the caller.
+func forbidden(op, entity string) error {
+	return &Error{Kind: KindForbidden, Op: op, Entity: entity, Err: ErrForbidden}
+}
+
+// classify returns the kind of the provided error, which is usually returned
+// by the DynamoDB client. Errors that are already classified keep their kind.
+func classify(err error) ErrorKind {
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Kind
+	}
+
+	var (
+		ccf        *types.ConditionalCheckFailedException
+		tce        *types.TransactionCanceledException
+		txConflict *types.TransactionConflictException
+		throughput *types.ProvisionedThroughputExceededException
+		limit      *types.RequestLimitExceeded
+		noTable    *types.ResourceNotFoundException
+		internal   *types.InternalServerError
+	)
+	switch {
+	case errors.As(err, &ccf), errors.As(err, &txConflict):
+		return KindConflict
+	case errors.As(err, &tce):
+		return classifyCancellation(tce)
+	case errors.As(err, &throughput), errors.As(err, &limit):
+		return KindThrottled
+	case errors.As(err, &noTable), errors.As(err, &internal):
+		// A missing table is a deployment problem, not a missing item.
+		return KindUnavailable
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return KindUnavailable
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ThrottlingException":
+			return KindThrottled
+		case "ValidationException":
+			return KindValidation
+		}
+		if apiErr.ErrorFault() == smithy.FaultServer {
+			return KindUnavailable
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return KindUnavailable
+	}
+
+	return KindInternal
+}
+
+// classifyCancellation returns the kind of a cancelled transaction from the
+// reasons its items were rejected.
+func classifyCancellation(tce *types.TransactionCanceledException) ErrorKind {
+	kind := KindInternal
+	for _, reason := range tce.CancellationReasons {
+		switch reasonKind := cancellationKind(aws.StringValue(reason.Code)); reasonKind {
+		case KindConflict:
+			return KindConflict
+		case KindThrottled:
+			kind = KindThrottled
+		case KindValidation:
+			if kind == KindInternal {
+				kind = KindValidation
+			}
+		}
+	}
+	return kind
+}
+
+// cancellationKind returns the kind of the error for an item of a cancelled
+// transaction that was rejected with the provided code.
+func cancellationKind(code string) ErrorKind {
+	switch code {
+	case "ConditionalCheckFailed", "TransactionConflict":
+		return KindConflict
+	case "ThrottlingError", "ProvisionedThroughputExceeded", "RequestLimitExceeded":
+		return KindThrottled
+	case "ValidationError", "ItemCollectionSizeLimitExceeded":
+		return KindValidation
+	default:
+		return KindInternal
+	}
+}
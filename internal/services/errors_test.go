@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewError(t *testing.T) {
+	tests := map[string]struct {
+		err          error
+		expectedKind ErrorKind
+		expectedIs   error
+	}{
+		"conditional check failed": {
+			err:          &types.ConditionalCheckFailedException{},
+			expectedKind: KindConflict,
+			expectedIs:   ErrConflict,
+		},
+		"transaction condition failed": {
+			err: &types.TransactionCanceledException{
+				CancellationReasons: []types.CancellationReason{
+					{Code: aws.String("None")},
+					{Code: aws.String("ConditionalCheckFailed")},
+				},
+			},
+			expectedKind: KindConflict,
+			expectedIs:   ErrConflict,
+		},
+		"transaction throttled": {
+			err: &types.TransactionCanceledException{
+				CancellationReasons: []types.CancellationReason{
+					{Code: aws.String("ThrottlingError")},
+				},
+			},
+			expectedKind: KindThrottled,
+			expectedIs:   ErrThrottled,
+		},
+		"throughput exceeded": {
+			err:          &types.ProvisionedThroughputExceededException{},
+			expectedKind: KindThrottled,
+			expectedIs:   ErrThrottled,
+		},
+		"validation": {
+			err:          &smithy.GenericAPIError{Code: "ValidationException", Fault: smithy.FaultClient},
+			expectedKind: KindValidation,
+			expectedIs:   ErrValidation,
+		},
+		"missing table": {
+			err:          &types.ResourceNotFoundException{},
+			expectedKind: KindUnavailable,
+			expectedIs:   ErrUnavailable,
+		},
+		"deadline exceeded": {
+			err:          context.DeadlineExceeded,
+			expectedKind: KindUnavailable,
+			expectedIs:   ErrUnavailable,
+		},
+		"already classified": {
+			err:          notFound("BlogsService.ReadBlog", "blog"),
+			expectedKind: KindNotFound,
+			expectedIs:   ErrNotFound,
+		},
+		"unknown": {
+			err:          errors.New("boom"),
+			expectedKind: KindInternal,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := newError("BlogsService.UpdateBlog", "blog", fmt.Errorf("failed to put item: %w", tc.err))
+
+			var serviceErr *Error
+			assert.ErrorAs(t, err, &serviceErr)
+			assert.Equal(t, tc.expectedKind, serviceErr.Kind, "kind did not match")
+			assert.Equal(t, "BlogsService.UpdateBlog", serviceErr.Op, "op did not match")
+			assert.Equal(t, "blog", serviceErr.Entity, "entity did not match")
+			assert.ErrorIs(t, err, tc.err, "cause was not wrapped")
+			if tc.expectedIs != nil {
+				assert.ErrorIs(t, err, tc.expectedIs, "error did not match its kind")
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+// Package instrument wraps a DynamoDB client to record metrics of the
+// requests it sends: how many were sent and failed, how long they took and how
+// much capacity they consumed.
+//
+// Every request that can report its consumed capacity is sent with
+// ReturnConsumedCapacity set to TOTAL, unless the caller asked for more, on a
+// copy of the caller's input. The copy is made on every attempt, so anything
+// that must stay the same across retries, such as the ClientRequestToken of a
+// transaction, has to be set on the input before it reaches a Client, as
+// retry.Client does. A Client should wrap the AWS client directly, inside any
+// retry.Client, so that every attempt is recorded.
+package instrument
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/agallagher-captech/blog/internal/services/retry"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/smithy-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the metrics recorded by a Client, labelled by DynamoDB
+// operation.
+type Metrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	capacity *prometheus.CounterVec
+}
+
+// NewMetrics creates the DynamoDB metrics and registers them with the provided
+// registerer.
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dynamodb_requests_total",
+			Help: "DynamoDB requests sent, by operation.",
+		}, []string{"operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dynamodb_request_errors_total",
+			Help: "DynamoDB requests that failed, by operation and error code.",
+		}, []string{"operation", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "dynamodb_request_duration_seconds",
+			Help:    "Time taken by DynamoDB requests, by operation.",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation"}),
+		capacity: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dynamodb_consumed_capacity_units_total",
+			Help: "Capacity units consumed by DynamoDB requests, by operation and table.",
+		}, []string{"operation", "table"}),
+	}
+	for _, collector := range []prometheus.Collector{m.requests, m.errors, m.duration, m.capacity} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("[in instrument.NewMetrics] failed to register metric: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// Client is a DynamoDB client that records metrics of the requests of the
+// client it wraps.
+type Client struct {
+	api     retry.API
+	metrics *Metrics
+}
+
+// NewClient wraps the provided client, recording its requests in metrics.
+func NewClient(api retry.API, metrics *Metrics) *Client {
+	return &Client{api: api, metrics: metrics}
+}
+
+// DescribeTable describes a table.
+func (c *Client) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return observe(c, "DescribeTable", func() (*dynamodb.DescribeTableOutput, error) {
+		return c.api.DescribeTable(ctx, params, optFns...)
+	}, nil)
+}
+
+// CreateTable creates a table.
+func (c *Client) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	return observe(c, "CreateTable", func() (*dynamodb.CreateTableOutput, error) {
+		return c.api.CreateTable(ctx, params, optFns...)
+	}, nil)
+}
+
+// UpdateTable updates a table.
+func (c *Client) UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error) {
+	return observe(c, "UpdateTable", func() (*dynamodb.UpdateTableOutput, error) {
+		return c.api.UpdateTable(ctx, params, optFns...)
+	}, nil)
+}
+
+// DeleteTable deletes a table.
+func (c *Client) DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error) {
+	return observe(c, "DeleteTable", func() (*dynamodb.DeleteTableOutput, error) {
+		return c.api.DeleteTable(ctx, params, optFns...)
+	}, nil)
+}
+
+// GetItem gets an item.
+func (c *Client) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	input := *params
+	input.ReturnConsumedCapacity = returnCapacity(params.ReturnConsumedCapacity)
+	return observe(c, "GetItem", func() (*dynamodb.GetItemOutput, error) {
+		return c.api.GetItem(ctx, &input, optFns...)
+	}, func(out *dynamodb.GetItemOutput) []*types.ConsumedCapacity {
+		return []*types.ConsumedCapacity{out.ConsumedCapacity}
+	})
+}
+
+// PutItem puts an item.
+func (c *Client) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	input := *params
+	input.ReturnConsumedCapacity = returnCapacity(params.ReturnConsumedCapacity)
+	return observe(c, "PutItem", func() (*dynamodb.PutItemOutput, error) {
+		return c.api.PutItem(ctx, &input, optFns...)
+	}, func(out *dynamodb.PutItemOutput) []*types.ConsumedCapacity {
+		return []*types.ConsumedCapacity{out.ConsumedCapacity}
+	})
+}
+
+// Query runs a query.
+func (c *Client) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	input := *params
+	input.ReturnConsumedCapacity = returnCapacity(params.ReturnConsumedCapacity)
+	return observe(c, "Query", func() (*dynamodb.QueryOutput, error) {
+		return c.api.Query(ctx, &input, optFns...)
+	}, func(out *dynamodb.QueryOutput) []*types.ConsumedCapacity {
+		return []*types.ConsumedCapacity{out.ConsumedCapacity}
+	})
+}
+
+// DeleteItem deletes an item.
+func (c *Client) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	input := *params
+	input.ReturnConsumedCapacity = returnCapacity(params.ReturnConsumedCapacity)
+	return observe(c, "DeleteItem", func() (*dynamodb.DeleteItemOutput, error) {
+		return c.api.DeleteItem(ctx, &input, optFns...)
+	}, func(out *dynamodb.DeleteItemOutput) []*types.ConsumedCapacity {
+		return []*types.ConsumedCapacity{out.ConsumedCapacity}
+	})
+}
+
+// Scan runs a scan.
+func (c *Client) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	input := *params
+	input.ReturnConsumedCapacity = returnCapacity(params.ReturnConsumedCapacity)
+	return observe(c, "Scan", func() (*dynamodb.ScanOutput, error) {
+		return c.api.Scan(ctx, &input, optFns...)
+	}, func(out *dynamodb.ScanOutput) []*types.ConsumedCapacity {
+		return []*types.ConsumedCapacity{out.ConsumedCapacity}
+	})
+}
+
+// BatchWriteItem writes a batch of items.
+func (c *Client) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	input := *params
+	input.ReturnConsumedCapacity = returnCapacity(params.ReturnConsumedCapacity)
+	return observe(c, "BatchWriteItem", func() (*dynamodb.BatchWriteItemOutput, error) {
+		return c.api.BatchWriteItem(ctx, &input, optFns...)
+	}, func(out *dynamodb.BatchWriteItemOutput) []*types.ConsumedCapacity {
+		return pointers(out.ConsumedCapacity)
+	})
+}
+
+// TransactWriteItems writes a transaction.
+func (c *Client) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	input := *params
+	input.ReturnConsumedCapacity = returnCapacity(params.ReturnConsumedCapacity)
+	return observe(c, "TransactWriteItems", func() (*dynamodb.TransactWriteItemsOutput, error) {
+		return c.api.TransactWriteItems(ctx, &input, optFns...)
+	}, func(out *dynamodb.TransactWriteItemsOutput) []*types.ConsumedCapacity {
+		return pointers(out.ConsumedCapacity)
+	})
+}
+
+// UpdateItem updates an item.
+func (c *Client) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	input := *params
+	input.ReturnConsumedCapacity = returnCapacity(params.ReturnConsumedCapacity)
+	return observe(c, "UpdateItem", func() (*dynamodb.UpdateItemOutput, error) {
+		return c.api.UpdateItem(ctx, &input, optFns...)
+	}, func(out *dynamodb.UpdateItemOutput) []*types.ConsumedCapacity {
+		return []*types.ConsumedCapacity{out.ConsumedCapacity}
+	})
+}
+
+// observe sends a request of the provided operation and records it. If the
+// request succeeds, the capacity it consumed is read from its output with
+// consumed, which is nil for operations that do not report it.
+func observe[T any](c *Client, op string, call func() (T, error), consumed func(T) []*types.ConsumedCapacity) (T, error) {
+	start := time.Now()
+	result, err := call()
+	c.metrics.duration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	c.metrics.requests.WithLabelValues(op).Inc()
+
+	if err != nil {
+		c.metrics.errors.WithLabelValues(op, errorCode(err)).Inc()
+		return result, err
+	}
+	if consumed != nil {
+		for _, capacity := range consumed(result) {
+			if capacity == nil || capacity.CapacityUnits == nil {
+				continue
+			}
+			c.metrics.capacity.WithLabelValues(op, aws.StringValue(capacity.TableName)).Add(*capacity.CapacityUnits)
+		}
+	}
+	return result, nil
+}
+
+// returnCapacity returns the consumed capacity a request asks for: TOTAL,
+// unless the caller asked for more.
+func returnCapacity(requested types.ReturnConsumedCapacity) types.ReturnConsumedCapacity {
+	if requested == "" || requested == types.ReturnConsumedCapacityNone {
+		return types.ReturnConsumedCapacityTotal
+	}
+	return requested
+}
+
+// errorCode returns the code of the DynamoDB error, e.g.
+// "ConditionalCheckFailedException", or a short description of an error that
+// did not come from DynamoDB.
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.ErrorCode()
+	case errors.Is(err, context.DeadlineExceeded):
+		return "DeadlineExceeded"
+	case errors.Is(err, context.Canceled):
+		return "Canceled"
+	default:
+		return "Unknown"
+	}
+}
+
+// pointers returns pointers to the capacities consumed by a multi-item
+// request.
+func pointers(capacities []types.ConsumedCapacity) []*types.ConsumedCapacity {
+	result := make([]*types.ConsumedCapacity, len(capacities))
+	for i := range capacities {
+		result[i] = &capacities[i]
+	}
+	return result
+}
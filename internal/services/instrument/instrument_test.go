@@ -0,0 +1,81 @@
+package instrument
+
+import (
+	"context"
+	"testing"
+
+	"github.com/agallagher-captech/blog/internal/services/retry"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPI reports the consumed capacity DynamoDB would, and fails PutItem
+// with the provided error.
+type fakeAPI struct {
+	retry.API
+	putErr    error
+	requested []types.ReturnConsumedCapacity
+}
+
+func (f *fakeAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.requested = append(f.requested, params.ReturnConsumedCapacity)
+	return &dynamodb.GetItemOutput{ConsumedCapacity: &types.ConsumedCapacity{
+		TableName:     params.TableName,
+		CapacityUnits: aws.Float64(0.5),
+	}}, nil
+}
+
+func (f *fakeAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.requested = append(f.requested, params.ReturnConsumedCapacity)
+	return nil, f.putErr
+}
+
+func (f *fakeAPI) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	f.requested = append(f.requested, params.ReturnConsumedCapacity)
+	return &dynamodb.TransactWriteItemsOutput{ConsumedCapacity: []types.ConsumedCapacity{
+		{TableName: aws.String("BlogContent"), CapacityUnits: aws.Float64(4)},
+		{TableName: aws.String("Other"), CapacityUnits: aws.Float64(2)},
+	}}, nil
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.TODO()
+	metrics, err := NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	api := &fakeAPI{putErr: &types.ConditionalCheckFailedException{}}
+	client := NewClient(api, metrics)
+
+	input := &dynamodb.GetItemInput{TableName: aws.String("BlogContent")}
+	_, err = client.GetItem(ctx, input)
+	require.NoError(t, err)
+	_, err = client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:              aws.String("BlogContent"),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityIndexes,
+	})
+	require.NoError(t, err)
+	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("BlogContent")})
+	assert.Error(t, err, "error was not returned")
+	_, err = client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{})
+	require.NoError(t, err)
+
+	// Consumed capacity is always asked for, without changing the caller's input
+	assert.Equal(t, []types.ReturnConsumedCapacity{
+		types.ReturnConsumedCapacityTotal,
+		types.ReturnConsumedCapacityIndexes,
+		types.ReturnConsumedCapacityTotal,
+		types.ReturnConsumedCapacityTotal,
+	}, api.requested, "consumed capacity was not requested")
+	assert.Empty(t, input.ReturnConsumedCapacity, "caller's input was changed")
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.requests.WithLabelValues("GetItem")), "requests were not counted")
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.errors.WithLabelValues("PutItem", "ConditionalCheckFailedException")), "error was not counted by code")
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.capacity.WithLabelValues("GetItem", "BlogContent")), "capacity was not added up")
+	assert.Equal(t, float64(4), testutil.ToFloat64(metrics.capacity.WithLabelValues("TransactWriteItems", "BlogContent")), "capacity was not split by table")
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.capacity.WithLabelValues("TransactWriteItems", "Other")), "capacity was not split by table")
+	assert.Equal(t, 3, testutil.CollectAndCount(metrics.duration), "latency was not recorded per operation")
+}
@@ -0,0 +1,380 @@
+// Package memory provides an in-memory implementation of the DynamoDB client
+// used by the services, for tests and local development. It holds tables in
+// memory and evaluates key conditions, filters, condition expressions and
+// update expressions itself, so callers can assert on the state of the table
+// instead of the requests that were sent.
+//
+// Only top-level attribute paths are supported in expressions, reserved words
+// are not rejected, and there are no throughput or size limits. Query and
+// Scan only return a LastEvaluatedKey when more items remain.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/smithy-go"
+)
+
+// Client is an in-memory DynamoDB client. It is safe for concurrent use, and
+// every request is applied atomically.
+type Client struct {
+	mu     sync.Mutex
+	tables map[string]*table
+}
+
+// NewClient returns a Client with no tables.
+func NewClient() *Client {
+	return &Client{tables: make(map[string]*table)}
+}
+
+// table is a table held in memory, with its items keyed by their encoded
+// primary key.
+type table struct {
+	name        string
+	hashKey     string
+	rangeKey    string
+	keyTypes    map[string]types.ScalarAttributeType
+	indexes     map[string]*index
+	description *types.TableDescription
+	items       map[string]map[string]types.AttributeValue
+}
+
+// index is a global or local secondary index of a table. Indexes are not
+// stored, their items are found from the table's items when queried.
+type index struct {
+	name       string
+	hashKey    string
+	rangeKey   string
+	projection types.Projection
+}
+
+// validationError returns the error DynamoDB returns for an invalid request.
+func validationError(format string, args ...any) error {
+	return &smithy.GenericAPIError{
+		Code:    "ValidationException",
+		Message: fmt.Sprintf(format, args...),
+		Fault:   smithy.FaultClient,
+	}
+}
+
+// CreateTable creates a table with the provided key schema and secondary
+// indexes. Provisioned throughput, streams and other settings are ignored.
+func (c *Client) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := aws.StringValue(params.TableName)
+	if name == "" {
+		return nil, validationError("TableName must not be empty")
+	}
+	if _, ok := c.tables[name]; ok {
+		return nil, &types.ResourceInUseException{Message: aws.String("Table already exists: " + name)}
+	}
+
+	t := &table{
+		name:     name,
+		keyTypes: make(map[string]types.ScalarAttributeType),
+		indexes:  make(map[string]*index),
+		items:    make(map[string]map[string]types.AttributeValue),
+	}
+	for _, definition := range params.AttributeDefinitions {
+		t.keyTypes[aws.StringValue(definition.AttributeName)] = definition.AttributeType
+	}
+
+	var err error
+	if t.hashKey, t.rangeKey, err = t.keySchema(params.KeySchema); err != nil {
+		return nil, err
+	}
+	for _, gsi := range params.GlobalSecondaryIndexes {
+		if err = t.addIndex(gsi.IndexName, gsi.KeySchema, gsi.Projection); err != nil {
+			return nil, err
+		}
+	}
+	for _, lsi := range params.LocalSecondaryIndexes {
+		if err = t.addIndex(lsi.IndexName, lsi.KeySchema, lsi.Projection); err != nil {
+			return nil, err
+		}
+	}
+
+	t.description = &types.TableDescription{
+		TableName:            aws.String(name),
+		TableStatus:          types.TableStatusActive,
+		KeySchema:            params.KeySchema,
+		AttributeDefinitions: params.AttributeDefinitions,
+	}
+	for _, gsi := range params.GlobalSecondaryIndexes {
+		t.description.GlobalSecondaryIndexes = append(t.description.GlobalSecondaryIndexes, types.GlobalSecondaryIndexDescription{
+			IndexName:   gsi.IndexName,
+			IndexStatus: types.IndexStatusActive,
+			KeySchema:   gsi.KeySchema,
+			Projection:  gsi.Projection,
+		})
+	}
+	c.tables[name] = t
+
+	return &dynamodb.CreateTableOutput{TableDescription: t.description}, nil
+}
+
+// keySchema returns the hash and range key of a key schema, checking that they
+// are defined as attributes of the table.
+func (t *table) keySchema(schema []types.KeySchemaElement) (string, string, error) {
+	var hashKey, rangeKey string
+	for _, element := range schema {
+		name := aws.StringValue(element.AttributeName)
+		if _, ok := t.keyTypes[name]; !ok {
+			return "", "", validationError("key attribute %s is not defined in AttributeDefinitions", name)
+		}
+		switch element.KeyType {
+		case types.KeyTypeHash:
+			hashKey = name
+		case types.KeyTypeRange:
+			rangeKey = name
+		}
+	}
+	if hashKey == "" {
+		return "", "", validationError("a key schema must have a HASH key")
+	}
+	return hashKey, rangeKey, nil
+}
+
+// addIndex adds a secondary index to the table.
+func (t *table) addIndex(name *string, schema []types.KeySchemaElement, projection *types.Projection) error {
+	hashKey, rangeKey, err := t.keySchema(schema)
+	if err != nil {
+		return err
+	}
+	idx := &index{
+		name:       aws.StringValue(name),
+		hashKey:    hashKey,
+		rangeKey:   rangeKey,
+		projection: types.Projection{ProjectionType: types.ProjectionTypeAll},
+	}
+	if projection != nil {
+		idx.projection = *projection
+	}
+	t.indexes[idx.name] = idx
+	return nil
+}
+
+// DescribeTable returns the description of a table. Tables and their indexes
+// are always ACTIVE.
+func (c *Client) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	description := *t.description
+	description.ItemCount = aws.Int64(int64(len(t.items)))
+	return &dynamodb.DescribeTableOutput{Table: &description}, nil
+}
+
+// DeleteTable deletes a table and its items. Unlike DynamoDB, the table is
+// gone once DeleteTable returns rather than being in the DELETING status.
+func (c *Client) DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	delete(c.tables, t.name)
+	return &dynamodb.DeleteTableOutput{TableDescription: t.description}, nil
+}
+
+// UpdateTable creates or deletes global secondary indexes of a table. Other
+// updates, such as throughput and stream settings, are ignored.
+func (c *Client) UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	for _, definition := range params.AttributeDefinitions {
+		name := aws.StringValue(definition.AttributeName)
+		if typ, ok := t.keyTypes[name]; ok && typ != definition.AttributeType {
+			return nil, validationError("attribute %s is already defined as %s", name, typ)
+		}
+	}
+	if len(params.GlobalSecondaryIndexUpdates) > 1 {
+		return nil, validationError("only one global secondary index can be created or deleted per UpdateTable request")
+	}
+
+	// New attribute definitions are only kept if the indexes are created.
+	keyTypes := maps.Clone(t.keyTypes)
+	description := *t.description
+	for _, definition := range params.AttributeDefinitions {
+		name := aws.StringValue(definition.AttributeName)
+		if _, ok := t.keyTypes[name]; !ok {
+			t.keyTypes[name] = definition.AttributeType
+			description.AttributeDefinitions = append(slices.Clone(description.AttributeDefinitions), definition)
+		}
+	}
+	for _, update := range params.GlobalSecondaryIndexUpdates {
+		switch {
+		case update.Create != nil:
+			name := aws.StringValue(update.Create.IndexName)
+			if _, ok := t.indexes[name]; ok {
+				return nil, validationError("the table already has an index named %s", name)
+			}
+			if err = t.addIndex(update.Create.IndexName, update.Create.KeySchema, update.Create.Projection); err != nil {
+				t.keyTypes = keyTypes
+				return nil, err
+			}
+			description.GlobalSecondaryIndexes = append(slices.Clone(description.GlobalSecondaryIndexes), types.GlobalSecondaryIndexDescription{
+				IndexName:   update.Create.IndexName,
+				IndexStatus: types.IndexStatusActive,
+				KeySchema:   update.Create.KeySchema,
+				Projection:  update.Create.Projection,
+			})
+		case update.Delete != nil:
+			name := aws.StringValue(update.Delete.IndexName)
+			if _, ok := t.indexes[name]; !ok {
+				return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found: index " + name)}
+			}
+			delete(t.indexes, name)
+			description.GlobalSecondaryIndexes = slices.DeleteFunc(slices.Clone(description.GlobalSecondaryIndexes), func(gsi types.GlobalSecondaryIndexDescription) bool {
+				return aws.StringValue(gsi.IndexName) == name
+			})
+		}
+	}
+	t.description = &description
+
+	return &dynamodb.UpdateTableOutput{TableDescription: t.description}, nil
+}
+
+// ListTables returns the names of every table in alphabetical order.
+func (c *Client) ListTables(ctx context.Context, params *dynamodb.ListTablesInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ListTablesOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.tables))
+	for name := range c.tables {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return &dynamodb.ListTablesOutput{TableNames: names}, nil
+}
+
+// table returns the named table, or the error DynamoDB returns for a table
+// that does not exist.
+func (c *Client) table(name *string) (*table, error) {
+	t, ok := c.tables[aws.StringValue(name)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found")}
+	}
+	return t, nil
+}
+
+// primaryKey returns the encoded primary key of the provided item or key. If
+// exact is true, the key must not hold any other attributes.
+func (t *table) primaryKey(key map[string]types.AttributeValue, exact bool) (string, error) {
+	if exact {
+		want := 1
+		if t.rangeKey != "" {
+			want = 2
+		}
+		if len(key) != want {
+			return "", validationError("the provided key element does not match the schema")
+		}
+	}
+
+	encoded := ""
+	for _, name := range []string{t.hashKey, t.rangeKey} {
+		if name == "" {
+			continue
+		}
+		value, ok := key[name]
+		if !ok {
+			return "", validationError("the provided key element does not match the schema: missing %s", name)
+		}
+		if err := t.checkKeyValue(name, value); err != nil {
+			return "", err
+		}
+		encoded += keyString(value)
+	}
+	return encoded, nil
+}
+
+// checkKeyValue returns an error if the value of a key attribute does not
+// match its definition or is empty.
+func (t *table) checkKeyValue(name string, value types.AttributeValue) error {
+	if typeOf(value) != string(t.keyTypes[name]) {
+		return validationError("the provided key element does not match the schema: %s must be of type %s", name, t.keyTypes[name])
+	}
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		if v.Value == "" {
+			return validationError("the AttributeValue for a key attribute cannot contain an empty string value: %s", name)
+		}
+	case *types.AttributeValueMemberB:
+		if len(v.Value) == 0 {
+			return validationError("the AttributeValue for a key attribute cannot contain an empty binary value: %s", name)
+		}
+	}
+	return nil
+}
+
+// checkItem returns the encoded primary key of an item that is about to be
+// written, or an error if DynamoDB would reject it.
+func (t *table) checkItem(item map[string]types.AttributeValue) (string, error) {
+	key, err := t.primaryKey(item, false)
+	if err != nil {
+		return "", err
+	}
+	for name, value := range item {
+		if err = validateValue(name, value); err != nil {
+			return "", validationError("one or more parameter values were invalid: %s", err)
+		}
+	}
+	for _, idx := range t.indexes {
+		for _, name := range []string{idx.hashKey, idx.rangeKey} {
+			value, ok := item[name]
+			if name == "" || !ok {
+				continue
+			}
+			if err = t.checkKeyValue(name, value); err != nil {
+				return "", validationError("one or more parameter values are not valid: a value specified for a secondary index key is not supported. IndexName: %s, IndexKey: %s", idx.name, name)
+			}
+		}
+	}
+	return key, nil
+}
+
+// keyAttributes returns the attributes of the item that make up its primary
+// key.
+func (t *table) keyAttributes(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	key := map[string]types.AttributeValue{t.hashKey: cloneValue(item[t.hashKey])}
+	if t.rangeKey != "" {
+		key[t.rangeKey] = cloneValue(item[t.rangeKey])
+	}
+	return key
+}
@@ -0,0 +1,364 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedDir is the seed data of the repository, relative to this package.
+const seedDir = "../../../dynamodb_seed"
+
+func newSeededClient(t *testing.T) *Client {
+	t.Helper()
+	client := NewClient()
+	require.NoError(t, client.LoadDir(seedDir), "failed to load seed data")
+	return client
+}
+
+func s(value string) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: value}
+}
+
+func n(value string) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: value}
+}
+
+func isValidationError(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationException"
+}
+
+func TestClient_LoadDir(t *testing.T) {
+	client := newSeededClient(t)
+
+	tables, err := client.ListTables(context.TODO(), &dynamodb.ListTablesInput{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"BlogContent"}, tables.TableNames, "tables did not match")
+
+	result, err := client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String("BlogContent"),
+		Key: map[string]types.AttributeValue{
+			"PK": s("BLOG#17e16813-c203-0355-1e4c-17c630f114f3"),
+			"SK": s("METADATA"),
+		},
+		ProjectionExpression: aws.String("title, score"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]types.AttributeValue{
+		"title": s("Home Decor Ideas"),
+		"score": n("9.5"),
+	}, result.Item, "seeded blog did not match")
+}
+
+func TestClient_DeleteTable(t *testing.T) {
+	client := newSeededClient(t)
+
+	_, err := client.DeleteTable(context.TODO(), &dynamodb.DeleteTableInput{TableName: aws.String("BlogContent")})
+	require.NoError(t, err)
+
+	tables, err := client.ListTables(context.TODO(), &dynamodb.ListTablesInput{})
+	require.NoError(t, err)
+	assert.Empty(t, tables.TableNames, "table was not deleted")
+
+	_, err = client.DeleteTable(context.TODO(), &dynamodb.DeleteTableInput{TableName: aws.String("BlogContent")})
+	var notFound *types.ResourceNotFoundException
+	assert.ErrorAs(t, err, &notFound, "deleting a missing table did not fail")
+}
+
+func TestClient_PutItem(t *testing.T) {
+	item := map[string]types.AttributeValue{
+		"PK":   s("USER#1"),
+		"SK":   s("PROFILE"),
+		"name": s("Emma"),
+	}
+
+	tests := map[string]struct {
+		item      map[string]types.AttributeValue
+		condition *string
+		names     map[string]string
+		wantErr   func(error) bool
+	}{
+		"new item": {
+			item: map[string]types.AttributeValue{"PK": s("USER#2"), "SK": s("PROFILE")},
+		},
+		"condition met": {
+			item:      map[string]types.AttributeValue{"PK": s("USER#2"), "SK": s("PROFILE")},
+			condition: aws.String("attribute_not_exists(PK)"),
+		},
+		"condition not met": {
+			item:      item,
+			condition: aws.String("attribute_not_exists(PK)"),
+			wantErr: func(err error) bool {
+				var ccf *types.ConditionalCheckFailedException
+				return errors.As(err, &ccf) && ccf.Item["name"] != nil
+			},
+		},
+		"missing key": {
+			item:    map[string]types.AttributeValue{"PK": s("USER#2")},
+			wantErr: isValidationError,
+		},
+		"empty index key": {
+			item:    map[string]types.AttributeValue{"PK": s("USER#2"), "SK": s("PROFILE"), "GSI1SK": s("")},
+			wantErr: isValidationError,
+		},
+		"unused placeholder": {
+			item:      map[string]types.AttributeValue{"PK": s("USER#2"), "SK": s("PROFILE")},
+			condition: aws.String("attribute_not_exists(#pk)"),
+			names:     map[string]string{"#pk": "PK", "#sk": "SK"},
+			wantErr:   isValidationError,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := newSeededClient(t)
+			_, err := client.PutItem(context.TODO(), &dynamodb.PutItemInput{TableName: aws.String("BlogContent"), Item: item})
+			require.NoError(t, err)
+
+			_, err = client.PutItem(context.TODO(), &dynamodb.PutItemInput{
+				TableName:                           aws.String("BlogContent"),
+				Item:                                tc.item,
+				ConditionExpression:                 tc.condition,
+				ExpressionAttributeNames:            tc.names,
+				ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+			})
+
+			if tc.wantErr != nil {
+				assert.True(t, tc.wantErr(err), "unexpected error: %v", err)
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+		})
+	}
+}
+
+func TestClient_UpdateItem(t *testing.T) {
+	client := newSeededClient(t)
+	key := map[string]types.AttributeValue{
+		"PK": s("BLOG#17e16813-c203-0355-1e4c-17c630f114f3"),
+		"SK": s("METADATA"),
+	}
+
+	result, err := client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+		TableName:           aws.String("BlogContent"),
+		Key:                 key,
+		UpdateExpression:    aws.String("SET #title = :title, #version = if_not_exists(#version, :zero) + :one REMOVE score ADD tags :tags"),
+		ConditionExpression: aws.String("attribute_exists(PK) AND attribute_not_exists(#version)"),
+		ExpressionAttributeNames: map[string]string{
+			"#title":   "title",
+			"#version": "version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":title": s("Kitchen Decor Ideas"),
+			":zero":  n("0"),
+			":one":   n("1"),
+			":tags":  &types.AttributeValueMemberSS{Value: []string{"home"}},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, s("Kitchen Decor Ideas"), result.Attributes["title"], "title was not set")
+	assert.Equal(t, n("1"), result.Attributes["version"], "version was not incremented")
+	assert.Equal(t, &types.AttributeValueMemberSS{Value: []string{"home"}}, result.Attributes["tags"], "tags were not added")
+	assert.NotContains(t, result.Attributes, "score", "score was not removed")
+
+	// The version now exists, so the same condition fails
+	_, err = client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+		TableName:                aws.String("BlogContent"),
+		Key:                      key,
+		UpdateExpression:         aws.String("SET #version = #version + :one"),
+		ConditionExpression:      aws.String("attribute_not_exists(#version)"),
+		ExpressionAttributeNames: map[string]string{"#version": "version"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one": n("1"),
+		},
+	})
+	var ccf *types.ConditionalCheckFailedException
+	assert.ErrorAs(t, err, &ccf, "condition should have failed")
+
+	// Key attributes cannot be updated
+	_, err = client.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+		TableName:        aws.String("BlogContent"),
+		Key:              key,
+		UpdateExpression: aws.String("REMOVE SK"),
+	})
+	assert.True(t, isValidationError(err), "unexpected error: %v", err)
+}
+
+func TestClient_Query(t *testing.T) {
+	client := newSeededClient(t)
+	blogPK := s("BLOG#17e16813-c203-0355-1e4c-17c630f114f3")
+
+	tests := map[string]struct {
+		input     *dynamodb.QueryInput
+		wantCount int32
+		wantFirst types.AttributeValue
+		wantMore  bool
+		wantErr   bool
+	}{
+		"partition": {
+			input: &dynamodb.QueryInput{
+				KeyConditionExpression:    aws.String("PK = :pk"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":pk": blogPK},
+			},
+			wantCount: 6,
+			wantFirst: s("METADATA"),
+		},
+		"begins_with": {
+			input: &dynamodb.QueryInput{
+				KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":pk": blogPK,
+					":sk": s("USER#"),
+				},
+			},
+			wantCount: 5,
+			wantFirst: s("USER#1d87067c-f1fd-5516-dbac-104733ba0542"),
+		},
+		"descending with a limit": {
+			input: &dynamodb.QueryInput{
+				KeyConditionExpression:    aws.String("PK = :pk"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":pk": blogPK},
+				ScanIndexForward:          aws.Bool(false),
+				Limit:                     aws.Int32(1),
+			},
+			wantCount: 1,
+			wantFirst: s("USER#eb0a5951-04b5-77c4-3100-5da6eb3f712a"),
+			wantMore:  true,
+		},
+		"filter": {
+			input: &dynamodb.QueryInput{
+				KeyConditionExpression: aws.String("PK = :pk"),
+				FilterExpression:       aws.String("contains(message, :word)"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":pk":   blogPK,
+					":word": s("Pinterest"),
+				},
+			},
+			wantCount: 1,
+			wantFirst: s("USER#1f5925bc-65db-d1c2-188a-70aeee464468"),
+		},
+		"index": {
+			input: &dynamodb.QueryInput{
+				IndexName:              aws.String("GSI1"),
+				KeyConditionExpression: aws.String("GSI1PK = :pk AND GSI1SK = :sk"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":pk": s("BLOG"),
+					":sk": s("USER#d2eddb69-f92f-694d-450d-e7cdb6decce3"),
+				},
+			},
+			wantCount: 2,
+			wantFirst: s("METADATA"),
+		},
+		"missing hash key": {
+			input: &dynamodb.QueryInput{
+				KeyConditionExpression:    aws.String("begins_with(SK, :sk)"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":sk": s("USER#")},
+			},
+			wantErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc.input.TableName = aws.String("BlogContent")
+
+			result, err := client.Query(context.TODO(), tc.input)
+
+			if tc.wantErr {
+				assert.True(t, isValidationError(err), "unexpected error: %v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantCount, result.Count, "count did not match")
+			require.NotEmpty(t, result.Items, "no items were returned")
+			assert.Equal(t, tc.wantFirst, result.Items[0]["SK"], "first item did not match")
+			assert.Equal(t, tc.wantMore, result.LastEvaluatedKey != nil, "LastEvaluatedKey did not match")
+		})
+	}
+}
+
+func TestClient_Query_Pagination(t *testing.T) {
+	client := newSeededClient(t)
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String("BlogContent"),
+		IndexName:                 aws.String("GSI1"),
+		KeyConditionExpression:    aws.String("GSI1PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("USER")},
+		Limit:                     aws.Int32(2),
+	}
+
+	all, err := client.Query(context.TODO(), &dynamodb.QueryInput{
+		TableName:                 input.TableName,
+		IndexName:                 input.IndexName,
+		KeyConditionExpression:    input.KeyConditionExpression,
+		ExpressionAttributeValues: input.ExpressionAttributeValues,
+	})
+	require.NoError(t, err)
+
+	var paged []map[string]types.AttributeValue
+	for {
+		result, err := client.Query(context.TODO(), input)
+		require.NoError(t, err)
+		paged = append(paged, result.Items...)
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		assert.Contains(t, result.LastEvaluatedKey, "GSI1SK", "LastEvaluatedKey is missing the index key")
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+	assert.Equal(t, all.Items, paged, "pages did not add up to the full result")
+}
+
+func TestClient_TransactWriteItems(t *testing.T) {
+	client := newSeededClient(t)
+	put := func(pk, condition string) types.TransactWriteItem {
+		return types.TransactWriteItem{Put: &types.Put{
+			TableName:           aws.String("BlogContent"),
+			Item:                map[string]types.AttributeValue{"PK": s(pk), "SK": s("EMAIL")},
+			ConditionExpression: aws.String(condition),
+		}}
+	}
+
+	_, err := client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			put("EMAIL#new@example.com", "attribute_not_exists(PK)"),
+			put("EMAIL#taken@example.com", "attribute_not_exists(PK)"),
+		},
+	})
+	require.NoError(t, err)
+
+	// The second put fails, so the first must not be written either
+	_, err = client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			put("EMAIL#other@example.com", "attribute_not_exists(PK)"),
+			put("EMAIL#taken@example.com", "attribute_not_exists(PK)"),
+		},
+	})
+	var tce *types.TransactionCanceledException
+	require.ErrorAs(t, err, &tce, "transaction should have been cancelled")
+	assert.Equal(t, "None", aws.StringValue(tce.CancellationReasons[0].Code), "first reason did not match")
+	assert.Equal(t, "ConditionalCheckFailed", aws.StringValue(tce.CancellationReasons[1].Code), "second reason did not match")
+
+	result, err := client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String("BlogContent"),
+		Key:       map[string]types.AttributeValue{"PK": s("EMAIL#other@example.com"), "SK": s("EMAIL")},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, result.Item, "cancelled transaction was partly written")
+
+	// An item may only be written once per transaction
+	_, err = client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			put("EMAIL#twice@example.com", "attribute_not_exists(PK)"),
+			put("EMAIL#twice@example.com", "attribute_not_exists(PK)"),
+		},
+	})
+	assert.True(t, isValidationError(err), "unexpected error: %v", err)
+}
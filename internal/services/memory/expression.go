@@ -0,0 +1,667 @@
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// tokenKind identifies the kind of a token in an expression.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+
+	// tokenIdent is a bare attribute name, keyword or function name.
+	tokenIdent
+
+	// tokenName is an expression attribute name placeholder, e.g. #version.
+	tokenName
+
+	// tokenValue is an expression attribute value placeholder, e.g. :pk.
+	tokenValue
+
+	// tokenSymbol is punctuation or a comparator, e.g. "(" or "<=".
+	tokenSymbol
+)
+
+// token is a single lexical element of an expression.
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// symbols are the punctuation and comparators of the expression syntax,
+// longest first so that "<=" is not lexed as "<" followed by "=".
+var symbols = []string{"<>", "<=", ">=", "(", ")", ",", "=", "<", ">", "+", "-", ".", "[", "]"}
+
+// lex splits an expression into tokens.
+func lex(expression string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#' || c == ':':
+			end := i + 1
+			for end < len(expression) && isNameByte(expression[end]) {
+				end++
+			}
+			if end == i+1 {
+				return nil, fmt.Errorf("invalid placeholder at position %d", i)
+			}
+			kind := tokenName
+			if c == ':' {
+				kind = tokenValue
+			}
+			tokens = append(tokens, token{kind: kind, text: expression[i:end]})
+			i = end
+		case isNameByte(c):
+			end := i
+			for end < len(expression) && isNameByte(expression[end]) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: expression[i:end]})
+			i = end
+		default:
+			matched := false
+			for _, symbol := range symbols {
+				if strings.HasPrefix(expression[i:], symbol) {
+					tokens = append(tokens, token{kind: tokenSymbol, text: symbol})
+					i += len(symbol)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("invalid character %q at position %d", c, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+// isNameByte reports whether c may appear in an attribute name or
+// placeholder.
+func isNameByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// params resolves the placeholders of a request's expressions and records
+// which were used, since DynamoDB rejects requests with unused placeholders.
+type params struct {
+	names      map[string]string
+	values     map[string]types.AttributeValue
+	usedNames  map[string]bool
+	usedValues map[string]bool
+}
+
+// newParams returns params for the provided expression attribute names and
+// values.
+func newParams(names map[string]string, values map[string]types.AttributeValue) *params {
+	return &params{
+		names:      names,
+		values:     values,
+		usedNames:  make(map[string]bool),
+		usedValues: make(map[string]bool),
+	}
+}
+
+// name returns the attribute name a placeholder stands for.
+func (p *params) name(placeholder string) (string, error) {
+	name, ok := p.names[placeholder]
+	if !ok {
+		return "", fmt.Errorf("an expression attribute name used in the document path is not defined; attribute name: %s", placeholder)
+	}
+	p.usedNames[placeholder] = true
+	return name, nil
+}
+
+// value returns the attribute value a placeholder stands for.
+func (p *params) value(placeholder string) (types.AttributeValue, error) {
+	value, ok := p.values[placeholder]
+	if !ok {
+		return nil, fmt.Errorf("an expression attribute value used in expression is not defined; attribute value: %s", placeholder)
+	}
+	if err := validateValue(placeholder, value); err != nil {
+		return nil, err
+	}
+	p.usedValues[placeholder] = true
+	return value, nil
+}
+
+// checkUnused returns an error if any placeholder was never used by the
+// request's expressions.
+func (p *params) checkUnused() error {
+	var unused []string
+	for placeholder := range p.names {
+		if !p.usedNames[placeholder] {
+			unused = append(unused, placeholder)
+		}
+	}
+	for placeholder := range p.values {
+		if !p.usedValues[placeholder] {
+			unused = append(unused, placeholder)
+		}
+	}
+	if len(unused) > 0 {
+		slices.Sort(unused)
+		return fmt.Errorf("value provided in ExpressionAttributeNames or ExpressionAttributeValues unused in expressions: %s", strings.Join(unused, ", "))
+	}
+	return nil
+}
+
+// parser is a recursive descent parser over the tokens of one expression.
+type parser struct {
+	tokens []token
+	pos    int
+	params *params
+}
+
+// newParser lexes the provided expression and returns a parser for it.
+func newParser(expression string, params *params) (*parser, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, fmt.Errorf("expression must not be empty")
+	}
+	tokens, err := lex(expression)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{tokens: tokens, params: params}, nil
+}
+
+// peek returns the next token without consuming it.
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the next token.
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// isKeyword reports whether the next token is the provided keyword. Keywords
+// are case-insensitive.
+func (p *parser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+// isSymbol reports whether the next token is the provided symbol.
+func (p *parser) isSymbol(symbol string) bool {
+	t := p.peek()
+	return t.kind == tokenSymbol && t.text == symbol
+}
+
+// isFunction reports whether the next tokens are a call of the named function.
+func (p *parser) isFunction(name string) bool {
+	t := p.peek()
+	after := p.tokens[min(p.pos+1, len(p.tokens)-1)]
+	return t.kind == tokenIdent && t.text == name && after.kind == tokenSymbol && after.text == "("
+}
+
+// expect consumes the provided symbol or returns an error.
+func (p *parser) expect(symbol string) error {
+	if !p.isSymbol(symbol) {
+		return p.unexpected()
+	}
+	p.next()
+	return nil
+}
+
+// expectEOF returns an error if any tokens remain.
+func (p *parser) expectEOF() error {
+	if p.peek().kind != tokenEOF {
+		return p.unexpected()
+	}
+	return nil
+}
+
+// unexpected returns a syntax error for the next token.
+func (p *parser) unexpected() error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return fmt.Errorf("invalid expression: unexpected end of expression")
+	}
+	return fmt.Errorf("invalid expression: syntax error near %q", t.text)
+}
+
+// parsePath parses an attribute name, either bare or as a placeholder. Only
+// top-level attributes are supported.
+func (p *parser) parsePath() (string, error) {
+	t := p.peek()
+	var name string
+	switch t.kind {
+	case tokenIdent:
+		name = t.text
+	case tokenName:
+		var err error
+		if name, err = p.params.name(t.text); err != nil {
+			return "", err
+		}
+	default:
+		return "", p.unexpected()
+	}
+	p.next()
+	if p.isSymbol(".") || p.isSymbol("[") {
+		return "", fmt.Errorf("nested attribute paths are not supported: %s", t.text)
+	}
+	return name, nil
+}
+
+// operand is a value in a condition: an attribute of the item, an expression
+// attribute value or the size of an attribute.
+type operand struct {
+	path  string
+	value types.AttributeValue
+	size  bool
+}
+
+// resolve returns the value of the operand for the provided item. The boolean
+// result is false if the operand refers to an attribute the item does not
+// have.
+func (o operand) resolve(item map[string]types.AttributeValue) (types.AttributeValue, bool) {
+	if o.value != nil {
+		return o.value, true
+	}
+	value, ok := item[o.path]
+	if !ok {
+		return nil, false
+	}
+	if !o.size {
+		return value, true
+	}
+
+	var size int
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		size = utf8.RuneCountInString(v.Value)
+	case *types.AttributeValueMemberB:
+		size = len(v.Value)
+	case *types.AttributeValueMemberL:
+		size = len(v.Value)
+	case *types.AttributeValueMemberM:
+		size = len(v.Value)
+	case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+		size = len(setElements(v))
+	default:
+		return nil, false
+	}
+	return &types.AttributeValueMemberN{Value: fmt.Sprint(size)}, true
+}
+
+// parseOperand parses a condition operand.
+func (p *parser) parseOperand() (operand, error) {
+	if p.isFunction("size") {
+		p.next()
+		p.next()
+		path, err := p.parsePath()
+		if err != nil {
+			return operand{}, err
+		}
+		return operand{path: path, size: true}, p.expect(")")
+	}
+
+	if t := p.peek(); t.kind == tokenValue {
+		p.next()
+		value, err := p.params.value(t.text)
+		if err != nil {
+			return operand{}, err
+		}
+		return operand{value: value}, nil
+	}
+
+	path, err := p.parsePath()
+	if err != nil {
+		return operand{}, err
+	}
+	return operand{path: path}, nil
+}
+
+// condition is a parsed condition, key condition or filter expression.
+type condition interface {
+	eval(item map[string]types.AttributeValue) bool
+}
+
+// parseCondition parses a complete condition expression.
+func parseCondition(expression string, params *params) (condition, error) {
+	p, err := newParser(expression, params)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	return cond, p.expectEOF()
+}
+
+// logical is the AND or OR of two conditions.
+type logical struct {
+	and         bool
+	left, right condition
+}
+
+func (c logical) eval(item map[string]types.AttributeValue) bool {
+	if c.and {
+		return c.left.eval(item) && c.right.eval(item)
+	}
+	return c.left.eval(item) || c.right.eval(item)
+}
+
+// negation is the NOT of a condition.
+type negation struct {
+	cond condition
+}
+
+func (c negation) eval(item map[string]types.AttributeValue) bool {
+	return !c.cond.eval(item)
+}
+
+// comparison compares two operands with one of the comparators.
+type comparison struct {
+	comparator  string
+	left, right operand
+}
+
+func (c comparison) eval(item map[string]types.AttributeValue) bool {
+	left, okLeft := c.left.resolve(item)
+	right, okRight := c.right.resolve(item)
+	if !okLeft || !okRight {
+		// A missing attribute is unequal to everything and cannot be
+		// ordered.
+		return c.comparator == "<>"
+	}
+
+	switch c.comparator {
+	case "=":
+		return equalValues(left, right)
+	case "<>":
+		return !equalValues(left, right)
+	}
+
+	order, ok := compareValues(left, right)
+	if !ok {
+		return false
+	}
+	switch c.comparator {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	default:
+		return order >= 0
+	}
+}
+
+// between checks that an operand lies within an inclusive range.
+type between struct {
+	operand, low, high operand
+}
+
+func (c between) eval(item map[string]types.AttributeValue) bool {
+	return comparison{comparator: ">=", left: c.operand, right: c.low}.eval(item) &&
+		comparison{comparator: "<=", left: c.operand, right: c.high}.eval(item)
+}
+
+// in checks that an operand is equal to one of a list of operands.
+type in struct {
+	operand operand
+	list    []operand
+}
+
+func (c in) eval(item map[string]types.AttributeValue) bool {
+	for _, candidate := range c.list {
+		if (comparison{comparator: "=", left: c.operand, right: candidate}).eval(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// function is a call of one of the condition functions, such as
+// attribute_exists or begins_with.
+type function struct {
+	name string
+	path string
+	arg  operand
+}
+
+func (c function) eval(item map[string]types.AttributeValue) bool {
+	value, exists := item[c.path]
+	switch c.name {
+	case "attribute_exists":
+		return exists
+	case "attribute_not_exists":
+		return !exists
+	}
+	if !exists {
+		return false
+	}
+
+	arg, ok := c.arg.resolve(item)
+	if !ok {
+		return false
+	}
+	switch c.name {
+	case "attribute_type":
+		s, ok := arg.(*types.AttributeValueMemberS)
+		return ok && typeOf(value) == s.Value
+	case "begins_with":
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			prefix, ok := arg.(*types.AttributeValueMemberS)
+			return ok && strings.HasPrefix(v.Value, prefix.Value)
+		case *types.AttributeValueMemberB:
+			prefix, ok := arg.(*types.AttributeValueMemberB)
+			return ok && bytes.HasPrefix(v.Value, prefix.Value)
+		}
+		return false
+	default: // contains
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			sub, ok := arg.(*types.AttributeValueMemberS)
+			return ok && strings.Contains(v.Value, sub.Value)
+		case *types.AttributeValueMemberL:
+			return containsValue(v.Value, arg)
+		case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+			return containsValue(setElements(v), arg)
+		}
+		return false
+	}
+}
+
+// conditionFunctions are the functions that may be used as a condition, with
+// the number of arguments each takes.
+var conditionFunctions = map[string]int{
+	"attribute_exists":     1,
+	"attribute_not_exists": 1,
+	"attribute_type":       2,
+	"begins_with":          2,
+	"contains":             2,
+}
+
+// parseOr parses conditions joined by OR, the loosest binding operator.
+func (p *parser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logical{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses conditions joined by AND.
+func (p *parser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logical{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseNot parses a condition that may be negated with NOT.
+func (p *parser) parseNot() (condition, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		cond, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return negation{cond: cond}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses a parenthesised condition, a function call or a
+// comparison.
+func (p *parser) parsePrimary() (condition, error) {
+	if p.isSymbol("(") {
+		p.next()
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return cond, p.expect(")")
+	}
+
+	for name, args := range conditionFunctions {
+		if p.isFunction(name) {
+			return p.parseFunction(name, args)
+		}
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.isKeyword("BETWEEN"):
+		p.next()
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword("AND") {
+			return nil, p.unexpected()
+		}
+		p.next()
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return between{operand: left, low: low, high: high}, nil
+	case p.isKeyword("IN"):
+		p.next()
+		if err = p.expect("("); err != nil {
+			return nil, err
+		}
+		var list []operand
+		for {
+			candidate, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, candidate)
+			if !p.isSymbol(",") {
+				break
+			}
+			p.next()
+		}
+		return in{operand: left, list: list}, p.expect(")")
+	}
+
+	t := p.peek()
+	if t.kind != tokenSymbol || !slices.Contains([]string{"=", "<>", "<", "<=", ">", ">="}, t.text) {
+		return nil, p.unexpected()
+	}
+	p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return comparison{comparator: t.text, left: left, right: right}, nil
+}
+
+// parseFunction parses the arguments of a condition function call.
+func (p *parser) parseFunction(name string, args int) (condition, error) {
+	p.next()
+	p.next()
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	fn := function{name: name, path: path}
+	if args == 2 {
+		if err = p.expect(","); err != nil {
+			return nil, err
+		}
+		if fn.arg, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+	}
+	return fn, p.expect(")")
+}
+
+// parseProjection parses a projection expression into the names of the
+// attributes it selects.
+func parseProjection(expression string, params *params) ([]string, error) {
+	p, err := newParser(expression, params)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for {
+		name, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.isSymbol(",") {
+			break
+		}
+		p.next()
+	}
+	return names, p.expectEOF()
+}
+
+// project returns a copy of the item holding only the named attributes.
+func project(item map[string]types.AttributeValue, names []string) map[string]types.AttributeValue {
+	if names == nil {
+		return cloneItem(item)
+	}
+	projected := make(map[string]types.AttributeValue, len(names))
+	for _, name := range names {
+		if value, ok := item[name]; ok {
+			projected[name] = cloneValue(value)
+		}
+	}
+	return projected
+}
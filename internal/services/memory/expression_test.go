@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCondition(t *testing.T) {
+	item := map[string]types.AttributeValue{
+		"PK":      s("USER#1"),
+		"name":    s("Emma Davis"),
+		"score":   n("9.5"),
+		"version": n("2"),
+		"tags":    &types.AttributeValueMemberSS{Value: []string{"home", "decor"}},
+	}
+	names := map[string]string{"#name": "name", "#version": "version"}
+	values := map[string]types.AttributeValue{
+		":name":    s("Emma"),
+		":version": n("2.0"),
+		":low":     n("9"),
+		":high":    n("10"),
+		":tag":     s("decor"),
+		":type":    s("SS"),
+	}
+
+	tests := map[string]struct {
+		expression string
+		want       bool
+		wantErr    bool
+	}{
+		"exists":                 {expression: "attribute_exists(PK)", want: true},
+		"not exists":             {expression: "attribute_not_exists(missing)", want: true},
+		"numeric equality":       {expression: "#version = :version", want: true},
+		"missing is unequal":     {expression: "missing <> :version", want: true},
+		"missing is unordered":   {expression: "missing < :version", want: false},
+		"begins_with":            {expression: "begins_with(#name, :name)", want: true},
+		"between":                {expression: "score BETWEEN :low AND :high", want: true},
+		"in":                     {expression: "score IN (:low, :high)", want: false},
+		"contains set":           {expression: "contains(tags, :tag)", want: true},
+		"attribute_type":         {expression: "attribute_type(tags, :type)", want: true},
+		"size":                   {expression: "size(#name) > :low", want: true},
+		"precedence":             {expression: "attribute_exists(missing) AND score > :high OR #version = :version", want: true},
+		"parentheses":            {expression: "attribute_exists(missing) AND (score > :high OR #version = :version)", want: false},
+		"not":                    {expression: "NOT attribute_exists(missing)", want: true},
+		"lowercase keywords":     {expression: "#version = :version and not attribute_exists(missing)", want: true},
+		"undefined name":         {expression: "#missing = :version", wantErr: true},
+		"undefined value":        {expression: "score = :missing", wantErr: true},
+		"nested path":            {expression: "tags.first = :tag", wantErr: true},
+		"unbalanced":             {expression: "(score = :low", wantErr: true},
+		"trailing tokens":        {expression: "score = :low :high", wantErr: true},
+		"comparator without rhs": {expression: "score =", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cond, err := parseCondition(tc.expression, newParams(names, values))
+
+			if tc.wantErr {
+				assert.Error(t, err, "expected a parse error")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.want, cond.eval(item), "condition did not evaluate as expected")
+		})
+	}
+}
+
+func TestApplyUpdate(t *testing.T) {
+	values := map[string]types.AttributeValue{
+		":one":   n("1"),
+		":half":  n("0.5"),
+		":items": &types.AttributeValueMemberL{Value: []types.AttributeValue{s("b")}},
+		":tags":  &types.AttributeValueMemberSS{Value: []string{"home"}},
+	}
+
+	tests := map[string]struct {
+		expression string
+		want       map[string]types.AttributeValue
+		wantErr    bool
+	}{
+		"arithmetic": {
+			expression: "SET score = score + :half, count = :one - :half",
+			want:       map[string]types.AttributeValue{"score": n("10"), "count": n("0.5")},
+		},
+		"if_not_exists": {
+			expression: "SET version = if_not_exists(version, :one), score = if_not_exists(score, :one)",
+			want:       map[string]types.AttributeValue{"version": n("1"), "score": n("9.5")},
+		},
+		"list_append": {
+			expression: "SET items = list_append(items, :items)",
+			want: map[string]types.AttributeValue{
+				"items": &types.AttributeValueMemberL{Value: []types.AttributeValue{s("a"), s("b")}},
+			},
+		},
+		"add and delete": {
+			expression: "ADD score :one DELETE tags :tags",
+			want:       map[string]types.AttributeValue{"score": n("10.5"), "tags": nil},
+		},
+		"missing operand": {
+			expression: "SET score = missing + :one",
+			wantErr:    true,
+		},
+		"overlapping paths": {
+			expression: "SET score = :one REMOVE score",
+			wantErr:    true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			item := map[string]types.AttributeValue{
+				"score": n("9.5"),
+				"items": &types.AttributeValueMemberL{Value: []types.AttributeValue{s("a")}},
+				"tags":  &types.AttributeValueMemberSS{Value: []string{"home"}},
+			}
+
+			actions, err := parseUpdate(tc.expression, newParams(nil, values))
+			if err == nil {
+				err = applyUpdate(item, actions)
+			}
+
+			if tc.wantErr {
+				assert.Error(t, err, "expected an error")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			for attribute, want := range tc.want {
+				if want == nil {
+					assert.NotContains(t, item, attribute, "attribute was not removed")
+					continue
+				}
+				assert.Equal(t, want, item[attribute], "attribute %s did not match", attribute)
+			}
+		})
+	}
+}
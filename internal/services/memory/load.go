@@ -0,0 +1,32 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/agallagher-captech/blog/internal/seed"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// LoadDir creates the table described by the schema file in dir and loads the
+// items of its items file, the layout of ./dynamodb_seed.
+func (c *Client) LoadDir(dir string) error {
+	schema, requests, err := seed.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("[in memory.Client.LoadDir] failed to read seed data: %w", err)
+	}
+	return c.Load(schema, requests)
+}
+
+// Load creates a table and writes the provided requests, such as seed data
+// read with seed.ReadDir.
+func (c *Client) Load(schema *dynamodb.CreateTableInput, requests map[string][]types.WriteRequest) error {
+	if _, err := c.CreateTable(context.Background(), schema); err != nil {
+		return fmt.Errorf("[in memory.Client.Load] failed to create table: %w", err)
+	}
+	if _, err := seed.Write(context.Background(), c, requests); err != nil {
+		return fmt.Errorf("[in memory.Client.Load] failed to write items: %w", err)
+	}
+	return nil
+}
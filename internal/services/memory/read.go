@@ -0,0 +1,356 @@
+package memory
+
+import (
+	"context"
+	"hash/fnv"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// view is a table or one of its indexes, as read by Query and Scan.
+type view struct {
+	table    *table
+	index    *index
+	hashKey  string
+	rangeKey string
+}
+
+// view returns the table itself if indexName is nil, otherwise the named
+// index.
+func (t *table) view(indexName *string) (view, error) {
+	if indexName == nil {
+		return view{table: t, hashKey: t.hashKey, rangeKey: t.rangeKey}, nil
+	}
+	idx, ok := t.indexes[*indexName]
+	if !ok {
+		return view{}, validationError("the table does not have the specified index: %s", *indexName)
+	}
+	return view{table: t, index: idx, hashKey: idx.hashKey, rangeKey: idx.rangeKey}, nil
+}
+
+// order returns the attributes that order items in the view: its own keys,
+// followed by the table's keys for an index, since index keys need not be
+// unique.
+func (v view) order() []string {
+	names := []string{v.hashKey}
+	if v.rangeKey != "" {
+		names = append(names, v.rangeKey)
+	}
+	if v.index != nil {
+		names = append(names, v.table.hashKey)
+		if v.table.rangeKey != "" {
+			names = append(names, v.table.rangeKey)
+		}
+	}
+	return names
+}
+
+// compare orders two items, or an item and a start key, by the view's order.
+func (v view) compare(a, b map[string]types.AttributeValue) int {
+	for _, name := range v.order() {
+		if c, ok := compareValues(a[name], b[name]); ok && c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// items returns the items in the view in order. Items without the keys of an
+// index are not in the index.
+func (v view) items() []map[string]types.AttributeValue {
+	var items []map[string]types.AttributeValue
+	for _, item := range v.table.items {
+		if _, ok := item[v.hashKey]; !ok {
+			continue
+		}
+		if _, ok := item[v.rangeKey]; v.rangeKey != "" && !ok {
+			continue
+		}
+		items = append(items, item)
+	}
+	slices.SortFunc(items, v.compare)
+	return items
+}
+
+// key returns the key of an item in the view, which is used as the
+// LastEvaluatedKey of a page: the table's keys plus the index's keys.
+func (v view) key(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	key := v.table.keyAttributes(item)
+	if v.index != nil {
+		key[v.hashKey] = cloneValue(item[v.hashKey])
+		if v.rangeKey != "" {
+			key[v.rangeKey] = cloneValue(item[v.rangeKey])
+		}
+	}
+	return key
+}
+
+// projected returns the attributes of an item that are projected into the
+// view.
+func (v view) projected(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if v.index == nil {
+		return item
+	}
+	switch v.index.projection.ProjectionType {
+	case types.ProjectionTypeKeysOnly:
+		return v.key(item)
+	case types.ProjectionTypeInclude:
+		projected := v.key(item)
+		for _, name := range v.index.projection.NonKeyAttributes {
+			if value, ok := item[name]; ok {
+				projected[name] = value
+			}
+		}
+		return projected
+	default:
+		return item
+	}
+}
+
+// checkKeyCondition returns an error if the key condition of a query does not
+// test the view's hash key for equality, or tests anything other than its
+// range key.
+func (v view) checkKeyCondition(cond condition) error {
+	hash, rng := false, false
+	for _, part := range flattenAnd(cond) {
+		switch c := part.(type) {
+		case comparison:
+			if c.left.path == "" || c.left.size || c.right.value == nil {
+				return validationError("invalid KeyConditionExpression: a key condition must compare a key attribute with a value")
+			}
+			switch {
+			case c.left.path == v.hashKey && c.comparator == "=" && !hash:
+				hash = true
+				continue
+			case c.left.path == v.rangeKey && c.comparator != "<>" && !rng:
+				rng = true
+				continue
+			}
+		case between:
+			if c.operand.path == v.rangeKey && !c.operand.size && c.low.value != nil && c.high.value != nil && !rng {
+				rng = true
+				continue
+			}
+		case function:
+			if c.name == "begins_with" && c.path == v.rangeKey && c.arg.value != nil && !rng {
+				rng = true
+				continue
+			}
+		}
+		return validationError("invalid KeyConditionExpression: unsupported key condition on the key schema")
+	}
+	if !hash {
+		return validationError("query condition missed key schema element: %s", v.hashKey)
+	}
+	return nil
+}
+
+// flattenAnd returns the conditions joined by AND at the top of a condition.
+func flattenAnd(cond condition) []condition {
+	if c, ok := cond.(logical); ok && c.and {
+		return append(flattenAnd(c.left), flattenAnd(c.right)...)
+	}
+	return []condition{cond}
+}
+
+// page is a page of items read by Query or Scan.
+type page struct {
+	items   []map[string]types.AttributeValue
+	count   int32
+	scanned int32
+	lastKey map[string]types.AttributeValue
+}
+
+// read reads a page from items, which are in the order they are read. Reading
+// starts after the exclusive start key and stops after limit items have been
+// read, before they are filtered, as DynamoDB does.
+func (v view) read(
+	items []map[string]types.AttributeValue,
+	startKey map[string]types.AttributeValue,
+	limit *int32,
+	forward bool,
+	filter condition,
+	projection []string,
+	countOnly bool,
+) (page, error) {
+	if limit != nil && *limit <= 0 {
+		return page{}, validationError("Limit must be greater than 0")
+	}
+
+	start := 0
+	if startKey != nil {
+		for _, name := range v.order() {
+			if _, ok := startKey[name]; !ok {
+				return page{}, validationError("the provided starting key is invalid: missing %s", name)
+			}
+		}
+		for start < len(items) {
+			c := v.compare(items[start], startKey)
+			if forward && c > 0 || !forward && c < 0 {
+				break
+			}
+			start++
+		}
+	}
+
+	var p page
+	for i := start; i < len(items); i++ {
+		item := items[i]
+		p.scanned++
+		if filter == nil || filter.eval(item) {
+			p.count++
+			if !countOnly {
+				p.items = append(p.items, project(v.projected(item), projection))
+			}
+		}
+		if limit != nil && p.scanned == *limit && i+1 < len(items) {
+			p.lastKey = v.key(item)
+			break
+		}
+	}
+	if !countOnly && p.items == nil {
+		p.items = []map[string]types.AttributeValue{}
+	}
+	return p, nil
+}
+
+// parseOptionalProjection parses a projection expression, which is nil if the
+// request has none.
+func parseOptionalProjection(expression *string, params *params) ([]string, error) {
+	if expression == nil {
+		return nil, nil
+	}
+	projection, err := parseProjection(*expression, params)
+	if err != nil {
+		return nil, validationError("invalid ProjectionExpression: %s", err)
+	}
+	return projection, nil
+}
+
+// Query returns the items of a table or index that match a key condition,
+// ordered by the range key.
+func (c *Client) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	v, err := t.view(params.IndexName)
+	if err != nil {
+		return nil, err
+	}
+	if params.KeyConditionExpression == nil {
+		return nil, validationError("KeyConditionExpression must be provided")
+	}
+
+	p := newParams(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	keyCond, err := parseCondition(*params.KeyConditionExpression, p)
+	if err != nil {
+		return nil, validationError("invalid KeyConditionExpression: %s", err)
+	}
+	if err = v.checkKeyCondition(keyCond); err != nil {
+		return nil, err
+	}
+	filter, err := parseOptionalCondition("FilterExpression", params.FilterExpression, p)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := parseOptionalProjection(params.ProjectionExpression, p)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.checkUnused(); err != nil {
+		return nil, validationError("%s", err)
+	}
+
+	var matched []map[string]types.AttributeValue
+	for _, item := range v.items() {
+		if keyCond.eval(item) {
+			matched = append(matched, item)
+		}
+	}
+	forward := params.ScanIndexForward == nil || *params.ScanIndexForward
+	if !forward {
+		slices.Reverse(matched)
+	}
+
+	result, err := v.read(matched, params.ExclusiveStartKey, params.Limit, forward, filter, projection, params.Select == types.SelectCount)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.QueryOutput{
+		Items:            result.items,
+		Count:            result.count,
+		ScannedCount:     result.scanned,
+		LastEvaluatedKey: result.lastKey,
+	}, nil
+}
+
+// Scan returns every item of a table or index that matches the filter. Items
+// are returned in key order, and parallel scans split the items by a hash of
+// their primary key.
+func (c *Client) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	v, err := t.view(params.IndexName)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newParams(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	filter, err := parseOptionalCondition("FilterExpression", params.FilterExpression, p)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := parseOptionalProjection(params.ProjectionExpression, p)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.checkUnused(); err != nil {
+		return nil, validationError("%s", err)
+	}
+
+	items := v.items()
+	if params.TotalSegments != nil {
+		total := *params.TotalSegments
+		var segment int32
+		if params.Segment != nil {
+			segment = *params.Segment
+		}
+		if total <= 0 || segment < 0 || segment >= total {
+			return nil, validationError("Segment must be at least 0 and less than TotalSegments")
+		}
+		items = slices.DeleteFunc(items, func(item map[string]types.AttributeValue) bool {
+			key, _ := t.primaryKey(item, false)
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(key))
+			return h.Sum32()%uint32(total) != uint32(segment)
+		})
+	}
+
+	result, err := v.read(items, params.ExclusiveStartKey, params.Limit, true, filter, projection, params.Select == types.SelectCount)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.ScanOutput{
+		Items:            result.items,
+		Count:            result.count,
+		ScannedCount:     result.scanned,
+		LastEvaluatedKey: result.lastKey,
+	}, nil
+}
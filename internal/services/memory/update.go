@@ -0,0 +1,309 @@
+package memory
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// updateClauses are the clauses an update expression may contain.
+var updateClauses = []string{"SET", "REMOVE", "ADD", "DELETE"}
+
+// updateAction is a single action of an update expression, such as one
+// assignment of a SET clause.
+type updateAction struct {
+	clause string
+	path   string
+
+	// value is the new value of a SET action.
+	value *updateValue
+
+	// operand is the value added or deleted by an ADD or DELETE action.
+	operand types.AttributeValue
+}
+
+// updateValue is the right-hand side of a SET action: an operand, the sum or
+// difference of two operands, or a call of if_not_exists or list_append.
+type updateValue struct {
+	fn          string
+	operand     operand
+	left, right *updateValue
+}
+
+// evaluate returns the value for the provided item, before any action of the
+// update has been applied.
+func (v *updateValue) evaluate(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+	switch v.fn {
+	case "":
+		value, ok := v.operand.resolve(item)
+		if !ok {
+			return nil, fmt.Errorf("the provided expression refers to an attribute that does not exist in the item: %s", v.operand.path)
+		}
+		return value, nil
+	case "if_not_exists":
+		if value, ok := item[v.operand.path]; ok {
+			return value, nil
+		}
+		return v.right.evaluate(item)
+	}
+
+	left, err := v.left.evaluate(item)
+	if err != nil {
+		return nil, err
+	}
+	right, err := v.right.evaluate(item)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.fn == "list_append" {
+		l, okLeft := left.(*types.AttributeValueMemberL)
+		r, okRight := right.(*types.AttributeValueMemberL)
+		if !okLeft || !okRight {
+			return nil, fmt.Errorf("an operand in the update expression has an incorrect data type: list_append requires lists")
+		}
+		return &types.AttributeValueMemberL{Value: append(slices.Clone(l.Value), r.Value...)}, nil
+	}
+
+	x, okLeft := left.(*types.AttributeValueMemberN)
+	y, okRight := right.(*types.AttributeValueMemberN)
+	if !okLeft || !okRight {
+		return nil, fmt.Errorf("an operand in the update expression has an incorrect data type: %s requires numbers", v.fn)
+	}
+	return addNumbers(x.Value, y.Value, v.fn == "-")
+}
+
+// addNumbers returns the sum of two numbers, or their difference if subtract
+// is true.
+func addNumbers(a, b string, subtract bool) (types.AttributeValue, error) {
+	x, err := parseNumber(a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := parseNumber(b)
+	if err != nil {
+		return nil, err
+	}
+	if subtract {
+		x.Sub(x, y)
+	} else {
+		x.Add(x, y)
+	}
+	return &types.AttributeValueMemberN{Value: formatNumber(x)}, nil
+}
+
+// parseUpdate parses an update expression into its actions. Each attribute may
+// only be updated by one action.
+func parseUpdate(expression string, params *params) ([]updateAction, error) {
+	p, err := newParser(expression, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []updateAction
+	clauses := make(map[string]bool)
+	paths := make(map[string]bool)
+	for p.peek().kind != tokenEOF {
+		t := p.peek()
+		clause := strings.ToUpper(t.text)
+		if t.kind != tokenIdent || !slices.Contains(updateClauses, clause) {
+			return nil, p.unexpected()
+		}
+		p.next()
+		if clauses[clause] {
+			return nil, fmt.Errorf("the %s section can only be used once in an update expression", clause)
+		}
+		clauses[clause] = true
+
+		for {
+			path, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			if paths[path] {
+				return nil, fmt.Errorf("two document paths overlap with each other: %s", path)
+			}
+			paths[path] = true
+
+			action := updateAction{clause: clause, path: path}
+			switch clause {
+			case "SET":
+				if err = p.expect("="); err != nil {
+					return nil, err
+				}
+				if action.value, err = p.parseSetValue(); err != nil {
+					return nil, err
+				}
+			case "ADD", "DELETE":
+				t := p.peek()
+				if t.kind != tokenValue {
+					return nil, p.unexpected()
+				}
+				p.next()
+				if action.operand, err = p.params.value(t.text); err != nil {
+					return nil, err
+				}
+			}
+			actions = append(actions, action)
+
+			if !p.isSymbol(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	return actions, nil
+}
+
+// parseSetValue parses the value of a SET action.
+func (p *parser) parseSetValue() (*updateValue, error) {
+	left, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isSymbol("+") && !p.isSymbol("-") {
+		return left, nil
+	}
+	fn := p.next().text
+	right, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+	return &updateValue{fn: fn, left: left, right: right}, nil
+}
+
+// parseSetOperand parses an operand of a SET value, which may be a call of
+// if_not_exists or list_append.
+func (p *parser) parseSetOperand() (*updateValue, error) {
+	switch {
+	case p.isFunction("if_not_exists"):
+		p.next()
+		p.next()
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(","); err != nil {
+			return nil, err
+		}
+		fallback, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &updateValue{fn: "if_not_exists", operand: operand{path: path}, right: fallback}, p.expect(")")
+	case p.isFunction("list_append"):
+		p.next()
+		p.next()
+		left, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(","); err != nil {
+			return nil, err
+		}
+		right, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &updateValue{fn: "list_append", left: left, right: right}, p.expect(")")
+	}
+
+	o, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if o.size {
+		return nil, fmt.Errorf("invalid update expression: the function size is not allowed in an update expression")
+	}
+	return &updateValue{operand: o}, nil
+}
+
+// applyUpdate applies the actions of an update expression to the item in
+// place. Every value is computed from the item as it was before the update,
+// as DynamoDB does.
+func applyUpdate(item map[string]types.AttributeValue, actions []updateAction) error {
+	values := make([]types.AttributeValue, len(actions))
+	for i, action := range actions {
+		var err error
+		switch action.clause {
+		case "SET":
+			values[i], err = action.value.evaluate(item)
+		case "ADD":
+			values[i], err = addValue(item[action.path], action.operand)
+		case "DELETE":
+			values[i], err = deleteValue(item[action.path], action.operand)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, action := range actions {
+		if action.clause == "REMOVE" || values[i] == nil {
+			delete(item, action.path)
+			continue
+		}
+		item[action.path] = cloneValue(values[i])
+	}
+	return nil
+}
+
+// addValue returns the result of an ADD action: the sum of two numbers or the
+// union of two sets. A missing attribute takes the added value.
+func addValue(current, operand types.AttributeValue) (types.AttributeValue, error) {
+	if current == nil {
+		switch operand.(type) {
+		case *types.AttributeValueMemberN, *types.AttributeValueMemberSS,
+			*types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+			return operand, nil
+		}
+		return nil, fmt.Errorf("an operand in the update expression has an incorrect data type: ADD requires a number or a set")
+	}
+
+	if typeOf(current) != typeOf(operand) {
+		return nil, fmt.Errorf("an operand in the update expression has an incorrect data type: cannot ADD %s to %s", typeOf(operand), typeOf(current))
+	}
+	switch c := current.(type) {
+	case *types.AttributeValueMemberN:
+		return addNumbers(c.Value, operand.(*types.AttributeValueMemberN).Value, false)
+	case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+		elements := setElements(c)
+		for _, element := range setElements(operand) {
+			if !containsValue(elements, element) {
+				elements = append(elements, element)
+			}
+		}
+		return newSet(typeOf(c), elements), nil
+	}
+	return nil, fmt.Errorf("an operand in the update expression has an incorrect data type: ADD requires a number or a set")
+}
+
+// deleteValue returns the result of a DELETE action: the difference of two
+// sets, or nil if no elements remain and the attribute should be removed.
+func deleteValue(current, operand types.AttributeValue) (types.AttributeValue, error) {
+	switch operand.(type) {
+	case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+	default:
+		return nil, fmt.Errorf("an operand in the update expression has an incorrect data type: DELETE requires a set")
+	}
+	if current == nil {
+		return nil, nil
+	}
+	if typeOf(current) != typeOf(operand) {
+		return nil, fmt.Errorf("an operand in the update expression has an incorrect data type: cannot DELETE %s from %s", typeOf(operand), typeOf(current))
+	}
+
+	removed := setElements(operand)
+	var elements []types.AttributeValue
+	for _, element := range setElements(current) {
+		if !containsValue(removed, element) {
+			elements = append(elements, element)
+		}
+	}
+	if len(elements) == 0 {
+		return nil, nil
+	}
+	return newSet(typeOf(current), elements), nil
+}
@@ -0,0 +1,308 @@
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// cloneItem returns a deep copy of the provided item, so that callers never
+// share values with the items held by the client.
+func cloneItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if item == nil {
+		return nil
+	}
+	clone := make(map[string]types.AttributeValue, len(item))
+	for name, value := range item {
+		clone[name] = cloneValue(value)
+	}
+	return clone
+}
+
+// cloneValue returns a deep copy of the provided attribute value.
+func cloneValue(value types.AttributeValue) types.AttributeValue {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: v.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: v.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: bytes.Clone(v.Value)}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: v.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: v.Value}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: slices.Clone(v.Value)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: slices.Clone(v.Value)}
+	case *types.AttributeValueMemberBS:
+		clone := make([][]byte, len(v.Value))
+		for i, b := range v.Value {
+			clone[i] = bytes.Clone(b)
+		}
+		return &types.AttributeValueMemberBS{Value: clone}
+	case *types.AttributeValueMemberL:
+		clone := make([]types.AttributeValue, len(v.Value))
+		for i, element := range v.Value {
+			clone[i] = cloneValue(element)
+		}
+		return &types.AttributeValueMemberL{Value: clone}
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: cloneItem(v.Value)}
+	default:
+		return value
+	}
+}
+
+// typeOf returns the DynamoDB type descriptor of the provided value, such as
+// "S" or "NS", or an empty string if the value is nil or unknown.
+func typeOf(value types.AttributeValue) string {
+	switch value.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	case *types.AttributeValueMemberL:
+		return "L"
+	case *types.AttributeValueMemberM:
+		return "M"
+	default:
+		return ""
+	}
+}
+
+// parseNumber parses the string form of a DynamoDB number. Numbers are held as
+// rationals so that arithmetic on decimals is exact.
+func parseNumber(s string) (*big.Rat, error) {
+	if strings.Contains(s, "/") {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	n, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	return n, nil
+}
+
+// formatNumber returns the shortest exact decimal form of the provided number.
+// Every number parsed from a decimal string has one.
+func formatNumber(n *big.Rat) string {
+	if n.IsInt() {
+		return n.Num().String()
+	}
+
+	ten := big.NewInt(10)
+	scale := big.NewInt(1)
+	remainder := new(big.Int)
+	for digits := 1; ; digits++ {
+		scale.Mul(scale, ten)
+		if remainder.Mod(scale, n.Denom()).Sign() == 0 {
+			return n.FloatString(digits)
+		}
+	}
+}
+
+// compareValues orders two scalar values of the same type: strings and binary
+// values byte by byte and numbers numerically. The boolean result is false if
+// the values cannot be ordered.
+func compareValues(a, b types.AttributeValue) (int, bool) {
+	switch a := a.(type) {
+	case *types.AttributeValueMemberS:
+		if b, ok := b.(*types.AttributeValueMemberS); ok {
+			return strings.Compare(a.Value, b.Value), true
+		}
+	case *types.AttributeValueMemberN:
+		if b, ok := b.(*types.AttributeValueMemberN); ok {
+			x, errX := parseNumber(a.Value)
+			y, errY := parseNumber(b.Value)
+			if errX != nil || errY != nil {
+				return 0, false
+			}
+			return x.Cmp(y), true
+		}
+	case *types.AttributeValueMemberB:
+		if b, ok := b.(*types.AttributeValueMemberB); ok {
+			return bytes.Compare(a.Value, b.Value), true
+		}
+	}
+	return 0, false
+}
+
+// equalValues reports whether two values are equal. Numbers are compared
+// numerically and sets without regard to order.
+func equalValues(a, b types.AttributeValue) bool {
+	if typeOf(a) != typeOf(b) {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *types.AttributeValueMemberS, *types.AttributeValueMemberN, *types.AttributeValueMemberB:
+		c, ok := compareValues(a, b)
+		return ok && c == 0
+	case *types.AttributeValueMemberBOOL:
+		return a.Value == b.(*types.AttributeValueMemberBOOL).Value
+	case *types.AttributeValueMemberNULL:
+		return a.Value == b.(*types.AttributeValueMemberNULL).Value
+	case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+		x, y := setElements(a), setElements(b)
+		if len(x) != len(y) {
+			return false
+		}
+		for _, element := range x {
+			if !containsValue(y, element) {
+				return false
+			}
+		}
+		return true
+	case *types.AttributeValueMemberL:
+		x, y := a.Value, b.(*types.AttributeValueMemberL).Value
+		return slices.EqualFunc(x, y, equalValues)
+	case *types.AttributeValueMemberM:
+		x, y := a.Value, b.(*types.AttributeValueMemberM).Value
+		if len(x) != len(y) {
+			return false
+		}
+		for name, value := range x {
+			other, ok := y[name]
+			if !ok || !equalValues(value, other) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// setElements returns the elements of a string, number or binary set as
+// scalar values, or nil if the value is not a set.
+func setElements(value types.AttributeValue) []types.AttributeValue {
+	var elements []types.AttributeValue
+	switch v := value.(type) {
+	case *types.AttributeValueMemberSS:
+		for _, s := range v.Value {
+			elements = append(elements, &types.AttributeValueMemberS{Value: s})
+		}
+	case *types.AttributeValueMemberNS:
+		for _, n := range v.Value {
+			elements = append(elements, &types.AttributeValueMemberN{Value: n})
+		}
+	case *types.AttributeValueMemberBS:
+		for _, b := range v.Value {
+			elements = append(elements, &types.AttributeValueMemberB{Value: b})
+		}
+	}
+	return elements
+}
+
+// newSet returns a set of the provided set type ("SS", "NS" or "BS") holding
+// the provided scalar elements.
+func newSet(setType string, elements []types.AttributeValue) types.AttributeValue {
+	switch setType {
+	case "SS":
+		set := &types.AttributeValueMemberSS{}
+		for _, element := range elements {
+			set.Value = append(set.Value, element.(*types.AttributeValueMemberS).Value)
+		}
+		return set
+	case "NS":
+		set := &types.AttributeValueMemberNS{}
+		for _, element := range elements {
+			set.Value = append(set.Value, element.(*types.AttributeValueMemberN).Value)
+		}
+		return set
+	default:
+		set := &types.AttributeValueMemberBS{}
+		for _, element := range elements {
+			set.Value = append(set.Value, element.(*types.AttributeValueMemberB).Value)
+		}
+		return set
+	}
+}
+
+// containsValue reports whether values holds a value equal to the provided
+// one.
+func containsValue(values []types.AttributeValue, value types.AttributeValue) bool {
+	return slices.ContainsFunc(values, func(v types.AttributeValue) bool {
+		return equalValues(v, value)
+	})
+}
+
+// keyString encodes a key attribute value as a string that is equal for equal
+// values, so it can be used to index items.
+func keyString(value types.AttributeValue) string {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return fmt.Sprintf("S%d:%s", len(v.Value), v.Value)
+	case *types.AttributeValueMemberN:
+		if n, err := parseNumber(v.Value); err == nil {
+			s := formatNumber(n)
+			return fmt.Sprintf("N%d:%s", len(s), s)
+		}
+		return "N:" + v.Value
+	case *types.AttributeValueMemberB:
+		return fmt.Sprintf("B%d:%s", len(v.Value), v.Value)
+	default:
+		return ""
+	}
+}
+
+// validateValue returns an error if the provided value could not be stored by
+// DynamoDB, for example an invalid number or an empty set.
+func validateValue(name string, value types.AttributeValue) error {
+	switch v := value.(type) {
+	case nil:
+		return fmt.Errorf("attribute %s has no value", name)
+	case *types.AttributeValueMemberN:
+		if _, err := parseNumber(v.Value); err != nil {
+			return fmt.Errorf("attribute %s: %w", name, err)
+		}
+	case *types.AttributeValueMemberNS:
+		if len(v.Value) == 0 {
+			return fmt.Errorf("attribute %s: a number set may not be empty", name)
+		}
+		for _, n := range v.Value {
+			if _, err := parseNumber(n); err != nil {
+				return fmt.Errorf("attribute %s: %w", name, err)
+			}
+		}
+	case *types.AttributeValueMemberSS:
+		if len(v.Value) == 0 {
+			return fmt.Errorf("attribute %s: a string set may not be empty", name)
+		}
+	case *types.AttributeValueMemberBS:
+		if len(v.Value) == 0 {
+			return fmt.Errorf("attribute %s: a binary set may not be empty", name)
+		}
+	case *types.AttributeValueMemberL:
+		for _, element := range v.Value {
+			if err := validateValue(name, element); err != nil {
+				return err
+			}
+		}
+	case *types.AttributeValueMemberM:
+		for key, element := range v.Value {
+			if err := validateValue(name+"."+key, element); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,543 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+const (
+	// maxBatchWriteItems is the maximum number of requests in a
+	// BatchWriteItem call.
+	maxBatchWriteItems = 25
+
+	// maxTransactItems is the maximum number of actions in a
+	// TransactWriteItems call.
+	maxTransactItems = 100
+)
+
+// errConditionFailed is returned when the condition expression of a write is
+// not met by the current item.
+var errConditionFailed = errors.New("the conditional request failed")
+
+// mutation is a checked write to a single item that has not been applied yet.
+type mutation struct {
+	table *table
+	key   string
+	old   map[string]types.AttributeValue
+
+	// new is the item after the write, or nil if it deletes the item.
+	new map[string]types.AttributeValue
+
+	// write is false for a condition check, which leaves the item as it is.
+	write bool
+
+	// updated names the attributes changed by an update.
+	updated []string
+}
+
+// apply writes the mutation to its table.
+func (m mutation) apply() {
+	switch {
+	case !m.write:
+	case m.new == nil:
+		delete(m.table.items, m.key)
+	default:
+		m.table.items[m.key] = m.new
+	}
+}
+
+// conditionalCheckFailed returns the error DynamoDB returns when the condition
+// of a single write fails, holding the current item if it was asked for.
+func conditionalCheckFailed(old map[string]types.AttributeValue, returnValues types.ReturnValuesOnConditionCheckFailure) error {
+	err := &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	if returnValues == types.ReturnValuesOnConditionCheckFailureAllOld {
+		err.Item = cloneItem(old)
+	}
+	return err
+}
+
+// parseOptionalCondition parses a condition expression, which is nil if the
+// request has none.
+func parseOptionalCondition(kind string, expression *string, params *params) (condition, error) {
+	if expression == nil {
+		return nil, nil
+	}
+	cond, err := parseCondition(*expression, params)
+	if err != nil {
+		return nil, validationError("invalid %s: %s", kind, err)
+	}
+	return cond, nil
+}
+
+// checkCondition returns errConditionFailed if the condition is not met by the
+// item, which is nil if it does not exist.
+func checkCondition(cond condition, item map[string]types.AttributeValue) error {
+	if cond != nil && !cond.eval(item) {
+		return errConditionFailed
+	}
+	return nil
+}
+
+// preparePut checks a put of the provided item. The mutation is returned with
+// errConditionFailed if the condition is not met.
+func (c *Client) preparePut(
+	tableName *string,
+	item map[string]types.AttributeValue,
+	conditionExpression *string,
+	names map[string]string,
+	values map[string]types.AttributeValue,
+) (mutation, error) {
+	t, err := c.table(tableName)
+	if err != nil {
+		return mutation{}, err
+	}
+	key, err := t.checkItem(item)
+	if err != nil {
+		return mutation{}, err
+	}
+
+	p := newParams(names, values)
+	cond, err := parseOptionalCondition("ConditionExpression", conditionExpression, p)
+	if err != nil {
+		return mutation{}, err
+	}
+	if err = p.checkUnused(); err != nil {
+		return mutation{}, validationError("%s", err)
+	}
+
+	m := mutation{table: t, key: key, old: t.items[key], new: cloneItem(item), write: true}
+	return m, checkCondition(cond, m.old)
+}
+
+// prepareDelete checks a delete of the item with the provided key. The
+// mutation is returned with errConditionFailed if the condition is not met.
+func (c *Client) prepareDelete(
+	tableName *string,
+	key map[string]types.AttributeValue,
+	conditionExpression *string,
+	names map[string]string,
+	values map[string]types.AttributeValue,
+) (mutation, error) {
+	t, err := c.table(tableName)
+	if err != nil {
+		return mutation{}, err
+	}
+	encoded, err := t.primaryKey(key, true)
+	if err != nil {
+		return mutation{}, err
+	}
+
+	p := newParams(names, values)
+	cond, err := parseOptionalCondition("ConditionExpression", conditionExpression, p)
+	if err != nil {
+		return mutation{}, err
+	}
+	if err = p.checkUnused(); err != nil {
+		return mutation{}, validationError("%s", err)
+	}
+
+	m := mutation{table: t, key: encoded, old: t.items[encoded], write: true}
+	return m, checkCondition(cond, m.old)
+}
+
+// prepareUpdate checks an update of the item with the provided key, creating
+// it if it does not exist. The mutation is returned with errConditionFailed if
+// the condition is not met.
+func (c *Client) prepareUpdate(
+	tableName *string,
+	key map[string]types.AttributeValue,
+	updateExpression *string,
+	conditionExpression *string,
+	names map[string]string,
+	values map[string]types.AttributeValue,
+) (mutation, error) {
+	t, err := c.table(tableName)
+	if err != nil {
+		return mutation{}, err
+	}
+	encoded, err := t.primaryKey(key, true)
+	if err != nil {
+		return mutation{}, err
+	}
+
+	p := newParams(names, values)
+	var actions []updateAction
+	if updateExpression != nil {
+		if actions, err = parseUpdate(*updateExpression, p); err != nil {
+			return mutation{}, validationError("invalid UpdateExpression: %s", err)
+		}
+	}
+	cond, err := parseOptionalCondition("ConditionExpression", conditionExpression, p)
+	if err != nil {
+		return mutation{}, err
+	}
+	if err = p.checkUnused(); err != nil {
+		return mutation{}, validationError("%s", err)
+	}
+
+	m := mutation{table: t, key: encoded, old: t.items[encoded], write: true}
+	for _, action := range actions {
+		if action.path == t.hashKey || action.path == t.rangeKey {
+			return mutation{}, validationError("cannot update attribute %s: this attribute is part of the key", action.path)
+		}
+		m.updated = append(m.updated, action.path)
+	}
+	if err = checkCondition(cond, m.old); err != nil {
+		return m, err
+	}
+
+	m.new = cloneItem(m.old)
+	if m.new == nil {
+		m.new = cloneItem(key)
+	}
+	if err = applyUpdate(m.new, actions); err != nil {
+		return mutation{}, validationError("invalid UpdateExpression: %s", err)
+	}
+	if _, err = t.checkItem(m.new); err != nil {
+		return mutation{}, err
+	}
+	return m, nil
+}
+
+// prepareConditionCheck checks the condition of a transaction's condition
+// check. The mutation is returned with errConditionFailed if the condition is
+// not met.
+func (c *Client) prepareConditionCheck(
+	tableName *string,
+	key map[string]types.AttributeValue,
+	conditionExpression *string,
+	names map[string]string,
+	values map[string]types.AttributeValue,
+) (mutation, error) {
+	if conditionExpression == nil {
+		return mutation{}, validationError("a ConditionCheck must have a ConditionExpression")
+	}
+	t, err := c.table(tableName)
+	if err != nil {
+		return mutation{}, err
+	}
+	encoded, err := t.primaryKey(key, true)
+	if err != nil {
+		return mutation{}, err
+	}
+
+	p := newParams(names, values)
+	cond, err := parseOptionalCondition("ConditionExpression", conditionExpression, p)
+	if err != nil {
+		return mutation{}, err
+	}
+	if err = p.checkUnused(); err != nil {
+		return mutation{}, validationError("%s", err)
+	}
+
+	m := mutation{table: t, key: encoded, old: t.items[encoded]}
+	return m, checkCondition(cond, m.old)
+}
+
+// GetItem returns the item with the provided key, or an output without an
+// item if it does not exist.
+func (c *Client) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.primaryKey(params.Key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newParams(params.ExpressionAttributeNames, nil)
+	var projection []string
+	if params.ProjectionExpression != nil {
+		if projection, err = parseProjection(*params.ProjectionExpression, p); err != nil {
+			return nil, validationError("invalid ProjectionExpression: %s", err)
+		}
+	}
+	if err = p.checkUnused(); err != nil {
+		return nil, validationError("%s", err)
+	}
+
+	item, ok := t.items[key]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	return &dynamodb.GetItemOutput{Item: project(item, projection)}, nil
+}
+
+// PutItem creates or replaces an item.
+func (c *Client) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkReturnValues(params.ReturnValues, types.ReturnValueNone, types.ReturnValueAllOld); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, err := c.preparePut(
+		params.TableName,
+		params.Item,
+		params.ConditionExpression,
+		params.ExpressionAttributeNames,
+		params.ExpressionAttributeValues,
+	)
+	if errors.Is(err, errConditionFailed) {
+		return nil, conditionalCheckFailed(m.old, params.ReturnValuesOnConditionCheckFailure)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.apply()
+
+	output := &dynamodb.PutItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		output.Attributes = cloneItem(m.old)
+	}
+	return output, nil
+}
+
+// DeleteItem deletes the item with the provided key. Deleting an item that
+// does not exist succeeds unless the condition expression requires it.
+func (c *Client) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkReturnValues(params.ReturnValues, types.ReturnValueNone, types.ReturnValueAllOld); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, err := c.prepareDelete(
+		params.TableName,
+		params.Key,
+		params.ConditionExpression,
+		params.ExpressionAttributeNames,
+		params.ExpressionAttributeValues,
+	)
+	if errors.Is(err, errConditionFailed) {
+		return nil, conditionalCheckFailed(m.old, params.ReturnValuesOnConditionCheckFailure)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.apply()
+
+	output := &dynamodb.DeleteItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		output.Attributes = cloneItem(m.old)
+	}
+	return output, nil
+}
+
+// UpdateItem applies an update expression to the item with the provided key,
+// creating the item if it does not exist.
+func (c *Client) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, err := c.prepareUpdate(
+		params.TableName,
+		params.Key,
+		params.UpdateExpression,
+		params.ConditionExpression,
+		params.ExpressionAttributeNames,
+		params.ExpressionAttributeValues,
+	)
+	if errors.Is(err, errConditionFailed) {
+		return nil, conditionalCheckFailed(m.old, params.ReturnValuesOnConditionCheckFailure)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.apply()
+
+	output := &dynamodb.UpdateItemOutput{}
+	switch params.ReturnValues {
+	case types.ReturnValueAllOld:
+		output.Attributes = cloneItem(m.old)
+	case types.ReturnValueAllNew:
+		output.Attributes = cloneItem(m.new)
+	case types.ReturnValueUpdatedOld:
+		output.Attributes = project(m.old, m.updated)
+	case types.ReturnValueUpdatedNew:
+		output.Attributes = project(m.new, m.updated)
+	}
+	return output, nil
+}
+
+// checkReturnValues returns an error if the requested return values are not
+// one of the allowed values. An empty value is always allowed.
+func checkReturnValues(returnValues types.ReturnValue, allowed ...types.ReturnValue) error {
+	if returnValues == "" {
+		return nil
+	}
+	for _, value := range allowed {
+		if returnValues == value {
+			return nil
+		}
+	}
+	return validationError("ReturnValues can only be %s", joinReturnValues(allowed))
+}
+
+// joinReturnValues lists return values for an error message.
+func joinReturnValues(values []types.ReturnValue) string {
+	s := make([]string, len(values))
+	for i, value := range values {
+		s[i] = string(value)
+	}
+	return strings.Join(s, " or ")
+}
+
+// BatchWriteItem puts and deletes up to 25 items. Every item is always
+// processed, so UnprocessedItems is always empty.
+func (c *Client) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := 0
+	for _, requests := range params.RequestItems {
+		count += len(requests)
+	}
+	if count == 0 || count > maxBatchWriteItems {
+		return nil, validationError("a batch must contain between 1 and %d write requests, got %d", maxBatchWriteItems, count)
+	}
+
+	mutations := make([]mutation, 0, count)
+	seen := make(map[string]bool, count)
+	for tableName, requests := range params.RequestItems {
+		for _, request := range requests {
+			var m mutation
+			var err error
+			switch {
+			case request.PutRequest != nil:
+				m, err = c.preparePut(aws.String(tableName), request.PutRequest.Item, nil, nil, nil)
+			case request.DeleteRequest != nil:
+				m, err = c.prepareDelete(aws.String(tableName), request.DeleteRequest.Key, nil, nil, nil)
+			default:
+				err = validationError("a write request must have a PutRequest or a DeleteRequest")
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			id := tableName + "/" + m.key
+			if seen[id] {
+				return nil, validationError("provided list of item keys contains duplicates")
+			}
+			seen[id] = true
+			mutations = append(mutations, m)
+		}
+	}
+
+	for _, m := range mutations {
+		m.apply()
+	}
+	return &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]types.WriteRequest{}}, nil
+}
+
+// TransactWriteItems applies up to 100 puts, updates, deletes and condition
+// checks atomically. If any condition is not met nothing is written, and the
+// returned TransactionCanceledException has a cancellation reason for every
+// action in the order they were sent.
+func (c *Client) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := len(params.TransactItems)
+	if count == 0 || count > maxTransactItems {
+		return nil, validationError("a transaction must contain between 1 and %d actions, got %d", maxTransactItems, count)
+	}
+
+	mutations := make([]mutation, count)
+	reasons := make([]types.CancellationReason, count)
+	codes := make([]string, count)
+	seen := make(map[string]bool, count)
+	cancelled := false
+	for i, action := range params.TransactItems {
+		var m mutation
+		var err error
+		var returnValues types.ReturnValuesOnConditionCheckFailure
+		switch {
+		case action.Put != nil:
+			put := action.Put
+			returnValues = put.ReturnValuesOnConditionCheckFailure
+			m, err = c.preparePut(put.TableName, put.Item, put.ConditionExpression, put.ExpressionAttributeNames, put.ExpressionAttributeValues)
+		case action.Update != nil:
+			update := action.Update
+			returnValues = update.ReturnValuesOnConditionCheckFailure
+			m, err = c.prepareUpdate(update.TableName, update.Key, update.UpdateExpression, update.ConditionExpression, update.ExpressionAttributeNames, update.ExpressionAttributeValues)
+		case action.Delete != nil:
+			del := action.Delete
+			returnValues = del.ReturnValuesOnConditionCheckFailure
+			m, err = c.prepareDelete(del.TableName, del.Key, del.ConditionExpression, del.ExpressionAttributeNames, del.ExpressionAttributeValues)
+		case action.ConditionCheck != nil:
+			check := action.ConditionCheck
+			returnValues = check.ReturnValuesOnConditionCheckFailure
+			m, err = c.prepareConditionCheck(check.TableName, check.Key, check.ConditionExpression, check.ExpressionAttributeNames, check.ExpressionAttributeValues)
+		default:
+			err = validationError("a transaction action must have a Put, Update, Delete or ConditionCheck")
+		}
+
+		switch {
+		case errors.Is(err, errConditionFailed):
+			cancelled = true
+			reasons[i] = types.CancellationReason{
+				Code:    aws.String("ConditionalCheckFailed"),
+				Message: aws.String("The conditional request failed"),
+			}
+			if returnValues == types.ReturnValuesOnConditionCheckFailureAllOld {
+				reasons[i].Item = cloneItem(m.old)
+			}
+		case err != nil:
+			return nil, err
+		default:
+			reasons[i] = types.CancellationReason{Code: aws.String("None")}
+		}
+		codes[i] = aws.StringValue(reasons[i].Code)
+
+		id := m.table.name + "/" + m.key
+		if seen[id] {
+			return nil, validationError("transaction request cannot include multiple operations on one item")
+		}
+		seen[id] = true
+		mutations[i] = m
+	}
+
+	if cancelled {
+		return nil, &types.TransactionCanceledException{
+			Message: aws.String(fmt.Sprintf(
+				"Transaction cancelled, please refer cancellation reasons for specific reasons [%s]",
+				strings.Join(codes, ", "),
+			)),
+			CancellationReasons: reasons,
+		}
+	}
+
+	for _, m := range mutations {
+		m.apply()
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
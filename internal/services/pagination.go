@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// queryPage runs a single page of the provided query. The page's cursor is
+// decoded into the query's ExclusiveStartKey and the LastEvaluatedKey of the
+// result is returned as the cursor of the next page, which is empty when there
+// are no more results. Cursors are bound to the provided scope.
+func queryPage(
+	ctx context.Context,
+	client dynamoClient,
+	cursors *Cursors,
+	scope string,
+	input *dynamodb.QueryInput,
+	page Page,
+) ([]map[string]types.AttributeValue, string, error) {
+	startKey, err := cursors.Decode(scope, page.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	limit := page.limit()
+	input.ExclusiveStartKey = startKey
+	input.Limit = &limit
+	result, err := client.Query(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("query page: %w", err)
+	}
+
+	next, err := cursors.Encode(scope, result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return result.Items, next, nil
+}
+
+// queryAll runs the provided query across every page of results and returns
+// all of the matched items. It is meant for batch jobs that need the complete
+// result set rather than a single page.
+func queryAll(ctx context.Context, client dynamoClient, input *dynamodb.QueryInput) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+	for {
+		result, err := client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("query all: %w", err)
+		}
+		items = append(items, result.Items...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// queryKeys runs the provided query across every page of results and returns
+// the primary keys (PK and SK) of the matched items.
+func queryKeys(ctx context.Context, client dynamoClient, input *dynamodb.QueryInput) ([]map[string]types.AttributeValue, error) {
+	input.ProjectionExpression = aws.String("PK, SK")
+
+	items, err := queryAll(ctx, client, input)
+	if err != nil {
+		return nil, fmt.Errorf("query keys: %w", err)
+	}
+
+	keys := make([]map[string]types.AttributeValue, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, map[string]types.AttributeValue{
+			"PK": item["PK"],
+			"SK": item["SK"],
+		})
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// Patch is a partial update of a single item, keyed by attribute name. Set
+// replaces the value of each attribute and Remove deletes each attribute from
+// the item. It is the storage form of a JSON merge patch.
+type Patch struct {
+	Set    map[string]any
+	Remove []string
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p Patch) IsEmpty() bool {
+	return len(p.Set) == 0 && len(p.Remove) == 0
+}
+
+// touches reports whether the patch sets or removes the named attribute.
+func (p Patch) touches(attribute string) bool {
+	_, ok := p.Set[attribute]
+	return ok || slices.Contains(p.Remove, attribute)
+}
+
+// updateItem returns the UpdateItem input that applies the patch to the item
+// with the provided key in a single request, while cond holds. The item's
+// version is incremented. The updated item is returned as ALL_NEW, and the old
+// item is returned on a failed condition so that classifyConditionFailure can
+// tell a missing item from a stale one.
+func (p Patch) updateItem(table string, key map[string]types.AttributeValue, cond condition) (*dynamodb.UpdateItemInput, error) {
+	update, err := p.updateExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	maps.Copy(update.names, cond.names)
+	maps.Copy(update.values, cond.values)
+
+	return &dynamodb.UpdateItemInput{
+		TableName:                           aws.String(table),
+		Key:                                 key,
+		UpdateExpression:                    update.expression,
+		ConditionExpression:                 cond.expression,
+		ExpressionAttributeNames:            update.names,
+		ExpressionAttributeValues:           update.values,
+		ReturnValues:                        types.ReturnValueAllNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}, nil
+}
+
+// updateExpression returns the SET and REMOVE clauses applying the patch and
+// incrementing the item's version, along with the names and values they refer
+// to. Attributes are visited in sorted order so the expression is stable.
+func (p Patch) updateExpression() (condition, error) {
+	names := map[string]string{"#version": "version"}
+	values := map[string]types.AttributeValue{
+		":zero": &types.AttributeValueMemberN{Value: "0"},
+		":one":  &types.AttributeValueMemberN{Value: "1"},
+	}
+
+	attributes := make([]string, 0, len(p.Set))
+	for attribute := range p.Set {
+		attributes = append(attributes, attribute)
+	}
+	slices.Sort(attributes)
+
+	set := make([]string, 0, len(attributes)+1)
+	for i, attribute := range attributes {
+		value, err := attributevalue.Marshal(p.Set[attribute])
+		if err != nil {
+			return condition{}, fmt.Errorf("marshal %s: %w", attribute, err)
+		}
+
+		name, placeholder := fmt.Sprintf("#s%d", i), fmt.Sprintf(":s%d", i)
+		names[name] = attribute
+		values[placeholder] = value
+		set = append(set, name+" = "+placeholder)
+	}
+	set = append(set, "#version = if_not_exists(#version, :zero) + :one")
+
+	expression := "SET " + strings.Join(set, ", ")
+
+	if len(p.Remove) > 0 {
+		remove := make([]string, 0, len(p.Remove))
+		removed := slices.Clone(p.Remove)
+		slices.Sort(removed)
+		for i, attribute := range removed {
+			name := fmt.Sprintf("#r%d", i)
+			names[name] = attribute
+			remove = append(remove, name)
+		}
+		expression += " REMOVE " + strings.Join(remove, ", ")
+	}
+
+	return condition{
+		expression: aws.String(expression),
+		names:      names,
+		values:     values,
+	}, nil
+}
+
+// storageAttributes are the attributes every entity uses for storage, which a
+// patch must never change.
+var storageAttributes = []string{"PK", "SK", "GSI1PK", "GSI1SK", "version"}
+
+// checkPatchable returns a validation *Error if the patch changes one of the
+// storage attributes or one of the provided immutable attributes of the
+// entity.
+func checkPatchable(op, entity string, patch Patch, immutable ...string) error {
+	if patch.IsEmpty() {
+		return &Error{Kind: KindValidation, Op: op, Entity: entity, Err: ErrValidation}
+	}
+	for _, attribute := range append(slices.Clone(storageAttributes), immutable...) {
+		if patch.touches(attribute) {
+			return &Error{
+				Kind:   KindValidation,
+				Op:     op,
+				Entity: entity,
+				Err:    fmt.Errorf("%w: %s cannot be patched", ErrValidation, attribute),
+			}
+		}
+	}
+	return nil
+}
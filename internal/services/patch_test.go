@@ -0,0 +1,97 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatch_UpdateItem(t *testing.T) {
+	key := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "BLOG#17e16813-c203-0355-1e4c-17c630f114f3"},
+		"SK": &types.AttributeValueMemberS{Value: "METADATA"},
+	}
+
+	tests := map[string]struct {
+		patch          Patch
+		expected       int64
+		wantUpdate     string
+		wantCondition  string
+		wantNames      map[string]string
+		wantValueCount int
+	}{
+		"set only": {
+			patch: Patch{Set: map[string]any{"title": "Kitchen Decor Ideas", "score": 7.5}},
+			wantUpdate: "SET #s0 = :s0, #s1 = :s1, " +
+				"#version = if_not_exists(#version, :zero) + :one",
+			wantCondition: "attribute_exists(PK) AND attribute_exists(SK)",
+			wantNames: map[string]string{
+				"#s0":      "score",
+				"#s1":      "title",
+				"#version": "version",
+			},
+			wantValueCount: 4,
+		},
+		"set and remove at a version": {
+			patch:    Patch{Set: map[string]any{"title": "Kitchen Decor Ideas"}, Remove: []string{"score"}},
+			expected: 2,
+			wantUpdate: "SET #s0 = :s0, #version = if_not_exists(#version, :zero) + :one " +
+				"REMOVE #r0",
+			wantCondition: "attribute_exists(PK) AND #version = :version",
+			wantNames: map[string]string{
+				"#s0":      "title",
+				"#r0":      "score",
+				"#version": "version",
+			},
+			wantValueCount: 4,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			input, err := tc.patch.updateItem("BlogContent", key, deleteCondition(tc.expected))
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.wantUpdate, aws.StringValue(input.UpdateExpression), "update expression did not match")
+			assert.Equal(t, tc.wantCondition, aws.StringValue(input.ConditionExpression), "condition did not match")
+			assert.Equal(t, tc.wantNames, input.ExpressionAttributeNames, "names did not match")
+			assert.Len(t, input.ExpressionAttributeValues, tc.wantValueCount, "unexpected number of values")
+			assert.Equal(t, types.ReturnValueAllNew, input.ReturnValues, "updated item is not returned")
+		})
+	}
+}
+
+func TestCheckPatchable(t *testing.T) {
+	tests := map[string]struct {
+		patch   Patch
+		wantErr bool
+	}{
+		"mutable attribute": {
+			patch: Patch{Set: map[string]any{"title": "Kitchen Decor Ideas"}},
+		},
+		"empty patch": {
+			patch:   Patch{},
+			wantErr: true,
+		},
+		"storage attribute": {
+			patch:   Patch{Remove: []string{"GSI1SK"}},
+			wantErr: true,
+		},
+		"immutable attribute": {
+			patch:   Patch{Set: map[string]any{"user_id": "d2eddb69-f92f-694d-450d-e7cdb6decce3"}},
+			wantErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkPatchable("BlogsService.PatchBlog", "blog", tc.patch, "blog_id", "user_id")
+
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrValidation, "error did not match")
+			} else {
+				assert.NoError(t, err, "unexpected error")
+			}
+		})
+	}
+}
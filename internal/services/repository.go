@@ -0,0 +1,289 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/agallagher-captech/blog/internal/keys"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/google/uuid"
+)
+
+// entity constrains the models a Repository stores: P is a pointer to the
+// model T, which declares the keys of its item and stores them when it is
+// marshalled.
+type entity[T any] interface {
+	*T
+	keys.Entity
+}
+
+// Repository reads and writes the items of a single type of entity in the
+// table. It takes care of marshalling, write conditions, versions, pagination,
+// logging and error wrapping, so the services built on it only deal with their
+// domain. Every write increments the version attribute of the item, and
+// errors are *Error values naming the repository method and the entity.
+type Repository[T any, P entity[T]] struct {
+	logger  *slog.Logger
+	client  dynamoClient
+	table   Table
+	cursors *Cursors
+	name    string
+}
+
+// NewRepository creates a new Repository for the entity with the provided
+// name, e.g. "blog", and returns a pointer to it. The name is used in errors,
+// logs and the scope of pagination cursors.
+func NewRepository[T any, P entity[T]](logger *slog.Logger, client dynamoClient, table Table, cursors *Cursors, name string) *Repository[T, P] {
+	return &Repository[T, P]{
+		logger:  logger,
+		client:  client,
+		table:   table,
+		cursors: cursors,
+		name:    name,
+	}
+}
+
+// Get returns the entity stored under the provided key, or ErrNotFound if
+// there is none.
+func (r *Repository[T, P]) Get(ctx context.Context, key keys.Key) (T, error) {
+	r.logger.DebugContext(ctx, "Getting item", r.keyAttrs(key)...)
+
+	var entity T
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.table.Name),
+		Key:       key.AttributeValues(),
+	})
+	if err != nil {
+		return entity, newError("Repository.Get", r.name, fmt.Errorf("failed to get item: %w", err))
+	}
+	if result.Item == nil {
+		return entity, notFound("Repository.Get", r.name)
+	}
+
+	return r.unmarshal("Repository.Get", result.Item)
+}
+
+// Create writes a new entity at version 1 and returns it as it was stored,
+// with its keys and version populated. ErrAlreadyExists is returned if an
+// item with the same key already exists.
+func (r *Repository[T, P]) Create(ctx context.Context, entity T) (T, error) {
+	r.logger.DebugContext(ctx, "Creating item", r.keyAttrs(P(&entity).Keys().Key)...)
+
+	item, _, err := r.marshal("Repository.Create", &entity)
+	if err != nil {
+		return entity, err
+	}
+	item["version"] = versionValue(1)
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.table.Name),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(PK) AND attribute_not_exists(SK)"),
+	})
+	if err != nil {
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return entity, alreadyExists("Repository.Create", r.name)
+		}
+		return entity, newError("Repository.Create", r.name, fmt.Errorf("failed to put item: %w", err))
+	}
+
+	return r.unmarshal("Repository.Create", item)
+}
+
+// Replace writes the entity over its item and returns it as it was stored.
+// The entity must hold the version it was read at: the write only applies
+// while the item is still at that version, and stores the next one. If the
+// caller expected a particular version, a failed write is reported as
+// ErrVersionMismatch, otherwise as a conflict.
+func (r *Repository[T, P]) Replace(ctx context.Context, entity T, expected int64) (T, error) {
+	r.logger.DebugContext(ctx, "Replacing item", r.keyAttrs(P(&entity).Keys().Key)...)
+
+	item, version, err := r.marshal("Repository.Replace", &entity)
+	if err != nil {
+		return entity, err
+	}
+	item["version"] = versionValue(version + 1)
+
+	// Only write if nobody else has written since the entity was read
+	cond := versionCondition(version)
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String(r.table.Name),
+		Item:                      item,
+		ConditionExpression:       cond.expression,
+		ExpressionAttributeNames:  cond.names,
+		ExpressionAttributeValues: cond.values,
+	})
+	if err != nil {
+		return entity, classifyUpdateFailure("Repository.Replace", r.name, expected, err)
+	}
+
+	return r.unmarshal("Repository.Replace", item)
+}
+
+// Update applies the patch to the item with the provided key in a single
+// UpdateItem request and returns the updated entity. If version is non-zero
+// the item must still be at that version, or ErrVersionMismatch is returned.
+// ErrNotFound is returned if the item does not exist. The patch should have
+// been checked with checkPatchable.
+func (r *Repository[T, P]) Update(ctx context.Context, key keys.Key, version int64, patch Patch) (T, error) {
+	return r.update(ctx, "Repository.Update", key, deleteCondition(version), patch)
+}
+
+// UpdateOwned is Update for an item that may only be changed by the user
+// stored in its user_id. ErrForbidden is returned if the item belongs to
+// another user, which is checked by the update itself.
+func (r *Repository[T, P]) UpdateOwned(ctx context.Context, key keys.Key, owner uuid.UUID, version int64, patch Patch) (T, error) {
+	return r.update(ctx, "Repository.UpdateOwned", key, ownedBy(deleteCondition(version), owner), patch)
+}
+
+// update applies the patch to the item with the provided key while cond
+// holds, naming the provided operation in its errors.
+func (r *Repository[T, P]) update(ctx context.Context, op string, key keys.Key, cond condition, patch Patch) (T, error) {
+	r.logger.DebugContext(ctx, "Updating item", r.keyAttrs(key)...)
+
+	var entity T
+	input, err := patch.updateItem(r.table.Name, key.AttributeValues(), cond)
+	if err != nil {
+		return entity, newError(op, r.name, fmt.Errorf("failed to build update: %w", err))
+	}
+
+	// Apply the patch in DynamoDB, which returns the updated item
+	result, err := r.client.UpdateItem(ctx, input)
+	if err != nil {
+		return entity, classifyConditionFailure(op, r.name, "update item", cond, err)
+	}
+
+	return r.unmarshal(op, result.Attributes)
+}
+
+// Delete deletes the item with the provided key. ErrNotFound is returned if
+// the item does not exist. If version is non-zero the item must still be at
+// that version, or ErrVersionMismatch is returned.
+func (r *Repository[T, P]) Delete(ctx context.Context, key keys.Key, version int64) error {
+	r.logger.DebugContext(ctx, "Deleting item", r.keyAttrs(key)...)
+
+	cond := deleteCondition(version)
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                           aws.String(r.table.Name),
+		Key:                                 key.AttributeValues(),
+		ConditionExpression:                 cond.expression,
+		ExpressionAttributeNames:            cond.names,
+		ExpressionAttributeValues:           cond.values,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		return classifyConditionFailure("Repository.Delete", r.name, "delete item", cond, err)
+	}
+
+	return nil
+}
+
+// QueryPartition returns a page of the entities in the partition with the
+// provided key, in sort key order, along with the cursor of the next page. If
+// prefix is not empty only the items whose sort key starts with it are
+// returned. ErrInvalidCursor is returned if the page's cursor is not valid.
+func (r *Repository[T, P]) QueryPartition(ctx context.Context, pk, prefix string, page Page) ([]T, string, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.table.Name),
+		KeyConditionExpression: aws.String("PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: pk},
+		},
+	}
+	if prefix != "" {
+		input.KeyConditionExpression = aws.String("PK = :pk AND begins_with(SK, :sk)")
+		input.ExpressionAttributeValues[":sk"] = &types.AttributeValueMemberS{Value: prefix}
+	}
+
+	return r.Query(ctx, fmt.Sprintf("%s:partition:%s:%s", r.name, pk, prefix), input, page)
+}
+
+// QueryIndex returns a page of the entities in the partition of GSI1 with the
+// provided key, along with the cursor of the next page. If sk is not empty
+// only the items with that GSI1 sort key are returned. ErrInvalidCursor is
+// returned if the page's cursor is not valid.
+func (r *Repository[T, P]) QueryIndex(ctx context.Context, pk, sk string, page Page) ([]T, string, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.table.Name),
+		IndexName:              aws.String(r.table.GSI1),
+		KeyConditionExpression: aws.String("GSI1PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: pk},
+		},
+	}
+	if sk != "" {
+		input.KeyConditionExpression = aws.String("GSI1PK = :pk AND GSI1SK = :sk")
+		input.ExpressionAttributeValues[":sk"] = &types.AttributeValueMemberS{Value: sk}
+	}
+
+	return r.Query(ctx, fmt.Sprintf("%s:index:%s:%s", r.name, pk, sk), input, page)
+}
+
+// Query runs a single page of the provided query, whose table name is set by
+// the repository, and returns the matched entities along with the cursor of
+// the next page. Cursors are bound to the provided scope, which should
+// identify the query. ErrInvalidCursor is returned if the page's cursor is not
+// valid.
+func (r *Repository[T, P]) Query(ctx context.Context, scope string, input *dynamodb.QueryInput, page Page) ([]T, string, error) {
+	r.logger.DebugContext(ctx, "Querying items", slog.String("entity", r.name), slog.String("scope", scope))
+
+	input.TableName = aws.String(r.table.Name)
+	items, next, err := queryPage(ctx, r.client, r.cursors, scope, input, page)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCursor) {
+			return nil, "", ErrInvalidCursor
+		}
+		return nil, "", newError("Repository.Query", r.name, fmt.Errorf("failed to query items: %w", err))
+	}
+
+	var entities []T
+	if err = attributevalue.UnmarshalListOfMaps(items, &entities); err != nil {
+		return nil, "", newError("Repository.Query", r.name, fmt.Errorf("failed to unmarshal result: %w", err))
+	}
+
+	return entities, next, nil
+}
+
+// marshal returns the item of the entity, with its keys populated, along with
+// the version the entity holds.
+func (r *Repository[T, P]) marshal(op string, entity *T) (map[string]types.AttributeValue, int64, error) {
+	item, err := keys.Marshal(P(entity))
+	if err != nil {
+		return nil, 0, newError(op, r.name, fmt.Errorf("failed to marshal %s: %w", r.name, err))
+	}
+
+	var version int64
+	if value, ok := item["version"]; ok {
+		if err = attributevalue.Unmarshal(value, &version); err != nil {
+			return nil, 0, newError(op, r.name, fmt.Errorf("failed to read version: %w", err))
+		}
+	}
+	return item, version, nil
+}
+
+// unmarshal returns the entity stored in the provided item.
+func (r *Repository[T, P]) unmarshal(op string, item map[string]types.AttributeValue) (T, error) {
+	var entity T
+	if err := attributevalue.UnmarshalMap(item, &entity); err != nil {
+		return entity, newError(op, r.name, fmt.Errorf("failed to unmarshal result: %w", err))
+	}
+	return entity, nil
+}
+
+// keyAttrs returns the log attributes identifying the item with the provided
+// key.
+func (r *Repository[T, P]) keyAttrs(key keys.Key) []any {
+	return []any{slog.String("entity", r.name), slog.String("pk", key.PK), slog.String("sk", key.SK)}
+}
+
+// versionValue returns the attribute value of an item version.
+func versionValue(version int64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/agallagher-captech/blog/internal/keys"
+	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/agallagher-captech/blog/internal/services/memory"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBlogRepository(t *testing.T, client dynamoClient) *Repository[models.Blog, *models.Blog] {
+	t.Helper()
+	return NewRepository[models.Blog](slog.Default(), client, DefaultTable(), NewCursors("test-cursor-key"), "blog")
+}
+
+func TestRepository_Lifecycle(t *testing.T) {
+	ctx := context.TODO()
+	client := memory.NewClient()
+	_, err := client.CreateTable(ctx, DefaultTable().Schema())
+	require.NoError(t, err)
+	blogs := newBlogRepository(t, client)
+
+	author := models.UUID{UUID: uuid.New()}
+	blog := models.Blog{ID: models.UUID{UUID: uuid.New()}, UserID: author, Title: "First Post"}
+	key := keys.BlogKey(blog.ID.UUID)
+
+	// Creating populates the keys and the first version
+	created, err := blogs.Create(ctx, blog)
+	require.NoError(t, err)
+	assert.Equal(t, blog.Keys().Key, keys.Key{PK: created.PK, SK: created.SK}, "keys were not populated")
+	assert.Equal(t, keys.User(author.UUID), created.GSI1SK, "index key was not populated")
+	assert.Equal(t, int64(1), created.Version, "created at the wrong version")
+
+	_, err = blogs.Create(ctx, blog)
+	assert.ErrorIs(t, err, ErrAlreadyExists, "duplicate was created")
+
+	read, err := blogs.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, created, read, "read did not match created")
+
+	// Replacing writes the next version, but only over the version read
+	read.Title = "First Post, Edited"
+	replaced, err := blogs.Replace(ctx, read, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), replaced.Version, "version was not incremented")
+
+	_, err = blogs.Replace(ctx, read, read.Version)
+	assert.ErrorIs(t, err, ErrVersionMismatch, "stale replace was written")
+
+	// Updating applies a patch to the current version
+	updated, err := blogs.Update(ctx, key, 2, Patch{Set: map[string]any{"score": 4.5}})
+	require.NoError(t, err)
+	assert.Equal(t, 4.5, updated.Score, "patch was not applied")
+	assert.Equal(t, "First Post, Edited", updated.Title, "patch changed other attributes")
+	assert.Equal(t, int64(3), updated.Version, "version was not incremented")
+
+	_, err = blogs.Update(ctx, keys.BlogKey(uuid.New()), 0, Patch{Set: map[string]any{"score": 1}})
+	assert.ErrorIs(t, err, ErrNotFound, "missing item was updated")
+
+	// Deleting checks the version too
+	assert.ErrorIs(t, blogs.Delete(ctx, key, 2), ErrVersionMismatch, "stale delete was applied")
+	require.NoError(t, blogs.Delete(ctx, key, 3))
+	_, err = blogs.Get(ctx, key)
+	assert.ErrorIs(t, err, ErrNotFound, "item was not deleted")
+	assert.ErrorIs(t, blogs.Delete(ctx, key, 0), ErrNotFound, "missing item was deleted")
+}
+
+func TestRepository_Query(t *testing.T) {
+	ctx := context.TODO()
+	blogs := newBlogRepository(t, newSeededClient(t))
+	comments := NewRepository[models.Comment](slog.Default(), newSeededClient(t), DefaultTable(), NewCursors("test-cursor-key"), "comment")
+
+	// Every blog is listed through the index, page by page
+	var all []models.Blog
+	page := Page{Limit: 3}
+	for {
+		items, next, err := blogs.QueryIndex(ctx, keys.Blogs, "", page)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(items), 3, "page was too long")
+		all = append(all, items...)
+		if next == "" {
+			break
+		}
+		page.Cursor = next
+	}
+	require.NotEmpty(t, all, "no blogs were listed")
+
+	// The blogs of an author are selected by the index sort key
+	author := all[0].UserID.UUID
+	written, _, err := blogs.QueryIndex(ctx, keys.Blogs, keys.User(author), Page{Limit: 100})
+	require.NoError(t, err)
+	require.NotEmpty(t, written)
+	for _, blog := range written {
+		assert.Equal(t, author, blog.UserID.UUID, "blog of another author was listed")
+	}
+
+	// The comments of a blog share its partition with its metadata item
+	blogID := uuid.MustParse("17e16813-c203-0355-1e4c-17c630f114f3")
+	onBlog, _, err := comments.QueryPartition(ctx, keys.Blog(blogID), keys.UserPrefix, Page{Limit: 100})
+	require.NoError(t, err)
+	require.NotEmpty(t, onBlog, "no comments were listed")
+	for _, comment := range onBlog {
+		assert.Equal(t, blogID, comment.BlogID.UUID, "comment of another blog was listed")
+	}
+
+	// A cursor is only valid for the query it was issued by
+	_, next, err := blogs.QueryIndex(ctx, keys.Blogs, "", Page{Limit: 1})
+	require.NoError(t, err)
+	require.NotEmpty(t, next)
+	_, _, err = blogs.QueryIndex(ctx, keys.Blogs, keys.User(author), Page{Limit: 1, Cursor: next})
+	assert.ErrorIs(t, err, ErrInvalidCursor, "cursor of another query was accepted")
+}
@@ -0,0 +1,61 @@
+package retry
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics counts the retries of a Client, labelled by DynamoDB operation. A
+// nil *Metrics counts nothing.
+type Metrics struct {
+	retries   *prometheus.CounterVec
+	throttles *prometheus.CounterVec
+	exhausts  *prometheus.CounterVec
+}
+
+// NewMetrics creates the retry metrics and registers them with the provided
+// registerer.
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dynamodb_retries_total",
+			Help: "DynamoDB requests sent again, by operation and the reason the previous attempt failed.",
+		}, []string{"operation", "reason"}),
+		throttles: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dynamodb_throttles_total",
+			Help: "DynamoDB requests throttled or left partly unprocessed, by operation.",
+		}, []string{"operation"}),
+		exhausts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dynamodb_retries_exhausted_total",
+			Help: "DynamoDB operations that failed after running out of attempts or time to retry, by operation.",
+		}, []string{"operation"}),
+	}
+	for _, collector := range []prometheus.Collector{m.retries, m.throttles, m.exhausts} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("[in retry.NewMetrics] failed to register metric: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// retried counts a retry of the operation for the provided reason.
+func (m *Metrics) retried(op, reason string) {
+	if m != nil {
+		m.retries.WithLabelValues(op, reason).Inc()
+	}
+}
+
+// throttled counts a throttled attempt of the operation.
+func (m *Metrics) throttled(op string) {
+	if m != nil {
+		m.throttles.WithLabelValues(op).Inc()
+	}
+}
+
+// exhausted counts an operation that failed without being retried again.
+func (m *Metrics) exhausted(op string) {
+	if m != nil {
+		m.exhausts.WithLabelValues(op).Inc()
+	}
+}
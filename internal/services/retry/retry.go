@@ -0,0 +1,359 @@
+// Package retry wraps a DynamoDB client so that requests DynamoDB rejected
+// for being throttled are sent again, along with reads and transactions it
+// failed on its side.
+//
+// Requests are retried with exponential backoff and full jitter, up to a
+// maximum number of attempts, and every operation is given a deadline that is
+// never later than the deadline of the request's context. Unprocessed items of
+// a BatchWriteItem call are sent again in the same way. Retries and throttled
+// requests are logged and counted in Metrics.
+//
+// The retries of the AWS SDK should be turned off for a client wrapped by
+// Client, since every SDK attempt would otherwise be retried again.
+package retry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/smithy-go"
+	"github.com/google/uuid"
+)
+
+// API is the part of the DynamoDB API that Client wraps, which is implemented
+// by both the AWS client and the in-memory client.
+type API interface {
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)
+	DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+}
+
+// Policy configures how requests are retried.
+type Policy struct {
+	// MaxAttempts is the number of times a request is sent before its error
+	// is returned. Values below 1 are treated as 1, i.e. no retries.
+	MaxAttempts int
+
+	// BaseDelay is the backoff before the first retry. It doubles after
+	// every attempt, up to MaxDelay, and the delay actually waited is drawn
+	// at random between zero and the backoff.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// Timeout is the deadline of an operation, including all of its
+	// retries. The deadline of the request's context applies if it is
+	// earlier. Zero means the operation only ends with its context.
+	Timeout time.Duration
+}
+
+// backoff returns the delay to wait before the retry following the provided
+// attempt, starting at 1.
+func (p Policy) backoff(attempt int) time.Duration {
+	backoff := p.BaseDelay
+	for i := 1; i < attempt && backoff < p.MaxDelay; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, p.MaxDelay)
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// Reasons a request is retried, which label the retry metrics.
+const (
+	reasonThrottled   = "throttled"
+	reasonUnprocessed = "unprocessed"
+	reasonConflict    = "conflict"
+	reasonServer      = "server"
+)
+
+// Client is a DynamoDB client that retries the requests of the client it wraps
+// according to a Policy.
+type Client struct {
+	api     API
+	policy  Policy
+	logger  *slog.Logger
+	metrics *Metrics
+}
+
+// NewClient wraps the provided client, retrying its requests according to the
+// policy. Retries are logged with the provided logger and counted in metrics,
+// which may be nil.
+func NewClient(api API, policy Policy, logger *slog.Logger, metrics *Metrics) *Client {
+	return &Client{
+		api:     api,
+		policy:  policy,
+		logger:  logger,
+		metrics: metrics,
+	}
+}
+
+// DescribeTable describes a table, retrying as needed.
+func (c *Client) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return do(ctx, c, "DescribeTable", true, func(ctx context.Context) (*dynamodb.DescribeTableOutput, error) {
+		return c.api.DescribeTable(ctx, params, optFns...)
+	})
+}
+
+// CreateTable creates a table, retrying as needed.
+func (c *Client) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	return do(ctx, c, "CreateTable", false, func(ctx context.Context) (*dynamodb.CreateTableOutput, error) {
+		return c.api.CreateTable(ctx, params, optFns...)
+	})
+}
+
+// UpdateTable updates a table, retrying as needed.
+func (c *Client) UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error) {
+	return do(ctx, c, "UpdateTable", false, func(ctx context.Context) (*dynamodb.UpdateTableOutput, error) {
+		return c.api.UpdateTable(ctx, params, optFns...)
+	})
+}
+
+// DeleteTable deletes a table, retrying as needed.
+func (c *Client) DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error) {
+	return do(ctx, c, "DeleteTable", false, func(ctx context.Context) (*dynamodb.DeleteTableOutput, error) {
+		return c.api.DeleteTable(ctx, params, optFns...)
+	})
+}
+
+// GetItem gets an item, retrying as needed.
+func (c *Client) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return do(ctx, c, "GetItem", true, func(ctx context.Context) (*dynamodb.GetItemOutput, error) {
+		return c.api.GetItem(ctx, params, optFns...)
+	})
+}
+
+// PutItem puts an item, retrying as needed.
+func (c *Client) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return do(ctx, c, "PutItem", false, func(ctx context.Context) (*dynamodb.PutItemOutput, error) {
+		return c.api.PutItem(ctx, params, optFns...)
+	})
+}
+
+// Query runs a query, retrying as needed.
+func (c *Client) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	return do(ctx, c, "Query", true, func(ctx context.Context) (*dynamodb.QueryOutput, error) {
+		return c.api.Query(ctx, params, optFns...)
+	})
+}
+
+// DeleteItem deletes an item, retrying as needed.
+func (c *Client) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	return do(ctx, c, "DeleteItem", false, func(ctx context.Context) (*dynamodb.DeleteItemOutput, error) {
+		return c.api.DeleteItem(ctx, params, optFns...)
+	})
+}
+
+// Scan runs a scan, retrying as needed.
+func (c *Client) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return do(ctx, c, "Scan", true, func(ctx context.Context) (*dynamodb.ScanOutput, error) {
+		return c.api.Scan(ctx, params, optFns...)
+	})
+}
+
+// TransactWriteItems writes a transaction, retrying as needed. Transactions
+// cancelled by a failed condition are not retried.
+//
+// Every attempt is sent with the same client request token, so that DynamoDB
+// treats a retry of a transaction it already applied as a success instead of
+// checking its conditions again. This makes transactions safe to retry after
+// a server fault. A token is generated if the caller did not set one.
+func (c *Client) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	if params.ClientRequestToken == nil {
+		input := *params
+		input.ClientRequestToken = aws.String(uuid.NewString())
+		params = &input
+	}
+	return do(ctx, c, "TransactWriteItems", true, func(ctx context.Context) (*dynamodb.TransactWriteItemsOutput, error) {
+		return c.api.TransactWriteItems(ctx, params, optFns...)
+	})
+}
+
+// UpdateItem updates an item, retrying as needed.
+func (c *Client) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return do(ctx, c, "UpdateItem", false, func(ctx context.Context) (*dynamodb.UpdateItemOutput, error) {
+		return c.api.UpdateItem(ctx, params, optFns...)
+	})
+}
+
+// BatchWriteItem writes a batch of items. Failed requests are retried like
+// any other write, and the items DynamoDB leaves unprocessed are sent again with the
+// same backoff. Items still unprocessed once the attempts or the deadline run
+// out are returned in the output, as DynamoDB does.
+func (c *Client) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	ctx, cancel := c.withDeadline(ctx)
+	defer cancel()
+
+	pending := params
+	for attempt := 1; ; attempt++ {
+		result, err := c.api.BatchWriteItem(ctx, pending, optFns...)
+		if err != nil {
+			reason, ok := retryReason(err, false)
+			if !ok || !c.wait(ctx, "BatchWriteItem", attempt, reason, err) {
+				return result, err
+			}
+			continue
+		}
+
+		unprocessed := 0
+		for _, requests := range result.UnprocessedItems {
+			unprocessed += len(requests)
+		}
+		if unprocessed == 0 || !c.wait(ctx, "BatchWriteItem", attempt, reasonUnprocessed, nil, slog.Int("unprocessed", unprocessed)) {
+			return result, nil
+		}
+
+		next := *pending
+		next.RequestItems = result.UnprocessedItems
+		pending = &next
+	}
+}
+
+// do calls the provided operation until it succeeds, fails with an error that
+// is not worth retrying, or runs out of attempts or time. Idempotent
+// operations are also retried when DynamoDB failed on its side.
+func do[T any](ctx context.Context, c *Client, op string, idempotent bool, call func(ctx context.Context) (T, error)) (T, error) {
+	ctx, cancel := c.withDeadline(ctx)
+	defer cancel()
+
+	for attempt := 1; ; attempt++ {
+		result, err := call(ctx)
+		if err == nil {
+			return result, nil
+		}
+
+		reason, ok := retryReason(err, idempotent)
+		if !ok || !c.wait(ctx, op, attempt, reason, err) {
+			return result, err
+		}
+	}
+}
+
+// withDeadline returns a context that ends with the policy's timeout, unless
+// the provided context ends first.
+func (c *Client) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.policy.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.policy.Timeout)
+}
+
+// wait records that the provided attempt of the operation failed for the
+// provided reason and waits out the backoff before the next one. It reports
+// whether the operation should be tried again, which is not the case once the
+// attempts run out or the backoff would outlast the context.
+func (c *Client) wait(ctx context.Context, op string, attempt int, reason string, err error, attrs ...any) bool {
+	attrs = append(attrs,
+		slog.String("operation", op),
+		slog.Int("attempt", attempt),
+		slog.String("reason", reason),
+	)
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	if reason == reasonThrottled || reason == reasonUnprocessed {
+		c.metrics.throttled(op)
+	}
+
+	if attempt >= c.policy.MaxAttempts {
+		c.metrics.exhausted(op)
+		c.logger.WarnContext(ctx, "DynamoDB request failed after all attempts", attrs...)
+		return false
+	}
+
+	delay := c.policy.backoff(attempt)
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		c.metrics.exhausted(op)
+		c.logger.WarnContext(ctx, "DynamoDB request failed before its deadline allowed a retry", attrs...)
+		return false
+	}
+
+	c.metrics.retried(op, reason)
+	c.logger.InfoContext(ctx, "Retrying DynamoDB request", append(attrs, slog.Duration("delay", delay))...)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		c.metrics.exhausted(op)
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// retryReason returns why the request that failed with the provided error is
+// worth sending again, and false if it is not. Requests DynamoDB rejected
+// without applying them, because they were throttled or in conflict with a
+// transaction, are always retried. Requests DynamoDB failed to process on its
+// side may have been applied, so they are only retried if the request is
+// idempotent: a read, or a transaction sent with a client request token. A
+// conditional write would otherwise fail its own condition when retried.
+func retryReason(err error, idempotent bool) (string, bool) {
+	var (
+		throughput *types.ProvisionedThroughputExceededException
+		limit      *types.RequestLimitExceeded
+		txConflict *types.TransactionConflictException
+		tce        *types.TransactionCanceledException
+		internal   *types.InternalServerError
+	)
+	switch {
+	case errors.As(err, &throughput), errors.As(err, &limit):
+		return reasonThrottled, true
+	case errors.As(err, &txConflict):
+		return reasonConflict, true
+	case errors.As(err, &tce):
+		return cancellationReason(tce)
+	case errors.As(err, &internal):
+		return reasonServer, idempotent
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.ErrorCode() == "ThrottlingException" {
+			return reasonThrottled, true
+		}
+		if apiErr.ErrorFault() == smithy.FaultServer {
+			return reasonServer, idempotent
+		}
+	}
+	return "", false
+}
+
+// cancellationReason returns why a cancelled transaction is worth writing
+// again. It is only retried if every item that was rejected was throttled or
+// in conflict with another transaction, since a failed condition or an invalid
+// item fails again.
+func cancellationReason(tce *types.TransactionCanceledException) (string, bool) {
+	reason := ""
+	for _, cancellation := range tce.CancellationReasons {
+		switch aws.StringValue(cancellation.Code) {
+		case "", "None":
+		case "ThrottlingError", "ProvisionedThroughputExceeded", "RequestLimitExceeded":
+			reason = reasonThrottled
+		case "TransactionConflict":
+			if reason == "" {
+				reason = reasonConflict
+			}
+		default:
+			return "", false
+		}
+	}
+	return reason, reason != ""
+}
@@ -0,0 +1,267 @@
+package retry
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPI fails the first calls of GetItem with the provided errors, and
+// leaves one item of every BatchWriteItem call unprocessed until it runs out
+// of unprocessed calls.
+type fakeAPI struct {
+	API
+	getErrors   []error
+	gets        int
+	unprocessed int
+	batches     []int
+	txErrors    []error
+	tokens      []string
+	putErrors   []error
+	puts        int
+}
+
+func (f *fakeAPI) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.gets++
+	if f.gets <= len(f.getErrors) {
+		return nil, f.getErrors[f.gets-1]
+	}
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func (f *fakeAPI) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	requests := params.RequestItems["table"]
+	f.batches = append(f.batches, len(requests))
+	if f.unprocessed == 0 || len(requests) == 0 {
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	}
+	f.unprocessed--
+	return &dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: map[string][]types.WriteRequest{"table": requests[1:]},
+	}, nil
+}
+
+func (f *fakeAPI) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	f.tokens = append(f.tokens, aws.StringValue(params.ClientRequestToken))
+	if len(f.tokens) <= len(f.txErrors) {
+		return nil, f.txErrors[len(f.tokens)-1]
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (f *fakeAPI) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.puts++
+	if f.puts <= len(f.putErrors) {
+		return nil, f.putErrors[f.puts-1]
+	}
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// total returns the sum of the counters collected from the provided collector.
+func total(t *testing.T, collector prometheus.Collector) float64 {
+	t.Helper()
+	metrics := make(chan prometheus.Metric, 16)
+	collector.Collect(metrics)
+	close(metrics)
+
+	sum := 0.0
+	for metric := range metrics {
+		var m dto.Metric
+		require.NoError(t, metric.Write(&m))
+		sum += m.GetCounter().GetValue()
+	}
+	return sum
+}
+
+func newTestClient(t *testing.T, api API, policy Policy) (*Client, *Metrics) {
+	t.Helper()
+	metrics, err := NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	return NewClient(api, policy, slog.Default(), metrics), metrics
+}
+
+func TestClient_Retries(t *testing.T) {
+	throttled := &types.ProvisionedThroughputExceededException{}
+	policy := Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	tests := map[string]struct {
+		errors       []error
+		wantErr      bool
+		wantGets     int
+		wantRetries  float64
+		wantThrottle float64
+	}{
+		"succeeds": {
+			wantGets: 1,
+		},
+		"throttled once": {
+			errors:       []error{throttled},
+			wantGets:     2,
+			wantRetries:  1,
+			wantThrottle: 1,
+		},
+		"throttled throughout": {
+			errors:       []error{throttled, throttled, throttled},
+			wantErr:      true,
+			wantGets:     3,
+			wantRetries:  2,
+			wantThrottle: 3,
+		},
+		"server fault": {
+			errors:      []error{&types.InternalServerError{}},
+			wantGets:    2,
+			wantRetries: 1,
+		},
+		"failed condition": {
+			errors:   []error{&types.ConditionalCheckFailedException{}},
+			wantErr:  true,
+			wantGets: 1,
+		},
+		"cancelled by a condition": {
+			errors: []error{&types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{
+				{Code: aws.String("ThrottlingError")},
+				{Code: aws.String("ConditionalCheckFailed")},
+			}}},
+			wantErr:  true,
+			wantGets: 1,
+		},
+		"cancelled by a conflict": {
+			errors: []error{&types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{
+				{Code: aws.String("None")},
+				{Code: aws.String("TransactionConflict")},
+			}}},
+			wantGets:    2,
+			wantRetries: 1,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			api := &fakeAPI{getErrors: tc.errors}
+			client, metrics := newTestClient(t, api, policy)
+
+			_, err := client.GetItem(context.TODO(), &dynamodb.GetItemInput{})
+
+			if tc.wantErr {
+				assert.Error(t, err, "expected an error")
+			} else {
+				assert.NoError(t, err, "unexpected error")
+			}
+			assert.Equal(t, tc.wantGets, api.gets, "attempts did not match")
+			assert.Equal(t, tc.wantRetries, total(t, metrics.retries), "retries did not match")
+			assert.Equal(t, tc.wantThrottle, total(t, metrics.throttles), "throttles did not match")
+		})
+	}
+}
+
+func TestClient_Deadline(t *testing.T) {
+	throttled := &types.ProvisionedThroughputExceededException{}
+	api := &fakeAPI{getErrors: []error{throttled, throttled, throttled}}
+	client, metrics := newTestClient(t, api, Policy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour, Timeout: time.Second})
+
+	// A retry that cannot happen before the deadline is not waited for
+	start := time.Now()
+	_, err := client.GetItem(context.TODO(), &dynamodb.GetItemInput{})
+
+	assert.ErrorIs(t, err, throttled, "error did not match")
+	assert.Less(t, time.Since(start), 2*time.Second, "waited past the deadline")
+	assert.Equal(t, 1, api.gets, "request was retried")
+	assert.Equal(t, float64(1), total(t, metrics.exhausts), "exhausted retries were not counted")
+}
+
+func TestClient_BatchWriteItem(t *testing.T) {
+	requests := make([]types.WriteRequest, 4)
+	policy := Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	// Unprocessed items are sent again until they are all written
+	api := &fakeAPI{unprocessed: 2}
+	client, _ := newTestClient(t, api, policy)
+	result, err := client.BatchWriteItem(context.TODO(), &dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]types.WriteRequest{"table": requests},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, result.UnprocessedItems, "items were left unprocessed")
+	assert.Equal(t, []int{4, 3, 2}, api.batches, "only unprocessed items should be sent again")
+
+	// and returned once the attempts run out
+	api = &fakeAPI{unprocessed: 3}
+	client, metrics := newTestClient(t, api, policy)
+	result, err = client.BatchWriteItem(context.TODO(), &dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]types.WriteRequest{"table": requests},
+	})
+	require.NoError(t, err)
+	assert.Len(t, result.UnprocessedItems["table"], 1, "unprocessed items were not returned")
+	assert.Equal(t, float64(3), total(t, metrics.throttles), "throttles did not match")
+}
+
+func TestClient_TransactWriteItems(t *testing.T) {
+	policy := Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+	conflict := &types.TransactionConflictException{}
+
+	// Every attempt carries the same token, without changing the caller's input
+	api := &fakeAPI{txErrors: []error{conflict, conflict}}
+	client, _ := newTestClient(t, api, policy)
+	input := &dynamodb.TransactWriteItemsInput{}
+	_, err := client.TransactWriteItems(context.TODO(), input)
+	require.NoError(t, err)
+	require.Len(t, api.tokens, 3, "transaction was not retried")
+	assert.NotEmpty(t, api.tokens[0], "token was not set")
+	assert.Equal(t, api.tokens[0], api.tokens[1], "retry sent another token")
+	assert.Equal(t, api.tokens[0], api.tokens[2], "retry sent another token")
+	assert.Nil(t, input.ClientRequestToken, "caller's input was changed")
+
+	// and a token set by the caller is kept
+	api = &fakeAPI{}
+	client, _ = newTestClient(t, api, policy)
+	_, err = client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{ClientRequestToken: aws.String("token")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"token"}, api.tokens, "caller's token was not kept")
+}
+
+func TestClient_ServerFaults(t *testing.T) {
+	policy := Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+	fault := &types.InternalServerError{}
+	throttled := &types.ProvisionedThroughputExceededException{}
+
+	// A write that may have been applied is not sent again
+	api := &fakeAPI{putErrors: []error{fault}}
+	client, _ := newTestClient(t, api, policy)
+	_, err := client.PutItem(context.TODO(), &dynamodb.PutItemInput{})
+	assert.ErrorIs(t, err, fault, "error did not match")
+	assert.Equal(t, 1, api.puts, "write was retried after a server fault")
+
+	// unless it was throttled, and so never applied
+	api = &fakeAPI{putErrors: []error{throttled}}
+	client, _ = newTestClient(t, api, policy)
+	_, err = client.PutItem(context.TODO(), &dynamodb.PutItemInput{})
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, 2, api.puts, "throttled write was not retried")
+
+	// A transaction carries a token, so it is retried
+	api = &fakeAPI{txErrors: []error{fault}}
+	client, _ = newTestClient(t, api, policy)
+	_, err = client.TransactWriteItems(context.TODO(), &dynamodb.TransactWriteItemsInput{})
+	assert.NoError(t, err, "unexpected error")
+	assert.Len(t, api.tokens, 2, "transaction was not retried after a server fault")
+}
+
+func TestPolicy_Backoff(t *testing.T) {
+	policy := Policy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		ceiling := min(policy.BaseDelay<<(attempt-1), policy.MaxDelay)
+		for i := 0; i < 100; i++ {
+			delay := policy.backoff(attempt)
+			assert.GreaterOrEqual(t, delay, time.Duration(0), "delay was negative")
+			assert.LessOrEqual(t, delay, ceiling, "delay of attempt %d exceeded the backoff", attempt)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// ErrSchemaDrift is returned by EnsureSchema when the table does not match its
+// schema.
+var ErrSchemaDrift = fmt.Errorf("table does not match the schema")
+
+// schemaClient is the part of the DynamoDB API used to check the table the
+// services use, and to create it or its indexes.
+type schemaClient interface {
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)
+}
+
+const (
+	// schemaPollInterval is how often the table is described while waiting
+	// for it and its indexes to become ACTIVE.
+	schemaPollInterval = 2 * time.Second
+
+	// schemaWaitTimeout is how long to wait for the table and its indexes to
+	// become ACTIVE.
+	schemaWaitTimeout = 5 * time.Minute
+)
+
+// Table names the table the services read and write and its global secondary
+// index, so that every environment or developer can use a table of its own.
+type Table struct {
+	Name string
+	GSI1 string
+}
+
+// DefaultTable returns the names of the table in the seed data.
+func DefaultTable() Table {
+	return Table{Name: "BlogContent", GSI1: "GSI1"}
+}
+
+// Schema returns the table the services read and write: its primary key, the
+// types of every key attribute, and the global secondary indexes that the
+// services query.
+func (t Table) Schema() *dynamodb.CreateTableInput {
+	return &dynamodb.CreateTableInput{
+		TableName: aws.String(t.Name),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("PK"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("SK"), KeyType: types.KeyTypeRange},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("PK"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("SK"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("GSI1PK"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("GSI1SK"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String(t.GSI1),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String("GSI1PK"), KeyType: types.KeyTypeHash},
+					{AttributeName: aws.String("GSI1SK"), KeyType: types.KeyTypeRange},
+				},
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	}
+}
+
+// EnsureSchema checks that the table exists and matches its Schema. If create
+// is true, a missing table or global secondary index is created, and
+// EnsureSchema waits until the table and its indexes are ACTIVE. Differences
+// that cannot be fixed in place, such as a different key schema, are always
+// returned as an error wrapping ErrSchemaDrift that lists every difference.
+func EnsureSchema(ctx context.Context, logger *slog.Logger, client schemaClient, table Table, create bool) error {
+	want := table.Schema()
+	name := aws.StringValue(want.TableName)
+
+	described, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: want.TableName})
+	var notFound *types.ResourceNotFoundException
+	switch {
+	case errors.As(err, &notFound):
+		if !create {
+			return fmt.Errorf("[in services.EnsureSchema] %w: table %s does not exist", ErrSchemaDrift, name)
+		}
+		logger.InfoContext(ctx, "creating table", slog.String("table", name))
+		if _, err = client.CreateTable(ctx, want); err != nil {
+			return fmt.Errorf("[in services.EnsureSchema] failed to create table %s: %w", name, err)
+		}
+		return waitForSchema(ctx, client, name)
+	case err != nil:
+		return fmt.Errorf("[in services.EnsureSchema] failed to describe table %s: %w", name, err)
+	}
+
+	drift, missing := compareSchema(want, described.Table)
+	if !create {
+		for _, gsi := range missing {
+			drift = append(drift, fmt.Sprintf("index %s does not exist", aws.StringValue(gsi.IndexName)))
+		}
+	}
+	if len(drift) > 0 {
+		return fmt.Errorf("[in services.EnsureSchema] %w: table %s: %s", ErrSchemaDrift, name, strings.Join(drift, "; "))
+	}
+	if !create {
+		return nil
+	}
+
+	// DynamoDB creates one global secondary index per UpdateTable request,
+	// and the table must be ACTIVE before the next one is created.
+	if err = waitForSchema(ctx, client, name); err != nil {
+		return err
+	}
+	for _, gsi := range missing {
+		logger.InfoContext(ctx, "creating index",
+			slog.String("table", name),
+			slog.String("index", aws.StringValue(gsi.IndexName)))
+		_, err = client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+			TableName:            want.TableName,
+			AttributeDefinitions: want.AttributeDefinitions,
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+				{Create: &types.CreateGlobalSecondaryIndexAction{
+					IndexName:             gsi.IndexName,
+					KeySchema:             gsi.KeySchema,
+					Projection:            gsi.Projection,
+					ProvisionedThroughput: provisionedThroughput(described.Table),
+				}},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("[in services.EnsureSchema] failed to create index %s: %w", aws.StringValue(gsi.IndexName), err)
+		}
+		if err = waitForSchema(ctx, client, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compareSchema returns the differences between the described table and the
+// wanted schema, along with the wanted global secondary indexes the table
+// does not have.
+func compareSchema(want *dynamodb.CreateTableInput, table *types.TableDescription) ([]string, []types.GlobalSecondaryIndex) {
+	var drift []string
+	if got, wantKeys := formatKeySchema(table.KeySchema), formatKeySchema(want.KeySchema); got != wantKeys {
+		drift = append(drift, fmt.Sprintf("key schema is %s, want %s", got, wantKeys))
+	}
+
+	attributeTypes := make(map[string]string, len(table.AttributeDefinitions))
+	for _, definition := range table.AttributeDefinitions {
+		attributeTypes[aws.StringValue(definition.AttributeName)] = string(definition.AttributeType)
+	}
+	for _, definition := range want.AttributeDefinitions {
+		name := aws.StringValue(definition.AttributeName)
+		if got, ok := attributeTypes[name]; ok && got != string(definition.AttributeType) {
+			drift = append(drift, fmt.Sprintf("attribute %s has type %s, want %s", name, got, definition.AttributeType))
+		}
+	}
+
+	var missing []types.GlobalSecondaryIndex
+	for _, gsi := range want.GlobalSecondaryIndexes {
+		name := aws.StringValue(gsi.IndexName)
+		i := slices.IndexFunc(table.GlobalSecondaryIndexes, func(got types.GlobalSecondaryIndexDescription) bool {
+			return aws.StringValue(got.IndexName) == name
+		})
+		if i < 0 {
+			missing = append(missing, gsi)
+			continue
+		}
+
+		got := table.GlobalSecondaryIndexes[i]
+		if gotKeys, wantKeys := formatKeySchema(got.KeySchema), formatKeySchema(gsi.KeySchema); gotKeys != wantKeys {
+			drift = append(drift, fmt.Sprintf("index %s key schema is %s, want %s", name, gotKeys, wantKeys))
+		}
+		if gotProjection, wantProjection := formatProjection(got.Projection), formatProjection(gsi.Projection); gotProjection != wantProjection {
+			drift = append(drift, fmt.Sprintf("index %s projects %s, want %s", name, gotProjection, wantProjection))
+		}
+	}
+	return drift, missing
+}
+
+// formatKeySchema describes a key schema, hash key first, e.g. "PK HASH, SK
+// RANGE".
+func formatKeySchema(schema []types.KeySchemaElement) string {
+	elements := slices.Clone(schema)
+	slices.SortStableFunc(elements, func(a, b types.KeySchemaElement) int {
+		return strings.Compare(string(a.KeyType), string(b.KeyType))
+	})
+	parts := make([]string, len(elements))
+	for i, element := range elements {
+		parts[i] = fmt.Sprintf("%s %s", aws.StringValue(element.AttributeName), element.KeyType)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatProjection describes a projection, e.g. "ALL" or "INCLUDE (title)".
+func formatProjection(projection *types.Projection) string {
+	if projection == nil {
+		return string(types.ProjectionTypeAll)
+	}
+	if len(projection.NonKeyAttributes) == 0 {
+		return string(projection.ProjectionType)
+	}
+	attributes := slices.Clone(projection.NonKeyAttributes)
+	slices.Sort(attributes)
+	return fmt.Sprintf("%s (%s)", projection.ProjectionType, strings.Join(attributes, ", "))
+}
+
+// provisionedThroughput returns the throughput of a table with provisioned
+// billing, which its new indexes must be given, or nil for an on-demand
+// table.
+func provisionedThroughput(table *types.TableDescription) *types.ProvisionedThroughput {
+	if table.BillingModeSummary != nil && table.BillingModeSummary.BillingMode == types.BillingModePayPerRequest {
+		return nil
+	}
+	if table.ProvisionedThroughput == nil || aws.Int64Value(table.ProvisionedThroughput.ReadCapacityUnits) == 0 {
+		return nil
+	}
+	return &types.ProvisionedThroughput{
+		ReadCapacityUnits:  table.ProvisionedThroughput.ReadCapacityUnits,
+		WriteCapacityUnits: table.ProvisionedThroughput.WriteCapacityUnits,
+	}
+}
+
+// waitForSchema waits until the table and all of its global secondary indexes
+// are ACTIVE.
+func waitForSchema(ctx context.Context, client schemaClient, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, schemaWaitTimeout)
+	defer cancel()
+
+	for {
+		described, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(name)})
+		if err != nil {
+			return fmt.Errorf("[in services.waitForSchema] failed to describe table %s: %w", name, err)
+		}
+		if isActive(described.Table) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("[in services.waitForSchema] table %s and its indexes did not become ACTIVE: %w", name, ctx.Err())
+		case <-time.After(schemaPollInterval):
+		}
+	}
+}
+
+// isActive reports whether a table and all of its global secondary indexes
+// are ACTIVE.
+func isActive(table *types.TableDescription) bool {
+	if table.TableStatus != types.TableStatusActive {
+		return false
+	}
+	for _, gsi := range table.GlobalSecondaryIndexes {
+		if gsi.IndexStatus != types.IndexStatusActive {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/agallagher-captech/blog/internal/seed"
+	"github.com/agallagher-captech/blog/internal/services/memory"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableSchema_MatchesSeedSchema(t *testing.T) {
+	f, err := os.Open("../../dynamodb_seed/" + seed.SchemaFile)
+	require.NoError(t, err)
+	defer f.Close()
+
+	seedSchema, err := seed.ReadTableSchema(f)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTable().Schema(), seedSchema, "the seed schema must create the table the services expect")
+}
+
+func TestEnsureSchema(t *testing.T) {
+	// withoutIndexes is the table the services expect, but without its
+	// global secondary indexes
+	withoutIndexes := DefaultTable().Schema()
+	withoutIndexes.GlobalSecondaryIndexes = nil
+	withoutIndexes.AttributeDefinitions = withoutIndexes.AttributeDefinitions[:2]
+
+	// keysOnly projects only the keys into GSI1
+	keysOnly := DefaultTable().Schema()
+	keysOnly.GlobalSecondaryIndexes[0].Projection = &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly}
+
+	// hashOnly has no range key, which cannot be fixed without recreating it
+	hashOnly := DefaultTable().Schema()
+	hashOnly.KeySchema = hashOnly.KeySchema[:1]
+
+	tests := map[string]struct {
+		table       *dynamodb.CreateTableInput
+		create      bool
+		wantErr     bool
+		wantMessage string
+	}{
+		"matching table": {
+			table: DefaultTable().Schema(),
+		},
+		"missing table": {
+			wantErr:     true,
+			wantMessage: "table BlogContent does not exist",
+		},
+		"missing table is created": {
+			create: true,
+		},
+		"missing index": {
+			table:       withoutIndexes,
+			wantErr:     true,
+			wantMessage: "index GSI1 does not exist",
+		},
+		"missing index is created": {
+			table:  withoutIndexes,
+			create: true,
+		},
+		"different projection": {
+			table:       keysOnly,
+			create:      true,
+			wantErr:     true,
+			wantMessage: "index GSI1 projects KEYS_ONLY, want ALL",
+		},
+		"different key schema": {
+			table:       hashOnly,
+			create:      true,
+			wantErr:     true,
+			wantMessage: "key schema is PK HASH, want PK HASH, SK RANGE",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := memory.NewClient()
+			if tc.table != nil {
+				_, err := client.CreateTable(context.TODO(), tc.table)
+				require.NoError(t, err)
+			}
+
+			err := EnsureSchema(context.TODO(), slog.Default(), client, DefaultTable(), tc.create)
+
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrSchemaDrift, "expected schema drift")
+				assert.ErrorContains(t, err, tc.wantMessage, "error did not describe the drift")
+				return
+			}
+			require.NoError(t, err, "unexpected error")
+
+			// Whatever was created must now match the schema
+			described, err := client.DescribeTable(context.TODO(), &dynamodb.DescribeTableInput{
+				TableName: aws.String("BlogContent"),
+			})
+			require.NoError(t, err)
+			drift, missing := compareSchema(DefaultTable().Schema(), described.Table)
+			assert.Empty(t, drift, "table did not match the schema")
+			assert.Empty(t, missing, "table is missing indexes")
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/agallagher-captech/blog/internal/keys"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// maxTransactWriteItems is the maximum number of items DynamoDB accepts in a
+// single TransactWriteItems call.
+const maxTransactWriteItems = 100
+
+// transaction collects the puts, updates, deletes and condition checks of a
+// TransactWriteItems request. Each item names the entity it writes, so that a
+// cancelled transaction is reported as the domain error of the item whose
+// condition failed rather than as a DynamoDB error.
+//
+// A transaction is atomic and holds at most maxTransactWriteItems items,
+// unless it is split. A split transaction is written in chunks, in the order
+// its items were added, and each chunk is atomic on its own. Its guards are
+// checked in every chunk, so a chunk is only written while they hold.
+type transaction struct {
+	table  string
+	items  []transactionItem
+	guards []transactionItem
+	split  bool
+
+	// lastGuard is set while the guard was added after every item, so that
+	// failWith applies to it.
+	lastGuard bool
+}
+
+// transactionItem is a single write of a transaction.
+type transactionItem struct {
+	write  types.TransactWriteItem
+	entity string
+	key    keys.Key
+
+	// cond is the item's condition, which reports its own failure unless
+	// failure is set.
+	cond condition
+
+	// failure is the error reported when the item's condition fails. If it
+	// is nil the error depends on the item returned by the failed
+	// condition, as described by condition.failure.
+	failure func(op, entity string) error
+}
+
+// newTransaction returns an empty transaction on the provided table.
+func newTransaction(table Table) *transaction {
+	return &transaction{table: table.Name}
+}
+
+// allowSplit lets the transaction be written in chunks if it holds more items
+// than a single request can. Items should be added so that a transaction
+// that fails after some chunks were written can be run again, e.g. by
+// deleting an entity after everything that depends on it.
+func (t *transaction) allowSplit() *transaction {
+	t.split = true
+	return t
+}
+
+// create adds a put of a new item, which fails with ErrAlreadyExists if an
+// item with the same key exists.
+func (t *transaction) create(entity string, item map[string]types.AttributeValue) *transaction {
+	t.add(transactionItem{
+		write: types.TransactWriteItem{Put: &types.Put{
+			TableName:           aws.String(t.table),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(PK) AND attribute_not_exists(SK)"),
+		}},
+		entity:  entity,
+		key:     itemKey(item),
+		failure: alreadyExists,
+	})
+	return t
+}
+
+// put adds a put of an item that only applies while the condition holds.
+func (t *transaction) put(entity string, item map[string]types.AttributeValue, cond condition) *transaction {
+	t.add(transactionItem{
+		write: types.TransactWriteItem{Put: &types.Put{
+			TableName:                           aws.String(t.table),
+			Item:                                item,
+			ConditionExpression:                 cond.expression,
+			ExpressionAttributeNames:            cond.names,
+			ExpressionAttributeValues:           cond.values,
+			ReturnValuesOnConditionCheckFailure: returnValuesOn(cond.expression),
+		}},
+		entity: entity,
+		key:    itemKey(item),
+		cond:   cond,
+	})
+	return t
+}
+
+// update adds an UpdateItem request, such as one built by Patch.updateItem.
+// Its table name is set by the transaction and its return values are
+// ignored.
+func (t *transaction) update(entity string, input *dynamodb.UpdateItemInput) *transaction {
+	t.add(transactionItem{
+		write: types.TransactWriteItem{Update: &types.Update{
+			TableName:                           aws.String(t.table),
+			Key:                                 input.Key,
+			UpdateExpression:                    input.UpdateExpression,
+			ConditionExpression:                 input.ConditionExpression,
+			ExpressionAttributeNames:            input.ExpressionAttributeNames,
+			ExpressionAttributeValues:           input.ExpressionAttributeValues,
+			ReturnValuesOnConditionCheckFailure: returnValuesOn(input.ConditionExpression),
+		}},
+		entity: entity,
+		key:    itemKey(input.Key),
+	})
+	return t
+}
+
+// delete adds a delete of the item with the provided key. If cond has no
+// expression the delete is unconditional.
+func (t *transaction) delete(entity string, key keys.Key, cond condition) *transaction {
+	t.add(transactionItem{
+		write: types.TransactWriteItem{Delete: &types.Delete{
+			TableName:                           aws.String(t.table),
+			Key:                                 key.AttributeValues(),
+			ConditionExpression:                 cond.expression,
+			ExpressionAttributeNames:            cond.names,
+			ExpressionAttributeValues:           cond.values,
+			ReturnValuesOnConditionCheckFailure: returnValuesOn(cond.expression),
+		}},
+		entity: entity,
+		key:    key,
+		cond:   cond,
+	})
+	return t
+}
+
+// check adds a condition that the item with the provided key must meet for
+// the transaction to be written, without writing the item.
+func (t *transaction) check(entity string, key keys.Key, cond condition) *transaction {
+	t.add(conditionCheck(t.table, entity, key, cond))
+	return t
+}
+
+// guard adds a condition, like check, that is checked again in every chunk of
+// a split transaction.
+func (t *transaction) guard(entity string, key keys.Key, cond condition) *transaction {
+	t.guards = append(t.guards, conditionCheck(t.table, entity, key, cond))
+	t.lastGuard = true
+	return t
+}
+
+// failWith replaces the error reported when the condition of the item or
+// guard that was added last fails, e.g. with alreadyExists.
+func (t *transaction) failWith(failure func(op, entity string) error) *transaction {
+	switch {
+	case t.lastGuard:
+		t.guards[len(t.guards)-1].failure = failure
+	case len(t.items) > 0:
+		t.items[len(t.items)-1].failure = failure
+	}
+	return t
+}
+
+// add appends an item to the transaction.
+func (t *transaction) add(item transactionItem) {
+	t.items = append(t.items, item)
+	t.lastGuard = false
+}
+
+// execute writes the transaction, naming the provided operation in its
+// errors. If a condition fails, the error of every item whose condition
+// failed is returned. A transaction that is not split fails with a
+// validation error if it holds too many items.
+func (t *transaction) execute(ctx context.Context, client dynamoClient, op string) error {
+	chunks, err := t.chunks(op)
+	if err != nil {
+		return err
+	}
+
+	for _, chunk := range chunks {
+		writes := make([]types.TransactWriteItem, len(chunk))
+		for i, item := range chunk {
+			writes[i] = item.write
+		}
+
+		_, err = client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: writes})
+		if err != nil {
+			return cancellationError(op, chunk, err)
+		}
+	}
+	return nil
+}
+
+// chunks returns the items of the transaction in the groups they are written
+// in. Guards are added to every chunk that does not already write the guarded
+// item, since a transaction cannot act on the same item twice.
+func (t *transaction) chunks(op string) ([][]transactionItem, error) {
+	entity := "item"
+	if len(t.items) > 0 {
+		entity = t.items[0].entity
+	}
+	size := maxTransactWriteItems - len(t.guards)
+	if size <= 0 || (!t.split && len(t.items) > size) {
+		return nil, &Error{
+			Kind:   KindValidation,
+			Op:     op,
+			Entity: entity,
+			Err:    fmt.Errorf("%w: transaction has %d items, at most %d can be written atomically", ErrValidation, len(t.items)+len(t.guards), maxTransactWriteItems),
+		}
+	}
+
+	var chunks [][]transactionItem
+	for start := 0; start < len(t.items); start += size {
+		end := min(start+size, len(t.items))
+
+		chunk := make([]transactionItem, 0, end-start+len(t.guards))
+		for _, guard := range t.guards {
+			if !writesKey(t.items[start:end], guard.key) {
+				chunk = append(chunk, guard)
+			}
+		}
+		chunks = append(chunks, append(chunk, t.items[start:end]...))
+	}
+	return chunks, nil
+}
+
+// cancellationError returns the error for a chunk of a transaction that
+// failed. A cancelled transaction is reported by the reasons its items were
+// rejected, which are listed in the order of the chunk.
+func cancellationError(op string, chunk []transactionItem, err error) error {
+	var tce *types.TransactionCanceledException
+	if !errors.As(err, &tce) || len(tce.CancellationReasons) != len(chunk) {
+		entity := "item"
+		if len(chunk) > 0 {
+			entity = chunk[0].entity
+		}
+		return newError(op, entity, fmt.Errorf("failed to write transaction: %w", err))
+	}
+
+	var failures []error
+	for i, reason := range tce.CancellationReasons {
+		item := chunk[i]
+		switch code := aws.StringValue(reason.Code); code {
+		case "", "None":
+			continue
+		case "ConditionalCheckFailed":
+			if item.failure != nil {
+				failures = append(failures, item.failure(op, item.entity))
+				continue
+			}
+			failures = append(failures, item.cond.failure(op, item.entity, reason.Item))
+		default:
+			failures = append(failures, &Error{
+				Kind:   cancellationKind(code),
+				Op:     op,
+				Entity: item.entity,
+				Err:    fmt.Errorf("transaction item %d was rejected with %s: %w", i, code, err),
+			})
+		}
+	}
+
+	switch len(failures) {
+	case 0:
+		return newError(op, chunk[0].entity, fmt.Errorf("failed to write transaction: %w", err))
+	case 1:
+		return failures[0]
+	default:
+		return errors.Join(failures...)
+	}
+}
+
+// conditionCheck returns a transaction item checking the condition on the
+// item with the provided key.
+func conditionCheck(table, entity string, key keys.Key, cond condition) transactionItem {
+	return transactionItem{
+		write: types.TransactWriteItem{ConditionCheck: &types.ConditionCheck{
+			TableName:                           aws.String(table),
+			Key:                                 key.AttributeValues(),
+			ConditionExpression:                 cond.expression,
+			ExpressionAttributeNames:            cond.names,
+			ExpressionAttributeValues:           cond.values,
+			ReturnValuesOnConditionCheckFailure: returnValuesOn(cond.expression),
+		}},
+		entity: entity,
+		key:    key,
+		cond:   cond,
+	}
+}
+
+// returnValuesOn asks for the item a condition failed on, so that a missing
+// item can be told apart from one at another version. Items without a
+// condition ask for nothing.
+func returnValuesOn(expression *string) types.ReturnValuesOnConditionCheckFailure {
+	if expression == nil {
+		return ""
+	}
+	return types.ReturnValuesOnConditionCheckFailureAllOld
+}
+
+// itemKey returns the primary key of the provided item.
+func itemKey(item map[string]types.AttributeValue) keys.Key {
+	var key keys.Key
+	if pk, ok := item["PK"].(*types.AttributeValueMemberS); ok {
+		key.PK = pk.Value
+	}
+	if sk, ok := item["SK"].(*types.AttributeValueMemberS); ok {
+		key.SK = sk.Value
+	}
+	return key
+}
+
+// writesKey reports whether one of the items acts on the item with the
+// provided key.
+func writesKey(items []transactionItem, key keys.Key) bool {
+	for _, item := range items {
+		if item.key == key {
+			return true
+		}
+	}
+	return false
+}
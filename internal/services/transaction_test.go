@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/agallagher-captech/blog/internal/keys"
+	"github.com/agallagher-captech/blog/internal/services/memory"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testItem returns an item with the provided key and attributes.
+func testItem(key keys.Key, attributes map[string]types.AttributeValue) map[string]types.AttributeValue {
+	item := key.AttributeValues()
+	for name, value := range attributes {
+		item[name] = value
+	}
+	return item
+}
+
+func TestTransaction_Chunks(t *testing.T) {
+	parent := keys.Key{PK: "PARENT#1", SK: "PARENT"}
+	children := func(tx *transaction, count int) *transaction {
+		for i := 0; i < count; i++ {
+			tx.delete("child", keys.Key{PK: parent.PK, SK: fmt.Sprintf("CHILD#%03d", i)}, condition{})
+		}
+		return tx
+	}
+
+	// An atomic transaction is written in a single request
+	chunks, err := children(newTransaction(DefaultTable()), maxTransactWriteItems).chunks("test")
+	require.NoError(t, err)
+	assert.Len(t, chunks, 1, "atomic transaction was split")
+
+	_, err = children(newTransaction(DefaultTable()), maxTransactWriteItems+1).chunks("test")
+	assert.ErrorIs(t, err, ErrValidation, "oversized transaction was accepted")
+
+	// A split transaction repeats its guard in every chunk, except the one
+	// that writes the guarded item itself
+	tx := children(newTransaction(DefaultTable()).allowSplit().guard("parent", parent, deleteCondition(0)), 250)
+	tx.delete("parent", parent, deleteCondition(0))
+	chunks, err = tx.chunks("test")
+	require.NoError(t, err)
+	require.Len(t, chunks, 3, "transaction was not split")
+
+	written := 0
+	for i, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk), maxTransactWriteItems, "chunk %d was too long", i)
+		guards := 0
+		for _, item := range chunk {
+			if item.write.ConditionCheck != nil {
+				guards++
+			} else {
+				written++
+			}
+		}
+		if i < len(chunks)-1 {
+			assert.Equal(t, 1, guards, "chunk %d was not guarded", i)
+		} else {
+			assert.Zero(t, guards, "guard was repeated with the guarded item")
+			assert.Equal(t, parent, chunk[len(chunk)-1].key, "parent was not deleted last")
+		}
+	}
+	assert.Equal(t, 251, written, "items were lost between chunks")
+}
+
+func TestTransaction_Execute(t *testing.T) {
+	ctx := context.TODO()
+	client := memory.NewClient()
+	_, err := client.CreateTable(ctx, DefaultTable().Schema())
+	require.NoError(t, err)
+
+	first := keys.Key{PK: "ITEM#1", SK: "ITEM"}
+	second := keys.Key{PK: "ITEM#2", SK: "ITEM"}
+	missing := keys.Key{PK: "ITEM#3", SK: "ITEM"}
+	version := map[string]types.AttributeValue{"version": versionValue(1)}
+
+	err = newTransaction(DefaultTable()).
+		create("first", testItem(first, version)).
+		create("second", testItem(second, version)).
+		execute(ctx, client, "test")
+	require.NoError(t, err)
+
+	// Every item whose condition failed is reported, and nothing is written
+	err = newTransaction(DefaultTable()).
+		create("first", testItem(first, nil)).
+		create("second", testItem(second, nil)).
+		create("third", testItem(missing, nil)).
+		execute(ctx, client, "test")
+	var serviceErr *Error
+	require.ErrorAs(t, err, &serviceErr)
+	assert.ErrorIs(t, err, ErrAlreadyExists, "duplicates were created")
+	assert.Contains(t, err.Error(), "first", "first failure was not reported")
+	assert.Contains(t, err.Error(), "second", "second failure was not reported")
+	assert.NotContains(t, err.Error(), "third", "item without a failure was reported")
+
+	// Versioned writes tell a stale item from a missing one
+	err = newTransaction(DefaultTable()).
+		put("first", testItem(first, nil), versionCondition(2)).
+		execute(ctx, client, "test")
+	assert.ErrorIs(t, err, ErrVersionMismatch, "stale item was written")
+
+	err = newTransaction(DefaultTable()).
+		delete("third", missing, deleteCondition(1)).
+		execute(ctx, client, "test")
+	assert.ErrorIs(t, err, ErrNotFound, "missing item was deleted")
+
+	// A condition check can report its own error
+	err = newTransaction(DefaultTable()).
+		check("second", second, condition{expression: aws.String("attribute_not_exists(PK)")}).
+		failWith(alreadyExists).
+		execute(ctx, client, "test")
+	assert.ErrorIs(t, err, ErrAlreadyExists, "custom failure was not reported")
+
+	err = newTransaction(DefaultTable()).
+		put("first", testItem(first, map[string]types.AttributeValue{"version": versionValue(2)}), versionCondition(1)).
+		delete("second", second, deleteCondition(1)).
+		execute(ctx, client, "test")
+	assert.NoError(t, err, "current items were not written")
+}
@@ -2,9 +2,14 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
+	"strings"
 
+	"github.com/agallagher-captech/blog/internal/keys"
 	"github.com/agallagher-captech/blog/internal/models"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -19,108 +24,86 @@ type dynamoClient interface {
 	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
 	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
 	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
 	// Add any other methods you might need from the DynamoDB client
 }
 
-var ErrNotFound = fmt.Errorf("item not found")
-var ErrAlreadyExists = fmt.Errorf("item already exists")
+var ErrInvalidCredentials = fmt.Errorf("invalid credentials")
 
 // UsersService is a service capable of performing CRUD operations for
 // models.User models.
+//
+// Users are stored through a Repository, but the writes that move the user's
+// email marker are transactions made by the service itself.
 type UsersService struct {
 	logger *slog.Logger
 	client dynamoClient
+	table  Table
+	users  *Repository[models.User, *models.User]
 }
 
 // NewUsersService creates a new UsersService and returns a pointer to it.
-func NewUsersService(logger *slog.Logger, client dynamoClient) *UsersService {
+func NewUsersService(logger *slog.Logger, client dynamoClient, table Table, cursors *Cursors) *UsersService {
 	return &UsersService{
 		logger: logger,
 		client: client,
+		table:  table,
+		users:  NewRepository[models.User](logger, client, table, cursors, "user"),
 	}
 }
 
+// emailMarkerKey returns the primary key of the marker item that reserves the
+// provided email address for a single user.
+func emailMarkerKey(email string) map[string]types.AttributeValue {
+	return keys.EmailKey(models.NormalizeEmail(email)).AttributeValues()
+}
+
+// emailMarkerItem returns the marker item reserving the provided email address
+// for the user with the provided id.
+func emailMarkerItem(email string, id uuid.UUID) map[string]types.AttributeValue {
+	item := emailMarkerKey(email)
+	item["user_id"] = &types.AttributeValueMemberS{Value: id.String()}
+	return item
+}
+
 // CreateUser attempts to create the provided user, returning a fully hydrated
-// models.User or an error.
-// CreateUser attempts to create a new user in the database. It returns an error if the user could not be created.
+// models.User or an error. The user's email address is reserved with a marker
+// item written in the same transaction, so ErrAlreadyExists is returned if the
+// id or the email address is already taken.
 func (s *UsersService) CreateUser(ctx context.Context, user models.User) (models.User, error) {
 	s.logger.InfoContext(ctx, "Creating user", "id", user.ID)
 
-	// Interim: Scan the table for an existing user with the same email
-	// TODO: Replace this with a GSI key query for better performance
-	scanInput := &dynamodb.ScanInput{
-		TableName:        aws.String("BlogContent"),
-		FilterExpression: aws.String("email = :email"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":email": &types.AttributeValueMemberS{Value: user.Email},
-		},
-		ProjectionExpression: aws.String("email"),
-	}
-	scanResult, err := s.client.Scan(ctx, scanInput)
-	if err != nil {
-		return models.User{}, fmt.Errorf("[in main.UsersService.CreateUser] failed to scan for email: %w", err)
-	}
-	if len(scanResult.Items) > 0 {
-		return models.User{}, ErrAlreadyExists
-	}
-
-	// Check if the user already exists
-	result, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: aws.String("BlogContent"),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{
-				Value: fmt.Sprintf("USER#%s", user.ID.String()),
-			},
-			"SK": &types.AttributeValueMemberS{
-				Value: "PROFILE",
-			},
-		},
-	})
+	// Only ever store the hash of the user's password
+	hash, err := models.HashPassword(user.Password)
 	if err != nil {
-		return models.User{}, fmt.Errorf(
-			"[in main.UsersService.CreateUser] failed to check if user exists: %w",
-			err,
-		)
-	}
-
-	// If the user already exists, return an error
-	if result.Item != nil {
-		return models.User{}, fmt.Errorf(
-			"[in main.UsersService.CreateUser] user already exists.",
+		return models.User{}, newError(
+			"UsersService.CreateUser",
+			"user",
+			fmt.Errorf("failed to hash password: %w", err),
 		)
 	}
+	user.Password = hash
 
 	// Marshal the user struct into a map of DynamoDB AttributeValues
-	item, err := attributevalue.MarshalMap(user)
+	item, _, err := s.users.marshal("UsersService.CreateUser", &user)
 	if err != nil {
-		return models.User{}, fmt.Errorf(
-			"[in main.UsersService.CreateUser] failed to marshal user: %w",
-			err,
-		)
-	}
-
-	// Add the PK and SK to the item
-	item["PK"] = &types.AttributeValueMemberS{
-		Value: fmt.Sprintf("USER#%s", user.ID.String()),
-	}
-	item["SK"] = &types.AttributeValueMemberS{
-		Value: "PROFILE",
+		return models.User{}, err
 	}
-
-	// Put the item into DynamoDB
-	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName:           aws.String("BlogContent"),
-		Item:                item,
-		ConditionExpression: aws.String("attribute_not_exists(PK) AND attribute_not_exists(SK)"),
-	})
+	item["version"] = versionValue(1)
+
+	// Put the user and its email marker into DynamoDB, failing if either
+	// already exists
+	err = newTransaction(s.table).
+		create("user", item).
+		create("email", emailMarkerItem(user.Email, user.ID.UUID)).
+		execute(ctx, s.client, "UsersService.CreateUser")
 	if err != nil {
-		return models.User{}, fmt.Errorf(
-			"[in main.UsersService.CreateUser] failed to put item: %w",
-			err,
-		)
+		return models.User{}, err
 	}
 
-	return user, nil
+	return s.users.unmarshal("UsersService.CreateUser", item)
 }
 
 // ReadUser attempts to read a user from the database using the provided id. A
@@ -128,82 +111,103 @@ func (s *UsersService) CreateUser(ctx context.Context, user models.User) (models
 func (s *UsersService) ReadUser(ctx context.Context, id uuid.UUID) (models.User, error) {
 	s.logger.InfoContext(ctx, "Reading user", "id", id)
 
-	// get item from DynamoDB by PK and SK
+	return s.users.Get(ctx, keys.UserKey(id))
+}
+
+// ReadUserByEmail attempts to read the user that registered the provided email
+// address. The email marker item is used to resolve the user's id, so no scan
+// is needed. A fully hydrated models.User or error is returned.
+func (s *UsersService) ReadUserByEmail(ctx context.Context, email string) (models.User, error) {
+	s.logger.InfoContext(ctx, "Reading user by email")
+
+	// get the email marker from DynamoDB by PK and SK
 	result, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: aws.String("BlogContent"),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{
-				Value: fmt.Sprintf("USER#%s", id.String()),
-			},
-			"SK": &types.AttributeValueMemberS{
-				Value: "PROFILE",
-			},
-		},
+		TableName: aws.String(s.table.Name),
+		Key:       emailMarkerKey(email),
 	})
 	if err != nil {
-		return models.User{}, fmt.Errorf(
-			"[in main.UsersService.ReadUser] failed to get item: %w",
-			err,
+		return models.User{}, newError(
+			"UsersService.ReadUserByEmail",
+			"user",
+			fmt.Errorf("failed to get item: %w", err),
 		)
 	}
 
 	// handle item not found
 	if result.Item == nil {
-		return models.User{}, ErrNotFound
+		return models.User{}, notFound("UsersService.ReadUserByEmail", "user")
 	}
 
-	// Unmarshal the results into the models.User struct
-	var user models.User
-	if err = attributevalue.UnmarshalMap(result.Item, &user); err != nil {
-		return models.User{}, fmt.Errorf(
-			"[in main.UsersService.ReadUser] failed to unmarshal result: %w",
-			err,
+	var marker struct {
+		UserID models.UUID `dynamodbav:"user_id"`
+	}
+	if err = attributevalue.UnmarshalMap(result.Item, &marker); err != nil {
+		return models.User{}, newError(
+			"UsersService.ReadUserByEmail",
+			"user",
+			fmt.Errorf("failed to unmarshal result: %w", err),
 		)
 	}
 
+	return s.ReadUser(ctx, marker.UserID.UUID)
+}
+
+// dummyPasswordHash is a bcrypt hash, at the default cost, that passwords are
+// compared against when no user has the email address being logged in with.
+const dummyPasswordHash = "$2a$10$DHB59tiHzSDXzm3M3YervenT0fwKwtrPSUCrtUC/FwqWOSdWuBJ0e"
+
+// checkPassword reports whether the password matches the user's hash. It is a
+// variable so tests can observe the comparisons.
+var checkPassword = models.User.VerifyPassword
+
+// VerifyPassword checks the provided plaintext password against the stored
+// hash of the user registered with the provided email address. The matching
+// models.User is returned, or ErrInvalidCredentials if the user does not exist
+// or the password does not match. A password is compared against a dummy hash
+// when the user does not exist, so that a login takes as long whether or not
+// its email address is registered.
+func (s *UsersService) VerifyPassword(ctx context.Context, email, password string) (models.User, error) {
+	s.logger.InfoContext(ctx, "Verifying user password")
+
+	user, err := s.ReadUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			checkPassword(models.User{Password: dummyPasswordHash}, password)
+			return models.User{}, ErrInvalidCredentials
+		}
+		return models.User{}, newError(
+			"UsersService.VerifyPassword",
+			"user",
+			fmt.Errorf("failed to read user: %w", err),
+		)
+	}
+
+	if !checkPassword(user, password) {
+		return models.User{}, ErrInvalidCredentials
+	}
+
 	return user, nil
 }
 
 // UpdateUser attempts to perform an update of the user with the provided id,
-// updating it to reflect the properties on the provided patch object. A
-// models.User or an error is returned.
+// updating it to reflect the properties on the provided patch object. If the
+// patch has a non-zero Version the user must still be at that version, or
+// ErrVersionMismatch is returned. A models.User or an error is returned.
 func (s *UsersService) UpdateUser(ctx context.Context, id uuid.UUID, patch models.User) (models.User, error) {
 	s.logger.InfoContext(ctx, "Updating user", "id", id)
 
 	// Check if the user exists
-	result, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: aws.String("BlogContent"),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{
-				Value: fmt.Sprintf("USER#%s", id.String()),
-			},
-			"SK": &types.AttributeValueMemberS{
-				Value: "PROFILE",
-			},
-		},
-	})
+	existingUser, err := s.users.Get(ctx, keys.UserKey(id))
 	if err != nil {
-		return models.User{}, fmt.Errorf(
-			"[in main.UsersService.UpdateUser] failed to get item: %w",
-			err,
-		)
+		return models.User{}, err
 	}
 
-	// Handle item not found
-	if result.Item == nil {
-		return models.User{}, ErrNotFound
-	}
-
-	// Unmarshal the existing user into the models.User struct
-	var existingUser models.User
-	if err = attributevalue.UnmarshalMap(result.Item, &existingUser); err != nil {
-		return models.User{}, fmt.Errorf(
-			"[in main.UsersService.UpdateUser] failed to unmarshal result: %w",
-			err,
-		)
+	if !checkVersion(patch.Version, existingUser.Version) {
+		return models.User{}, versionMismatch("UsersService.UpdateUser", "user")
 	}
 
 	// Update the existing user with the patch data
+	previousEmail := existingUser.Email
 	if patch.Name != "" {
 		existingUser.Name = patch.Name
 	}
@@ -211,95 +215,326 @@ func (s *UsersService) UpdateUser(ctx context.Context, id uuid.UUID, patch model
 		existingUser.Email = patch.Email
 	}
 	if patch.Password != "" {
-		existingUser.Password = patch.Password
+		hash, err := models.HashPassword(patch.Password)
+		if err != nil {
+			return models.User{}, newError(
+				"UsersService.UpdateUser",
+				"user",
+				fmt.Errorf("failed to hash password: %w", err),
+			)
+		}
+		existingUser.Password = hash
+	}
+
+	// Without a new email address the user is simply replaced
+	if models.NormalizeEmail(previousEmail) == models.NormalizeEmail(existingUser.Email) {
+		return s.users.Replace(ctx, existingUser, patch.Version)
+	}
+
+	// Only write if nobody else has written since the user was read
+	updatedItem, version, err := s.users.marshal("UsersService.UpdateUser", &existingUser)
+	if err != nil {
+		return models.User{}, err
 	}
+	updatedItem["version"] = versionValue(version + 1)
+
+	// The email address changed, so move the email marker in the same
+	// transaction as the update to keep the address unique.
+	err = newTransaction(s.table).
+		put("user", updatedItem, versionCondition(version)).
+		failWith(updateFailure(patch.Version)).
+		delete("email", keys.EmailKey(models.NormalizeEmail(previousEmail)), condition{}).
+		create("email", emailMarkerItem(existingUser.Email, id)).
+		execute(ctx, s.client, "UsersService.UpdateUser")
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return s.users.unmarshal("UsersService.UpdateUser", updatedItem)
+}
 
-	// Marshal the updated user struct into a map of DynamoDB AttributeValues
-	updatedItem, err := attributevalue.MarshalMap(existingUser)
+// PatchUser attempts to apply the provided patch to the user with the provided
+// id. A new password is hashed before it is stored. Most patches are applied
+// in a single UpdateItem request, but a new email address also moves the
+// user's email marker, so it is applied in a transaction after reading the
+// current address. If version is non-zero the user must still be at that
+// version, or ErrVersionMismatch is returned. The user's id cannot be patched
+// and the email address cannot be removed. The updated models.User or an error
+// is returned.
+func (s *UsersService) PatchUser(ctx context.Context, id uuid.UUID, version int64, patch Patch) (models.User, error) {
+	s.logger.InfoContext(ctx, "Patching user", "id", id)
+
+	if err := checkPatchable("UsersService.PatchUser", "user", patch, "user_id"); err != nil {
+		return models.User{}, err
+	}
+	if slices.Contains(patch.Remove, "email") {
+		return models.User{}, &Error{
+			Kind:   KindValidation,
+			Op:     "UsersService.PatchUser",
+			Entity: "user",
+			Err:    fmt.Errorf("%w: email cannot be removed", ErrValidation),
+		}
+	}
+
+	// Only ever store the hash of the user's password
+	if password, ok := patch.Set["password"].(string); ok {
+		hash, err := models.HashPassword(password)
+		if err != nil {
+			return models.User{}, newError(
+				"UsersService.PatchUser",
+				"user",
+				fmt.Errorf("failed to hash password: %w", err),
+			)
+		}
+		patch.Set = maps.Clone(patch.Set)
+		patch.Set["password"] = hash
+	}
+
+	if email, ok := patch.Set["email"].(string); ok {
+		return s.patchUserEmail(ctx, id, version, email, patch)
+	}
+
+	return s.users.Update(ctx, keys.UserKey(id), version, patch)
+}
+
+// patchUserEmail applies a patch that sets the user's email address, moving
+// the email marker in the same transaction as the update so the address stays
+// unique. ErrAlreadyExists is returned if the address is taken.
+func (s *UsersService) patchUserEmail(ctx context.Context, id uuid.UUID, version int64, email string, patch Patch) (models.User, error) {
+	current, err := s.ReadUser(ctx, id)
 	if err != nil {
-		return models.User{}, fmt.Errorf(
-			"[in main.UsersService.UpdateUser] failed to marshal updated user: %w",
-			err,
+		return models.User{}, newError(
+			"UsersService.PatchUser",
+			"user",
+			fmt.Errorf("failed to read user: %w", err),
 		)
 	}
+	if !checkVersion(version, current.Version) {
+		return models.User{}, versionMismatch("UsersService.PatchUser", "user")
+	}
 
-	// Update the item in DynamoDB
-	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String("BlogContent"),
-		Item:      updatedItem,
-	})
+	// The update is conditional on the version that was read, so the marker
+	// being released is still the user's.
+	input, err := patch.updateItem(s.table.Name, keys.UserKey(id).AttributeValues(), deleteCondition(current.Version))
 	if err != nil {
-		return models.User{}, fmt.Errorf(
-			"[in main.UsersService.UpdateUser] failed to put updated item: %w",
-			err,
+		return models.User{}, newError(
+			"UsersService.PatchUser",
+			"user",
+			fmt.Errorf("failed to build update: %w", err),
 		)
 	}
 
-	return existingUser, nil
+	tx := newTransaction(s.table).
+		update("user", input).
+		failWith(updateFailure(version))
+	if models.NormalizeEmail(current.Email) != models.NormalizeEmail(email) {
+		tx.delete("email", keys.EmailKey(models.NormalizeEmail(current.Email)), condition{}).
+			create("email", emailMarkerItem(email, id))
+	}
+	if err = tx.execute(ctx, s.client, "UsersService.PatchUser"); err != nil {
+		return models.User{}, err
+	}
+
+	// A transaction cannot return the updated item, so read it back
+	return s.ReadUser(ctx, id)
 }
 
-// DeleteUser attempts to delete the user with the provided id. An error is
-// returned if the delete fails.
-func (s *UsersService) DeleteUser(ctx context.Context, id uuid.UUID) error {
+// DeleteUser attempts to delete the user with the provided id along with every
+// blog the user wrote, every comment left on those blogs, every comment the
+// user left elsewhere and every session the user holds. If version is non-zero
+// the user must still be at that version, or ErrVersionMismatch is returned.
+// The number of deleted items is returned, or an error if the user does not
+// exist or the delete fails.
+//
+// The user's content is deleted in transactions that each only apply while
+// the profile is still at the version that was read, so a concurrent write to
+// the user stops the delete. The profile and its email marker are deleted last,
+// together, so an address is never released while its user exists, and a
+// failed delete can be retried.
+func (s *UsersService) DeleteUser(ctx context.Context, id uuid.UUID, version int64) (int, error) {
 	s.logger.InfoContext(ctx, "Deleting user", "id", id)
 
-	// Perform the delete operation in DynamoDB
-	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
-		TableName: aws.String("BlogContent"),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{
-				Value: fmt.Sprintf("USER#%s", id.String()),
-			},
-			"SK": &types.AttributeValueMemberS{
-				Value: "PROFILE",
-			},
-		},
-		ConditionExpression: aws.String("attribute_exists(PK) AND attribute_exists(SK)"),
+	// Make sure the user exists before removing anything that references it
+	result, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:                aws.String(s.table.Name),
+		Key:                      keys.UserKey(id).AttributeValues(),
+		ProjectionExpression:     aws.String("PK, email, #version"),
+		ExpressionAttributeNames: map[string]string{"#version": "version"},
 	})
 	if err != nil {
-		return fmt.Errorf(
-			"[in main.UsersService.DeleteUser] failed to delete item: %w",
-			err,
+		return 0, newError(
+			"UsersService.DeleteUser",
+			"user",
+			fmt.Errorf("failed to get item: %w", err),
+		)
+	}
+	if result.Item == nil {
+		return 0, notFound("UsersService.DeleteUser", "user")
+	}
+
+	var existing struct {
+		Email   string `dynamodbav:"email"`
+		Version int64  `dynamodbav:"version"`
+	}
+	if err = attributevalue.UnmarshalMap(result.Item, &existing); err != nil {
+		return 0, newError(
+			"UsersService.DeleteUser",
+			"user",
+			fmt.Errorf("failed to unmarshal result: %w", err),
+		)
+	}
+
+	// Fail early on a stale version, before anything is deleted
+	if !checkVersion(version, existing.Version) {
+		return 0, versionMismatch("UsersService.DeleteUser", "user")
+	}
+
+	// Collect the keys of everything owned by the user
+	owned, err := s.userContentKeys(ctx, id)
+	if err != nil {
+		return 0, newError(
+			"UsersService.DeleteUser",
+			"user",
+			fmt.Errorf("failed to collect user content: %w", err),
 		)
 	}
 
-	return nil
+	// Delete the user's content while the profile is unchanged
+	userKey := keys.UserKey(id)
+	if len(owned) > 0 {
+		content := newTransaction(s.table).
+			allowSplit().
+			guard("user", userKey, versionCondition(existing.Version)).
+			failWith(updateFailure(version))
+		for _, key := range owned {
+			content.delete(contentEntity(key), itemKey(key), condition{})
+		}
+		if err = content.execute(ctx, s.client, "UsersService.DeleteUser"); err != nil {
+			return 0, err
+		}
+	}
+
+	// Delete the profile, releasing the user's email address with it
+	tx := newTransaction(s.table).
+		delete("user", userKey, versionCondition(existing.Version)).
+		failWith(updateFailure(version))
+	deleted := len(owned) + 1
+	if existing.Email != "" {
+		tx.delete("email", keys.EmailKey(models.NormalizeEmail(existing.Email)), condition{})
+		deleted++
+	}
+	if err = tx.execute(ctx, s.client, "UsersService.DeleteUser"); err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
 }
 
-// ListUsers attempts to list all users in the database using a GSI. A slice of models.User
-// or an error is returned.
-func (s *UsersService) ListUsers(ctx context.Context) ([]models.User, error) {
-	s.logger.InfoContext(ctx, "Listing all users")
+// contentEntity names the kind of user content stored under the provided key,
+// for the errors of a failed delete.
+func contentEntity(key map[string]types.AttributeValue) string {
+	k := itemKey(key)
+	switch {
+	case strings.HasPrefix(k.SK, keys.SessionPrefix):
+		return "session"
+	case k.SK == keys.Metadata:
+		return "blog"
+	default:
+		return "comment"
+	}
+}
 
-	// Define the input for the Query operation
-	input := &dynamodb.QueryInput{
-		TableName:              aws.String("BlogContent"),
-		IndexName:              aws.String("GSI1"),
-		KeyConditionExpression: aws.String("GSI1PK = :pk"),
+// userContentKeys returns the keys of every blog written by the user with the
+// provided id, every comment on those blogs, every comment the user left and
+// every session the user holds. Each key is returned once.
+func (s *UsersService) userContentKeys(ctx context.Context, id uuid.UUID) ([]map[string]types.AttributeValue, error) {
+	userSK := &types.AttributeValueMemberS{Value: keys.User(id)}
+
+	// Find the blogs written by the user
+	blogKeys, err := queryKeys(ctx, s.client, &dynamodb.QueryInput{
+		TableName:              aws.String(s.table.Name),
+		IndexName:              aws.String(s.table.GSI1),
+		KeyConditionExpression: aws.String("GSI1PK = :pk AND GSI1SK = :sk"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":pk": &types.AttributeValueMemberS{
-				Value: "USER",
-			},
+			":pk": &types.AttributeValueMemberS{Value: keys.Blogs},
+			":sk": userSK,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blogs: %w", err)
+	}
+
+	// Find the comments left by the user
+	owned, err := queryKeys(ctx, s.client, &dynamodb.QueryInput{
+		TableName:              aws.String(s.table.Name),
+		IndexName:              aws.String(s.table.GSI1),
+		KeyConditionExpression: aws.String("GSI1PK = :pk AND GSI1SK = :sk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: keys.Comments},
+			":sk": userSK,
 		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments: %w", err)
 	}
 
-	// Perform the Query operation
-	result, err := s.client.Query(ctx, input)
+	// Find the user's refresh token sessions
+	sessionKeys, err := queryKeys(ctx, s.client, &dynamodb.QueryInput{
+		TableName:              aws.String(s.table.Name),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: keys.User(id)},
+			":sk": &types.AttributeValueMemberS{Value: keys.SessionPrefix},
+		},
+	})
 	if err != nil {
-		return nil, fmt.Errorf(
-			"[in main.UsersService.ListUsers] failed to query items: %w",
-			err,
-		)
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	owned = append(owned, sessionKeys...)
+
+	// Every item in a blog's partition, its metadata and all of its
+	// comments, is removed with the blog.
+	for _, blogKey := range blogKeys {
+		partitionKeys, err := queryKeys(ctx, s.client, &dynamodb.QueryInput{
+			TableName:              aws.String(s.table.Name),
+			KeyConditionExpression: aws.String("PK = :pk"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": blogKey["PK"],
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query blog partition: %w", err)
+		}
+		owned = append(owned, partitionKeys...)
 	}
 
-	// Unmarshal the results into a slice of models.User
-	var users []models.User
-	if err = attributevalue.UnmarshalListOfMaps(result.Items, &users); err != nil {
-		return nil, fmt.Errorf(
-			"[in main.UsersService.ListUsers] failed to unmarshal result: %w",
-			err,
-		)
+	// The user's own comments on their blogs are matched by both queries, and
+	// BatchWriteItem rejects duplicate keys in a single request.
+	seen := make(map[string]bool, len(owned))
+	unique := owned[:0]
+	for _, key := range owned {
+		pk, _ := key["PK"].(*types.AttributeValueMemberS)
+		sk, _ := key["SK"].(*types.AttributeValueMemberS)
+		if pk == nil || sk == nil {
+			continue
+		}
+		k := pk.Value + "|" + sk.Value
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		unique = append(unique, key)
 	}
 
-	return users, nil
+	return unique, nil
+}
+
+// ListUsers attempts to list a page of users in the database using a GSI. A
+// slice of models.User and the cursor of the next page, which is empty on the
+// last page, or an error is returned. ErrInvalidCursor is returned if the
+// page's cursor is not valid.
+func (s *UsersService) ListUsers(ctx context.Context, page Page) ([]models.User, string, error) {
+	s.logger.InfoContext(ctx, "Listing users")
+
+	return s.users.QueryIndex(ctx, keys.Users, "", page)
 }
@@ -8,16 +8,49 @@ import (
 	"testing"
 
 	"github.com/agallagher-captech/blog/internal/models"
+	"github.com/agallagher-captech/blog/internal/seed"
+	"github.com/agallagher-captech/blog/internal/services/memory"
 	"github.com/agallagher-captech/blog/internal/services/mock"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	testifymock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 var itemNotFoundError = errors.New("item not found")
 
+// newSeededClient returns an in-memory DynamoDB client holding the
+// repository's seed data, so tests can assert on the state of the table.
+func newSeededClient(t *testing.T) *memory.Client {
+	t.Helper()
+	client := memory.NewClient()
+	require.NoError(t, client.LoadDir("../../dynamodb_seed"), "failed to load seed data")
+	return client
+}
+
+// TestUsersService_ConfiguredTable checks that the services only use the
+// names of the table they are given.
+func TestUsersService_ConfiguredTable(t *testing.T) {
+	schema, requests, err := seed.ReadDir("../../dynamodb_seed")
+	require.NoError(t, err)
+	table := Table{Name: "BlogContent-test", GSI1: "ByType"}
+	seed.Rename(schema, requests, table.Name, map[string]string{DefaultTable().GSI1: table.GSI1})
+	client := memory.NewClient()
+	require.NoError(t, client.Load(schema, requests), "failed to load renamed seed data")
+
+	usersService := NewUsersService(slog.Default(), client, table, NewCursors("test-cursor-key"))
+	users, _, err := usersService.ListUsers(context.TODO(), Page{Limit: 100})
+	require.NoError(t, err, "failed to list users of the renamed table")
+	require.NotEmpty(t, users, "no users were listed")
+
+	user, err := usersService.ReadUser(context.TODO(), users[0].ID.UUID)
+	require.NoError(t, err, "failed to read a user of the renamed table")
+	assert.Equal(t, users[0].ID, user.ID, "read the wrong user")
+}
+
 func TestUsersService_ReadUser(t *testing.T) {
 	testcases := map[string]struct {
 		mockCalled     bool
@@ -112,10 +145,7 @@ func TestUsersService_ReadUser(t *testing.T) {
 					Once()
 			}
 
-			userService := UsersService{
-				logger: logger,
-				client: mockClient,
-			}
+			userService := NewUsersService(logger, mockClient, DefaultTable(), NewCursors("test-cursor-key"))
 
 			output, err := userService.ReadUser(context.TODO(), tc.input)
 
@@ -133,3 +163,210 @@ func TestUsersService_ReadUser(t *testing.T) {
 		})
 	}
 }
+
+func TestUsersService_CreateUser(t *testing.T) {
+	user := models.User{
+		ID:       models.UUID{UUID: uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")},
+		Name:     "Test User",
+		Email:    "testUser@example.com",
+		Password: "Test Password",
+	}
+
+	// The stored user has its storage keys populated and starts at version 1
+	created := user
+	created.DynamoDBBase = models.DynamoDBBase{
+		PK:      "USER#d2eddb69-f92f-694d-450d-e7cdb6decce3",
+		SK:      "PROFILE",
+		GSI1PK:  "USER",
+		GSI1SK:  "USER#d2eddb69-f92f-694d-450d-e7cdb6decce3",
+		Version: 1,
+	}
+
+	testcases := map[string]struct {
+		mockError      error
+		expectedOutput models.User
+		expectedError  error
+	}{
+		"happy path": {
+			mockError:      nil,
+			expectedOutput: created,
+			expectedError:  nil,
+		},
+		"email already taken": {
+			mockError: &types.TransactionCanceledException{
+				CancellationReasons: []types.CancellationReason{
+					{Code: aws.String("None")},
+					{Code: aws.String("ConditionalCheckFailed")},
+				},
+			},
+			expectedOutput: models.User{},
+			expectedError:  ErrAlreadyExists,
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			mockClient := new(mock.DynamoClient)
+			mockClient.
+				On("TransactWriteItems", context.TODO(), testifymock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+					marker := input.TransactItems[1].Put.Item["PK"].(*types.AttributeValueMemberS)
+					return marker.Value == "EMAIL#testuser@example.com"
+				})).
+				Return(&dynamodb.TransactWriteItemsOutput{}, tc.mockError).
+				Once()
+
+			userService := NewUsersService(slog.Default(), mockClient, DefaultTable(), NewCursors("test-cursor-key"))
+
+			output, err := userService.CreateUser(context.TODO(), user)
+
+			assert.ErrorIs(t, err, tc.expectedError, "error did not match")
+			if tc.expectedError == nil {
+				assert.True(t, output.VerifyPassword(user.Password), "stored password is not a hash of the input")
+				output.Password = user.Password
+			}
+			assert.Equal(t, tc.expectedOutput, output, "returned data does not match")
+			mockClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUsersService_EmailUniqueness(t *testing.T) {
+	ctx := context.TODO()
+	usersService := NewUsersService(slog.Default(), newSeededClient(t), DefaultTable(), NewCursors("test-cursor-key"))
+	seededID := uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")
+
+	// The seeded user's address is taken, whatever its case
+	_, err := usersService.CreateUser(ctx, models.User{
+		ID:       models.UUID{UUID: uuid.New()},
+		Name:     "Another Emma",
+		Email:    "Emma@Example.com",
+		Password: "Test Password",
+	})
+	assert.ErrorIs(t, err, ErrAlreadyExists, "duplicate email was accepted")
+
+	// Moving the seeded user to a new address releases the old one
+	patched, err := usersService.PatchUser(ctx, seededID, 0, Patch{
+		Set: map[string]any{"email": "emma.davis@example.com"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "emma.davis@example.com", patched.Email, "email was not patched")
+
+	created, err := usersService.CreateUser(ctx, models.User{
+		ID:       models.UUID{UUID: uuid.New()},
+		Name:     "Another Emma",
+		Email:    "emma@example.com",
+		Password: "Test Password",
+	})
+	require.NoError(t, err, "released email could not be reused")
+
+	found, err := usersService.ReadUserByEmail(ctx, "emma@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, found.ID, "email did not resolve to the new user")
+
+	// A version the user is not at is rejected
+	_, err = usersService.PatchUser(ctx, seededID, patched.Version+1, Patch{
+		Set: map[string]any{"name": "Emma Smith"},
+	})
+	assert.ErrorIs(t, err, ErrVersionMismatch, "stale version was accepted")
+
+	// Deleting the user releases their address too
+	_, err = usersService.DeleteUser(ctx, seededID, patched.Version)
+	require.NoError(t, err)
+	_, err = usersService.ReadUser(ctx, seededID)
+	assert.ErrorIs(t, err, ErrNotFound, "user was not deleted")
+
+	_, err = usersService.CreateUser(ctx, models.User{
+		ID:       models.UUID{UUID: uuid.New()},
+		Name:     "Emma Davis",
+		Email:    "emma.davis@example.com",
+		Password: "Test Password",
+	})
+	assert.NoError(t, err, "email of a deleted user could not be reused")
+}
+
+// interceptClient is an in-memory client that calls beforeTransact before
+// every TransactWriteItems request, to change the table between the reads and
+// writes of an operation.
+type interceptClient struct {
+	*memory.Client
+	beforeTransact func()
+}
+
+func (c *interceptClient) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	if c.beforeTransact != nil {
+		c.beforeTransact()
+	}
+	return c.Client.TransactWriteItems(ctx, params, optFns...)
+}
+
+func TestUsersService_DeleteUser_ConcurrentWrite(t *testing.T) {
+	seededID := uuid.MustParse("d2eddb69-f92f-694d-450d-e7cdb6decce3")
+	blogID := uuid.MustParse("17e16813-c203-0355-1e4c-17c630f114f3")
+
+	testcases := map[string]struct {
+		version       int64
+		expectedError error
+	}{
+		"without version": {
+			expectedError: ErrConflict,
+		},
+		"with version": {
+			version:       1,
+			expectedError: ErrVersionMismatch,
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.TODO()
+			client := &interceptClient{Client: newSeededClient(t)}
+			usersService := NewUsersService(slog.Default(), client, DefaultTable(), NewCursors("test-cursor-key"))
+			blogsService := NewBlogsService(slog.Default(), client, DefaultTable(), NewCursors("test-cursor-key"))
+
+			// Put the user at version 1, then update it once the delete has
+			// read it
+			user, err := usersService.PatchUser(ctx, seededID, 0, Patch{Set: map[string]any{"name": "Emma Smith"}})
+			require.NoError(t, err)
+			require.Equal(t, int64(1), user.Version)
+			blog, err := blogsService.ReadBlog(ctx, blogID)
+			require.NoError(t, err)
+			require.Equal(t, seededID, blog.UserID.UUID, "seeded blog has another author")
+
+			client.beforeTransact = func() {
+				client.beforeTransact = nil
+				_, err := usersService.PatchUser(ctx, seededID, 0, Patch{Set: map[string]any{"name": "Emma Jones"}})
+				require.NoError(t, err)
+			}
+
+			_, err = usersService.DeleteUser(ctx, seededID, tc.version)
+			assert.ErrorIs(t, err, tc.expectedError, "error did not match")
+
+			// Nothing was deleted, and the email address is still reserved
+			_, err = usersService.ReadUser(ctx, seededID)
+			assert.NoError(t, err, "user was deleted")
+			found, err := usersService.ReadUserByEmail(ctx, "emma@example.com")
+			require.NoError(t, err, "email marker was deleted")
+			assert.Equal(t, seededID, found.ID.UUID, "email resolved to another user")
+			_, err = blogsService.ReadBlog(ctx, blogID)
+			assert.NoError(t, err, "blog was deleted")
+		})
+	}
+}
+
+func TestUsersService_VerifyPassword_ComparesEveryLogin(t *testing.T) {
+	var compared []string
+	t.Cleanup(func() { checkPassword = models.User.VerifyPassword })
+	checkPassword = func(user models.User, password string) bool {
+		compared = append(compared, user.Password)
+		return user.VerifyPassword(password)
+	}
+	usersService := NewUsersService(slog.Default(), newSeededClient(t), DefaultTable(), NewCursors("test-cursor-key"))
+
+	// A registered and an unknown address both run a bcrypt comparison
+	_, err := usersService.VerifyPassword(context.TODO(), "emma@example.com", "wrong password")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "wrong password was accepted")
+	_, err = usersService.VerifyPassword(context.TODO(), "nobody@example.com", "wrong password")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "unknown email was accepted")
+
+	require.Len(t, compared, 2, "password was not compared on every login")
+	assert.NotEqual(t, dummyPasswordHash, compared[0], "registered user was compared against the dummy hash")
+	assert.Equal(t, dummyPasswordHash, compared[1], "unknown email was not compared against the dummy hash")
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/google/uuid"
+)
+
+var ErrVersionMismatch = fmt.Errorf("version mismatch")
+
+// versionMismatch returns an *Error reporting that the entity the provided
+// operation acted on is no longer at the version the caller expected.
+func versionMismatch(op, entity string) error {
+	return &Error{Kind: KindConflict, Op: op, Entity: entity, Err: ErrVersionMismatch}
+}
+
+// checkVersion reports whether an item at the current version satisfies the
+// caller's expected version. An expected version of 0 means the caller does
+// not care which version it acts on.
+func checkVersion(expected, current int64) bool {
+	return expected == 0 || expected == current
+}
+
+// condition is a DynamoDB condition expression along with the attribute names
+// and values it refers to.
+type condition struct {
+	expression *string
+	names      map[string]string
+	values     map[string]types.AttributeValue
+
+	// owner is the user the item must belong to, set by ownedBy.
+	owner string
+}
+
+// versionCondition returns a condition that only holds while the item exists
+// and is still at the provided version. Items written before versioning was
+// introduced have no version attribute and are at version 0.
+func versionCondition(version int64) condition {
+	if version == 0 {
+		return condition{
+			expression: aws.String("attribute_exists(PK) AND attribute_not_exists(#version)"),
+			names:      map[string]string{"#version": "version"},
+		}
+	}
+
+	return condition{
+		expression: aws.String("attribute_exists(PK) AND #version = :version"),
+		names:      map[string]string{"#version": "version"},
+		values: map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)},
+		},
+	}
+}
+
+// deleteCondition returns the condition of a delete that must only remove an
+// existing item, and only at the expected version if one is given.
+func deleteCondition(expected int64) condition {
+	if expected == 0 {
+		return condition{
+			expression: aws.String("attribute_exists(PK) AND attribute_exists(SK)"),
+		}
+	}
+	return versionCondition(expected)
+}
+
+// ownedBy returns a condition that holds while cond holds and the item's
+// user_id is the provided owner, so that a write can only be made by the user
+// who owns the item without reading it first.
+func ownedBy(cond condition, owner uuid.UUID) condition {
+	expression := "#owner = :owner"
+	if cond.expression != nil {
+		expression = *cond.expression + " AND " + expression
+	}
+
+	names := maps.Clone(cond.names)
+	if names == nil {
+		names = map[string]string{}
+	}
+	names["#owner"] = "user_id"
+
+	values := maps.Clone(cond.values)
+	if values == nil {
+		values = map[string]types.AttributeValue{}
+	}
+	values[":owner"] = &types.AttributeValueMemberS{Value: owner.String()}
+
+	return condition{
+		expression: aws.String(expression),
+		names:      names,
+		values:     values,
+		owner:      owner.String(),
+	}
+}
+
+// failure returns the error for the condition failing on the provided item,
+// which is the item returned with ReturnValuesOnConditionCheckFailure:
+// ErrNotFound if there was none, ErrForbidden if it belongs to someone other
+// than the condition's owner, and ErrVersionMismatch otherwise.
+func (c condition) failure(op, entity string, item map[string]types.AttributeValue) error {
+	if len(item) == 0 {
+		return notFound(op, entity)
+	}
+	if c.owner != "" {
+		if owner, ok := item["user_id"].(*types.AttributeValueMemberS); !ok || owner.Value != c.owner {
+			return forbidden(op, entity)
+		}
+	}
+	return versionMismatch(op, entity)
+}
+
+// classifyConditionFailure returns the error for a write guarded by cond that
+// failed while performing the described action. The write must have asked for
+// the item with ReturnValuesOnConditionCheckFailure so a missing item can be
+// told apart from one at another version or with another owner.
+func classifyConditionFailure(op, entity, action string, cond condition, err error) error {
+	var ccf *types.ConditionalCheckFailedException
+	if !errors.As(err, &ccf) {
+		return newError(op, entity, fmt.Errorf("failed to %s: %w", action, err))
+	}
+	return cond.failure(op, entity, ccf.Item)
+}
+
+// classifyUpdateFailure returns the error for a versioned write, guarded by
+// versionCondition, that failed. If the caller expected a particular version a
+// failed condition means the item has moved on, otherwise it was changed
+// concurrently between being read and written.
+func classifyUpdateFailure(op, entity string, expected int64, err error) error {
+	var ccf *types.ConditionalCheckFailedException
+	if expected != 0 && errors.As(err, &ccf) {
+		return versionMismatch(op, entity)
+	}
+	return newError(op, entity, fmt.Errorf("failed to put updated item: %w", err))
+}
+
+// updateFailure returns the failure of a versioned transaction item written
+// over an item that was read first, following classifyUpdateFailure.
+func updateFailure(expected int64) func(op, entity string) error {
+	return func(op, entity string) error {
+		if expected != 0 {
+			return versionMismatch(op, entity)
+		}
+		return &Error{
+			Kind:   KindConflict,
+			Op:     op,
+			Entity: entity,
+			Err:    fmt.Errorf("%w: %s was changed concurrently", ErrConflict, entity),
+		}
+	}
+}